@@ -6,6 +6,8 @@ export default Component.extend({
 	classNames: ['quill-js'],
 
 	content: "",
+	delta: null,
+	format: "html",
 	editor: null,
 
 	didInsertElement() {
@@ -16,11 +18,18 @@ export default Component.extend({
 			theme: 'snow'
 		});
 
-		editor.setText(this.get('content'));
+		// The delta is the source of truth; older posts only have their HTML
+		if (this.get('delta')) {
+			editor.setContents(this.get('delta'));
+		} else {
+			editor.clipboard.dangerouslyPasteHTML(this.get('content') || "");
+		}
 
 		editor.on('text-change', () => {
 			run(() => {
-				this.set('content', editor.getText());
+				this.set('delta', editor.getContents());
+				this.set('content', editor.root.innerHTML);
+				this.set('format', 'delta');
 			});
 		});
 
@@ -0,0 +1,15 @@
+import Model from 'ember-data/model';
+import attr from 'ember-data/attr';
+import { belongsTo, hasMany } from 'ember-data/relationships';
+
+export default Model.extend({
+  createdAt:  attr('date'),
+  updatedAt:  attr('date'),
+  name:       attr('string'),
+  slug:       attr('string'),
+  path:       attr('string'),
+
+  parent:     belongsTo('category', { inverse: 'children' }),
+  children:   hasMany('category', { inverse: 'parent' }),
+  posts:      hasMany('post'),
+});
\ No newline at end of file
@@ -1,14 +1,28 @@
 import Model from 'ember-data/model';
 import attr from 'ember-data/attr';
-import { belongsTo } from 'ember-data/relationships';
+import { belongsTo, hasMany } from 'ember-data/relationships';
 
 export default Model.extend({
   createdAt:  attr('date'),
   updatedAt:  attr('date'),
+  version:    attr('number'),
   title:      attr('string'),
   excerpt:    attr('string'),
+  excerptGenerated: attr('boolean'),
   content:    attr('string'),
+  contentFormat: attr('string', { defaultValue: 'html' }),
+  contentDelta: attr(),
+  contentHtml: attr('string'),
+  wordCount:  attr('number'),
+  readingTime: attr('number'),
   permalink:  attr('string'),
+  status:     attr('string', { defaultValue: 'draft' }),
+  publishedAt: attr('date'),
+  deletedAt:  attr('date'),
 
-  user:       belongsTo('user'),
+  user:       belongsTo('user', { inverse: 'posts' }),
+  authors:    hasMany('user', { inverse: null }),
+  category:   belongsTo('category'),
+  tags:       hasMany('tag'),
+  series:     belongsTo('series', { inverse: 'posts' }),
 });
\ No newline at end of file
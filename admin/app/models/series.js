@@ -0,0 +1,13 @@
+import Model from 'ember-data/model';
+import attr from 'ember-data/attr';
+import { hasMany } from 'ember-data/relationships';
+
+export default Model.extend({
+  createdAt:   attr('date'),
+  updatedAt:   attr('date'),
+  title:       attr('string'),
+  slug:        attr('string'),
+  description: attr('string'),
+
+  posts:       hasMany('post', { inverse: 'series' }),
+});
\ No newline at end of file
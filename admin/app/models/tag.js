@@ -0,0 +1,12 @@
+import Model from 'ember-data/model';
+import attr from 'ember-data/attr';
+import { hasMany } from 'ember-data/relationships';
+
+export default Model.extend({
+  createdAt:  attr('date'),
+  updatedAt:  attr('date'),
+  name:       attr('string'),
+  slug:       attr('string'),
+
+  posts:      hasMany('post'),
+});
@@ -5,8 +5,16 @@ import { hasMany } from 'ember-data/relationships';
 export default Model.extend({
   createdAt:  attr('date'),
   updatedAt:  attr('date'),
+  version:    attr('number'),
+  deletedAt:  attr('date'),
   email:      attr('string'),
   username:   attr('string'),
+  role:       attr('string'),
+  displayName: attr('string'),
+  bio:        attr('string'),
+  avatarUrl:  attr('string'),
+  website:    attr('string'),
+  socialLinks: attr(),
 
   posts:      hasMany('post'),
 });
\ No newline at end of file
@@ -0,0 +1,312 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/jmoiron/sqlx"
+	"github.com/manyminds/api2go"
+	"github.com/timrourke/timrourke.com/model"
+	"github.com/timrourke/timrourke.com/storage"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultTokenTTL is how long an access token stays valid when no TTL is
+// configured
+const DefaultTokenTTL = 24 * time.Hour
+
+var (
+	// ErrNoCredentials is returned when a request carries no bearer token
+	ErrNoCredentials = errors.New("Authentication required")
+
+	// ErrInvalidToken is returned when a bearer token is malformed, has a bad
+	// signature, has expired, or belongs to a user that no longer exists
+	ErrInvalidToken = errors.New("Invalid or expired token")
+
+	// ErrTwoFactorRequired is returned when a token was issued without a second
+	// factor to a user who has enrolled in, or whose role requires, two-factor
+	// authentication
+	ErrTwoFactorRequired = errors.New("Two-factor authentication is required")
+
+	// ErrInvalidSecondFactor is returned when a TOTP or recovery code is wrong,
+	// expired or already used
+	ErrInvalidSecondFactor = errors.New("Invalid two-factor code")
+
+	// ErrLoginRequired is returned when a personal access token is used for
+	// something only a logged in user may do
+	ErrLoginRequired = errors.New("Personal access tokens may not be used for this")
+
+	// ErrNoSecret is returned when an authenticator is built without a secret
+	// to sign tokens with
+	ErrNoSecret = errors.New("AUTH_TOKEN_SECRET must be set to sign access tokens")
+)
+
+// PersonalTokenPrefix starts every personal access token, telling them apart
+// from tokens issued by logging in
+const PersonalTokenPrefix = "trk_"
+
+// Claims are the JWT claims carried by an access token. The subject is the id
+// of the authenticated user.
+type Claims struct {
+	jwt.StandardClaims
+
+	// TwoFactor is true when the user gave a second factor to log in
+	TwoFactor bool `json:"tfa,omitempty"`
+}
+
+// Session is an authenticated user, and the scopes of the personal access
+// token they authenticated with. Scopes is nil for tokens issued by logging
+// in, which may do anything the user's role allows.
+type Session struct {
+	User   *model.User
+	Scopes model.Scopes
+}
+
+// IsPersonalToken reports whether the session comes from a personal access
+// token rather than from logging in
+func (s Session) IsPersonalToken() bool {
+	return s.Scopes != nil
+}
+
+// Allows reports whether the session may act within a scope
+func (s Session) Allows(scope string) bool {
+	return !s.IsPersonalToken() || s.Scopes.Has(scope)
+}
+
+// Authenticator issues access tokens and resolves them back to users
+type Authenticator struct {
+	Secret              []byte
+	TTL                 time.Duration
+	UserStorage         *storage.UserStorage
+	SettingStorage      *storage.SettingStorage
+	RecoveryCodeStorage *storage.RecoveryCodeStorage
+	TokenStorage        *storage.TokenStorage
+	Throttle            *Throttle
+}
+
+// NewAuthenticator returns a new instance of Authenticator. The secret signs
+// every token, so it must be set and must stay the same across restarts.
+func NewAuthenticator(secret []byte, ttl time.Duration, DB *sqlx.DB) (*Authenticator, error) {
+	if len(secret) == 0 {
+		return nil, ErrNoSecret
+	}
+
+	if ttl <= 0 {
+		ttl = DefaultTokenTTL
+	}
+
+	return &Authenticator{
+		Secret:              secret,
+		TTL:                 ttl,
+		UserStorage:         storage.NewUserStorage(DB),
+		SettingStorage:      storage.NewSettingStorage(DB),
+		RecoveryCodeStorage: storage.NewRecoveryCodeStorage(DB),
+		TokenStorage:        storage.NewTokenStorage(DB),
+		Throttle:            NewThrottle(DB),
+	}, nil
+}
+
+// IssueToken returns a signed access token for the user and its expiry.
+// twoFactor records whether the user gave a second factor to log in.
+func (a *Authenticator) IssueToken(user *model.User, twoFactor bool) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(a.TTL)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		StandardClaims: jwt.StandardClaims{
+			Subject:   user.GetID(),
+			IssuedAt:  now.Unix(),
+			ExpiresAt: expiresAt.Unix(),
+		},
+		TwoFactor: twoFactor,
+	})
+
+	signed, err := token.SignedString(a.Secret)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return signed, expiresAt, nil
+}
+
+// ParseToken verifies a signed access token and returns its claims
+func (a *Authenticator) ParseToken(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+
+		return a.Secret, nil
+	})
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+// Authenticate resolves the bearer token in the request's Authorization
+// header to a session. Tokens issued by logging in must satisfy the two-factor
+// policy; personal access tokens can only be created by sessions that did.
+func (a *Authenticator) Authenticate(r *http.Request) (*Session, error) {
+	tokenString := bearerToken(r)
+	if len(tokenString) == 0 {
+		return nil, ErrNoCredentials
+	}
+
+	if strings.HasPrefix(tokenString, PersonalTokenPrefix) {
+		return a.authenticatePersonalToken(tokenString)
+	}
+
+	user, claims, err := a.authenticateToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	if !claims.TwoFactor {
+		if user.HasTwoFactor() {
+			return nil, ErrTwoFactorRequired
+		}
+
+		required, err := a.RequiresTwoFactor(user)
+		if err != nil {
+			return nil, err
+		} else if required {
+			return nil, ErrTwoFactorRequired
+		}
+	}
+
+	return &Session{User: user}, nil
+}
+
+// AuthenticateForEnrollment resolves the bearer token in the request's
+// Authorization header to a user without enforcing the two-factor policy, so
+// users whose role requires two-factor authentication can enroll in it.
+// Personal access tokens are not accepted.
+func (a *Authenticator) AuthenticateForEnrollment(r *http.Request) (*model.User, error) {
+	tokenString := bearerToken(r)
+	if len(tokenString) == 0 {
+		return nil, ErrNoCredentials
+	} else if strings.HasPrefix(tokenString, PersonalTokenPrefix) {
+		return nil, ErrLoginRequired
+	}
+
+	user, _, err := a.authenticateToken(tokenString)
+	return user, err
+}
+
+// RequiresTwoFactor reports whether the user's role must use two-factor
+// authentication
+func (a *Authenticator) RequiresTwoFactor(user *model.User) (bool, error) {
+	value, err := a.SettingStorage.Get(model.SettingTwoFactorRoles)
+	if err != nil {
+		return false, err
+	}
+
+	for _, role := range model.ParseList(value) {
+		if role == user.Role {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// VerifySecondFactor checks a TOTP code, or one of the user's recovery codes,
+// and spends it so that it cannot be used again
+func (a *Authenticator) VerifySecondFactor(user *model.User, code string) error {
+	if !user.HasTwoFactor() {
+		return ErrInvalidSecondFactor
+	}
+
+	if IsRecoveryCode(code) {
+		err := a.RecoveryCodeStorage.Use(user.GetID(), HashSecret(NormalizeRecoveryCode(code)))
+		if err == sql.ErrNoRows {
+			return ErrInvalidSecondFactor
+		}
+
+		return err
+	}
+
+	step, ok := ValidateTOTP(*user.TOTPSecret, code, time.Now(), user.TOTPLastStep)
+	if !ok {
+		return ErrInvalidSecondFactor
+	}
+
+	err := a.UserStorage.UseTOTPStep(user, step)
+	if err == sql.ErrNoRows {
+		return ErrInvalidSecondFactor
+	}
+
+	return err
+}
+
+// authenticateToken resolves a token issued by logging in to a user and the
+// token's claims
+func (a *Authenticator) authenticateToken(tokenString string) (*model.User, *Claims, error) {
+	claims, err := a.ParseToken(tokenString)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user, err := a.UserStorage.GetOne(claims.Subject)
+	if err != nil {
+		return nil, nil, ErrInvalidToken
+	}
+
+	return user, claims, nil
+}
+
+// authenticatePersonalToken resolves a personal access token to a session
+// limited to the token's scopes, and records that it was used
+func (a *Authenticator) authenticatePersonalToken(tokenString string) (*Session, error) {
+	token, err := a.TokenStorage.GetByHash(HashSecret(tokenString))
+	if err != nil || !token.IsUsable(time.Now()) {
+		return nil, ErrInvalidToken
+	}
+
+	user, err := a.UserStorage.GetOne(token.UserId)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if err = a.TokenStorage.Touch(token.GetID()); err != nil {
+		return nil, err
+	}
+
+	scopes := token.Scopes
+	if scopes == nil {
+		scopes = model.Scopes{}
+	}
+
+	return &Session{User: user, Scopes: scopes}, nil
+}
+
+// Middleware is an api2go middleware that stores the authenticated session, or
+// the reason authentication failed, in the request context. api2go
+// middlewares cannot abort a request, so resources enforce authentication by
+// calling RequireSession.
+func (a *Authenticator) Middleware(c api2go.APIContexter, w http.ResponseWriter, r *http.Request) {
+	session, err := a.Authenticate(r)
+	if err != nil {
+		c.Set(authErrorContextKey, err)
+		return
+	}
+
+	c.Set(sessionContextKey, session)
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer" header
+func bearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "bearer ") {
+		return ""
+	}
+
+	return strings.TrimSpace(header[7:])
+}
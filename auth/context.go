@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"github.com/manyminds/api2go"
+	"github.com/timrourke/timrourke.com/model"
+)
+
+const (
+	sessionContextKey   = "auth.session"
+	authErrorContextKey = "auth.error"
+)
+
+// SessionFromContext returns the authenticated session stored by Middleware
+func SessionFromContext(c api2go.APIContexter) (*Session, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	value, ok := c.Get(sessionContextKey)
+	if !ok {
+		return nil, false
+	}
+
+	session, ok := value.(*Session)
+	return session, ok
+}
+
+// UserFromContext returns the authenticated user stored by Middleware
+func UserFromContext(c api2go.APIContexter) (*model.User, bool) {
+	session, ok := SessionFromContext(c)
+	if !ok {
+		return nil, false
+	}
+
+	return session.User, true
+}
+
+// RequireSession returns the authenticated session, or the reason the request
+// could not be authenticated
+func RequireSession(c api2go.APIContexter) (*Session, error) {
+	if session, ok := SessionFromContext(c); ok {
+		return session, nil
+	}
+
+	if c != nil {
+		if value, ok := c.Get(authErrorContextKey); ok {
+			if err, ok := value.(error); ok {
+				return nil, err
+			}
+		}
+	}
+
+	return nil, ErrNoCredentials
+}
+
+// RequireUser returns the authenticated user, or the reason the request could
+// not be authenticated
+func RequireUser(c api2go.APIContexter) (*model.User, error) {
+	session, err := RequireSession(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return session.User, nil
+}
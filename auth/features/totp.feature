@@ -0,0 +1,25 @@
+Feature: generate TOTP codes
+	In order to offer two-factor authentication
+	As the developer of timrourke.com
+	I need TOTP codes that match RFC 6238
+
+	Scenario Outline: Match the RFC 6238 SHA1 test vectors
+		When I use the TOTP secret "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+		Then the TOTP code at <time> should be "<code>"
+
+		Examples:
+			| time       | code   |
+			| 59         | 287082 |
+			| 1111111109 | 081804 |
+			| 1111111111 | 050471 |
+			| 1234567890 | 005924 |
+			| 2000000000 | 279037 |
+
+	Scenario: Accept a code from the previous period
+		When I use the TOTP secret "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+		Then the TOTP code "005924" should be valid at 1234567920
+
+	Scenario: Reject a code that was already used
+		When I use the TOTP secret "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+		And the last used TOTP step was 41152263
+		Then the TOTP code "005924" should not be valid at 1234567920
@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"crypto/rand"
+	"strings"
+)
+
+const (
+	// recoveryCodeCount is how many recovery codes a user gets at a time
+	recoveryCodeCount = 10
+
+	// recoveryCodeLength is the number of characters in a recovery code,
+	// ignoring formatting
+	recoveryCodeLength = 10
+
+	// recoveryCodeAlphabet is Crockford's base32 alphabet, which leaves out
+	// letters that are easy to misread. It has 32 characters so every random
+	// byte maps onto it evenly.
+	recoveryCodeAlphabet = "0123456789abcdefghjkmnpqrstvwxyz"
+)
+
+// GenerateRecoveryCodes returns a fresh set of recovery codes formatted for
+// display, such as "ab3de-fg7hj"
+func GenerateRecoveryCodes() ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+
+	for i := range codes {
+		b := make([]byte, recoveryCodeLength)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+
+		for j := range b {
+			b[j] = recoveryCodeAlphabet[int(b[j])%len(recoveryCodeAlphabet)]
+		}
+
+		half := recoveryCodeLength / 2
+		codes[i] = string(b[:half]) + "-" + string(b[half:])
+	}
+
+	return codes, nil
+}
+
+// NormalizeRecoveryCode strips the formatting from a recovery code as typed by
+// a user, leaving the form that is hashed and stored
+func NormalizeRecoveryCode(code string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(code))
+}
+
+// IsRecoveryCode reports whether a code is shaped like a recovery code rather
+// than a TOTP code
+func IsRecoveryCode(code string) bool {
+	return len(NormalizeRecoveryCode(code)) == recoveryCodeLength
+}
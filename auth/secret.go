@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// GenerateSecret returns a random, URL-safe secret for single-use links and
+// other bearer secrets that are stored hashed
+func GenerateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashSecret returns the hex SHA-256 hash under which a secret is stored.
+// Secrets are random, so a fast unsalted hash is enough to keep a database
+// leak from exposing usable values.
+func HashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// GeneratePersonalToken returns a new personal access token
+func GeneratePersonalToken() (string, error) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		return "", err
+	}
+
+	return PersonalTokenPrefix + secret, nil
+}
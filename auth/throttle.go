@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/timrourke/timrourke.com/model"
+	"github.com/timrourke/timrourke.com/storage"
+	"time"
+)
+
+// ErrLockedOut is returned when an account or client IP has failed to log in
+// too many times
+var ErrLockedOut = errors.New("Too many failed logins, try again later")
+
+const (
+	// DefaultMaxUserFailures is how many times in a row logging in to one
+	// account may fail before it is locked
+	DefaultMaxUserFailures = 5
+
+	// DefaultMaxClientFailures is how many times in a row logins from one
+	// client IP may fail, across all accounts, before it is locked
+	DefaultMaxClientFailures = 20
+
+	// DefaultFailureWindow is how long failures are remembered
+	DefaultFailureWindow = 15 * time.Minute
+
+	// DefaultBaseLockout is how long the first lockout lasts. Each lockout
+	// after it without a successful login lasts twice as long.
+	DefaultBaseLockout = time.Minute
+
+	// DefaultMaxLockout caps how long a single lockout lasts
+	DefaultMaxLockout = 24 * time.Hour
+)
+
+// Throttle locks out accounts and client IPs that fail to log in too often,
+// for exponentially longer each time
+type Throttle struct {
+	MaxUserFailures   int
+	MaxClientFailures int
+	FailureWindow     time.Duration
+	BaseLockout       time.Duration
+	MaxLockout        time.Duration
+
+	UserStorage          *storage.UserStorage
+	LoginThrottleStorage *storage.LoginThrottleStorage
+	LockoutEventStorage  *storage.LockoutEventStorage
+}
+
+// NewThrottle returns a new instance of Throttle with the default limits
+func NewThrottle(DB *sqlx.DB) *Throttle {
+	return &Throttle{
+		MaxUserFailures:      DefaultMaxUserFailures,
+		MaxClientFailures:    DefaultMaxClientFailures,
+		FailureWindow:        DefaultFailureWindow,
+		BaseLockout:          DefaultBaseLockout,
+		MaxLockout:           DefaultMaxLockout,
+		UserStorage:          storage.NewUserStorage(DB),
+		LoginThrottleStorage: storage.NewLoginThrottleStorage(DB),
+		LockoutEventStorage:  storage.NewLockoutEventStorage(DB),
+	}
+}
+
+// LockoutDuration returns how long to lock out for, given how many lockouts
+// came before this one
+func (t *Throttle) LockoutDuration(previousLockouts int) time.Duration {
+	duration := t.BaseLockout
+	for i := 0; i < previousLockouts; i++ {
+		duration *= 2
+		if duration >= t.MaxLockout {
+			return t.MaxLockout
+		}
+	}
+
+	return duration
+}
+
+// Check returns ErrLockedOut, and when the lockout ends, if either the user or
+// the client IP is locked out. The user is nil when no account matched.
+func (t *Throttle) Check(user *model.User, clientIP string, now time.Time) (time.Time, error) {
+	var lockedUntil time.Time
+
+	if user != nil && model.IsLocked(user.LockedUntil, now) {
+		lockedUntil = *user.LockedUntil
+	}
+
+	client, err := t.LoginThrottleStorage.Get(clientIP)
+	if err != nil {
+		return lockedUntil, err
+	}
+
+	if model.IsLocked(client.LockedUntil, now) && client.LockedUntil.After(lockedUntil) {
+		lockedUntil = *client.LockedUntil
+	}
+
+	if lockedUntil.IsZero() {
+		return lockedUntil, nil
+	}
+
+	return lockedUntil, ErrLockedOut
+}
+
+// Fail records a failed login, locking out the user or the client IP once
+// they reach their limit. The user is nil when no account matched.
+func (t *Throttle) Fail(user *model.User, clientIP string, now time.Time) error {
+	now = now.UTC()
+	windowStart := now.Add(-t.FailureWindow)
+
+	if user != nil {
+		failures, err := t.UserStorage.RecordFailedLogin(user, now, windowStart)
+		if err != nil {
+			return err
+		}
+
+		if failures >= t.MaxUserFailures {
+			until := now.Add(t.LockoutDuration(user.Lockouts))
+			if err = t.UserStorage.Lock(user, until); err != nil {
+				return err
+			}
+
+			userID := user.GetID()
+			err = t.LockoutEventStorage.Insert(model.LockoutEvent{
+				Event:       model.LockoutEventLocked,
+				UserId:      &userID,
+				ClientIP:    &clientIP,
+				LockedUntil: &until,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	failures, err := t.LoginThrottleStorage.RecordFailure(clientIP, now, windowStart)
+	if err != nil {
+		return err
+	}
+
+	if failures < t.MaxClientFailures {
+		return nil
+	}
+
+	client, err := t.LoginThrottleStorage.Get(clientIP)
+	if err != nil {
+		return err
+	}
+
+	until := now.Add(t.LockoutDuration(client.Lockouts))
+	if err = t.LoginThrottleStorage.Lock(clientIP, until); err != nil {
+		return err
+	}
+
+	return t.LockoutEventStorage.Insert(model.LockoutEvent{
+		Event:       model.LockoutEventLocked,
+		ClientIP:    &clientIP,
+		LockedUntil: &until,
+	})
+}
+
+// Succeed clears a user's failed logins once they log in. Failures from the
+// client IP are left to expire, so one valid account cannot be used to keep
+// guessing the passwords of others.
+func (t *Throttle) Succeed(user *model.User) error {
+	if user.FailedLogins == 0 && user.Lockouts == 0 && user.LockedUntil == nil {
+		return nil
+	}
+
+	return t.UserStorage.Unlock(user)
+}
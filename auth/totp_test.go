@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"fmt"
+	"github.com/DATA-DOG/godog"
+	"time"
+)
+
+var (
+	totpSecret   string
+	lastTOTPStep int64
+)
+
+func iUseTheTOTPSecret(secret string) error {
+	totpSecret = secret
+	lastTOTPStep = 0
+	return nil
+}
+
+func theLastUsedTOTPStepWas(step int64) error {
+	lastTOTPStep = step
+	return nil
+}
+
+func theTOTPCodeAtShouldBe(unix int64, expectedCode string) error {
+	code, err := TOTPCode(totpSecret, TOTPStep(time.Unix(unix, 0)))
+	if err != nil {
+		return err
+	}
+
+	if code == expectedCode {
+		return nil
+	}
+	return fmt.Errorf("expected TOTP code '%s' did not match actual '%s'",
+		expectedCode,
+		code)
+}
+
+func theTOTPCodeShouldBeValidAt(code string, unix int64) error {
+	if _, ok := ValidateTOTP(totpSecret, code, time.Unix(unix, 0), lastTOTPStep); ok {
+		return nil
+	}
+	return fmt.Errorf("expected TOTP code '%s' to be valid at %d", code, unix)
+}
+
+func theTOTPCodeShouldNotBeValidAt(code string, unix int64) error {
+	if _, ok := ValidateTOTP(totpSecret, code, time.Unix(unix, 0), lastTOTPStep); !ok {
+		return nil
+	}
+	return fmt.Errorf("expected TOTP code '%s' not to be valid at %d", code, unix)
+}
+
+func FeatureContext(s *godog.Suite) {
+	s.Step(`^I use the TOTP secret "([^"]*)"$`, iUseTheTOTPSecret)
+	s.Step(`^the last used TOTP step was (\d+)$`, theLastUsedTOTPStepWas)
+	s.Step(`^the TOTP code at (\d+) should be "([^"]*)"$`, theTOTPCodeAtShouldBe)
+	s.Step(`^the TOTP code "([^"]*)" should be valid at (\d+)$`, theTOTPCodeShouldBeValidAt)
+	s.Step(`^the TOTP code "([^"]*)" should not be valid at (\d+)$`, theTOTPCodeShouldNotBeValidAt)
+}
@@ -0,0 +1,135 @@
+// Package clientip finds the address of the client that sent a request.
+// Forwarded headers are easy to forge, so they are only believed when the
+// request comes through one of the proxies configured as trusted.
+package clientip
+
+import (
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net"
+	"net/http"
+	"strings"
+)
+
+type contextKey struct{}
+
+// Resolver finds client addresses, reading X-Forwarded-For only from its
+// trusted proxies. A nil Resolver trusts no proxies.
+type Resolver struct {
+	TrustedProxies []*net.IPNet
+}
+
+// NewResolver returns a resolver trusting the given proxies, each either an
+// IP address or a CIDR range
+func NewResolver(proxies []string) (*Resolver, error) {
+	var networks []*net.IPNet
+
+	for _, proxy := range proxies {
+		proxy = strings.TrimSpace(proxy)
+		if len(proxy) == 0 {
+			continue
+		}
+
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("%q is not an IP address or CIDR range", proxy)
+			}
+
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an IP address or CIDR range", proxy)
+		}
+		networks = append(networks, network)
+	}
+
+	return &Resolver{TrustedProxies: networks}, nil
+}
+
+// IP returns the address of the client that sent a request, or an empty
+// string when it cannot be told. The address of the connection is used,
+// unless it is a trusted proxy. Then X-Forwarded-For is read from the right,
+// past any more trusted proxies, to the first address one of them was sent
+// from. Only valid addresses are returned, and always in their canonical
+// form, so they fit the columns that store them.
+func (res *Resolver) IP(r *http.Request) string {
+	if r == nil {
+		return ""
+	}
+
+	remote := parseIP(r.RemoteAddr)
+	if remote == nil {
+		return ""
+	}
+
+	client := remote
+	if res.trusts(remote) {
+		hops := strings.Split(strings.Join(r.Header["X-Forwarded-For"], ","), ",")
+		for i := len(hops) - 1; i >= 0 && res.trusts(client); i-- {
+			hop := parseIP(hops[i])
+			if hop == nil {
+				break
+			}
+			client = hop
+		}
+	}
+
+	return client.String()
+}
+
+// Middleware resolves the client address of each request once, for
+// FromRequest to find
+func (res *Resolver) Middleware(c *gin.Context) {
+	ip := res.IP(c.Request)
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), contextKey{}, ip))
+	c.Next()
+}
+
+// FromRequest returns the client address Middleware resolved for a request.
+// Requests that did not pass through it are resolved trusting no proxies.
+func FromRequest(r *http.Request) string {
+	if r == nil {
+		return ""
+	}
+
+	if ip, ok := r.Context().Value(contextKey{}).(string); ok {
+		return ip
+	}
+
+	var res *Resolver
+	return res.IP(r)
+}
+
+// trusts reports whether an address is one of the trusted proxies
+func (res *Resolver) trusts(ip net.IP) bool {
+	if res == nil {
+		return false
+	}
+
+	for _, network := range res.TrustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseIP parses an address, with or without a port
+func parseIP(raw string) net.IP {
+	raw = strings.TrimSpace(raw)
+	if host, _, err := net.SplitHostPort(raw); err == nil {
+		raw = host
+	}
+
+	return net.ParseIP(strings.Trim(raw, "[]"))
+}
@@ -0,0 +1,58 @@
+package clientip
+
+import (
+	"fmt"
+	"github.com/DATA-DOG/godog"
+	"net/http"
+	"strings"
+)
+
+var (
+	resolver *Resolver
+	request  *http.Request
+)
+
+func iTrustNoProxies() error {
+	resolver = nil
+	return nil
+}
+
+func iTrustTheProxies(proxies string) error {
+	var err error
+	resolver, err = NewResolver(strings.Split(proxies, ","))
+	return err
+}
+
+func trustingTheProxiesShouldFail(proxies string) error {
+	if _, err := NewResolver(strings.Split(proxies, ",")); err == nil {
+		return fmt.Errorf("expected '%s' not to be accepted as proxies", proxies)
+	}
+	return nil
+}
+
+func aRequestArrivesFrom(remoteAddr string) error {
+	request = &http.Request{RemoteAddr: remoteAddr, Header: http.Header{}}
+	return nil
+}
+
+func aRequestArrivesFromForwardedFor(remoteAddr, forwarded string) error {
+	request = &http.Request{RemoteAddr: remoteAddr, Header: http.Header{}}
+	request.Header.Set("X-Forwarded-For", forwarded)
+	return nil
+}
+
+func theClientIPShouldBe(expected string) error {
+	if actual := resolver.IP(request); actual != expected {
+		return fmt.Errorf("expected client IP '%s', but it was '%s'", expected, actual)
+	}
+	return nil
+}
+
+func FeatureContext(s *godog.Suite) {
+	s.Step(`^I trust no proxies$`, iTrustNoProxies)
+	s.Step(`^I trust the proxies "([^"]*)"$`, iTrustTheProxies)
+	s.Step(`^trusting the proxies "([^"]*)" should fail$`, trustingTheProxiesShouldFail)
+	s.Step(`^a request arrives from "([^"]*)"$`, aRequestArrivesFrom)
+	s.Step(`^a request arrives from "([^"]*)" forwarded for "([^"]*)"$`, aRequestArrivesFromForwardedFor)
+	s.Step(`^the client IP should be "([^"]*)"$`, theClientIPShouldBe)
+}
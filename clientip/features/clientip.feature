@@ -0,0 +1,47 @@
+Feature: resolve client IPs
+	In order to throttle and audit the clients that really sent requests
+	As the developer of timrourke.com
+	I need to only believe forwarded headers from trusted proxies
+
+	Scenario: Use the address of the connection
+		When I trust no proxies
+		And a request arrives from "203.0.113.7:52100"
+		Then the client IP should be "203.0.113.7"
+
+	Scenario: Ignore forwarded headers from clients that are not proxies
+		When I trust no proxies
+		And a request arrives from "203.0.113.7:52100" forwarded for "198.51.100.1"
+		Then the client IP should be "203.0.113.7"
+
+	Scenario: Ignore forwarded headers from proxies that are not trusted
+		When I trust the proxies "10.0.0.1"
+		And a request arrives from "203.0.113.7:52100" forwarded for "198.51.100.1"
+		Then the client IP should be "203.0.113.7"
+
+	Scenario: Believe forwarded headers from a trusted proxy
+		When I trust the proxies "10.0.0.0/8"
+		And a request arrives from "10.0.0.1:52100" forwarded for "198.51.100.1"
+		Then the client IP should be "198.51.100.1"
+
+	Scenario: Skip addresses a client forged ahead of the trusted proxy
+		When I trust the proxies "10.0.0.0/8"
+		And a request arrives from "10.0.0.1:52100" forwarded for "192.0.2.99, 198.51.100.1"
+		Then the client IP should be "198.51.100.1"
+
+	Scenario: Read past a chain of trusted proxies
+		When I trust the proxies "10.0.0.1,10.0.0.2"
+		And a request arrives from "10.0.0.1:52100" forwarded for "198.51.100.1, 10.0.0.2"
+		Then the client IP should be "198.51.100.1"
+
+	Scenario: Fall back to the proxy when the forwarded address is not an IP
+		When I trust the proxies "10.0.0.1"
+		And a request arrives from "10.0.0.1:52100" forwarded for "not-an-ip-address-but-a-very-long-string-of-text"
+		Then the client IP should be "10.0.0.1"
+
+	Scenario: Resolve IPv6 addresses
+		When I trust the proxies "2001:db8::/32"
+		And a request arrives from "[2001:db8::1]:52100" forwarded for "2001:0db8:0000:0000:0000:0000:0000:0abc"
+		Then the client IP should be "2001:db8::abc"
+
+	Scenario: Reject proxies that are not addresses
+		Then trusting the proxies "proxy.example.com" should fail
@@ -0,0 +1,363 @@
+// Package delta renders the Quill Delta documents the admin's editor produces
+// to HTML
+package delta
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+)
+
+// ErrNotDocument is returned for deltas holding anything but inserts, which
+// describe a change to a document rather than a document
+var ErrNotDocument = errors.New("delta must be a document of inserts")
+
+// Delta is a Quill document: a list of inserts, each of text or of a single
+// embed, along with the formats applied to it. Newlines end lines, and the
+// formats on a newline apply to the whole line.
+type Delta struct {
+	Ops []Op `json:"ops"`
+}
+
+// Op is a single operation of a delta. Documents only hold inserts; Retain
+// and Delete are only read so that changes can be told apart from documents.
+type Op struct {
+	Insert     interface{}            `json:"insert,omitempty"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	Retain     *int                   `json:"retain,omitempty"`
+	Delete     *int                   `json:"delete,omitempty"`
+}
+
+// Parse reads a delta from JSON, checking that it is a document
+func Parse(source []byte) (*Delta, error) {
+	var d Delta
+	if err := json.Unmarshal(source, &d); err != nil {
+		return nil, err
+	}
+
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
+
+// Validate checks that every operation inserts either some text or a single
+// embed
+func (d Delta) Validate() error {
+	for _, op := range d.Ops {
+		if op.Retain != nil || op.Delete != nil {
+			return ErrNotDocument
+		}
+
+		switch insert := op.Insert.(type) {
+		case string:
+			if len(insert) == 0 {
+				return ErrNotDocument
+			}
+		case map[string]interface{}:
+			if len(insert) != 1 {
+				return ErrNotDocument
+			}
+		default:
+			return ErrNotDocument
+		}
+	}
+
+	return nil
+}
+
+// Scan satisfies the sql.Scanner interface
+func (d *Delta) Scan(src interface{}) error {
+	var value []byte
+
+	switch v := src.(type) {
+	case []byte:
+		value = v
+	case string:
+		value = []byte(v)
+	case nil:
+		*d = Delta{}
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into Delta", src)
+	}
+
+	return json.Unmarshal(value, d)
+}
+
+// Value satisfies the driver.Valuer interface
+func (d Delta) Value() (driver.Value, error) {
+	if d.Ops == nil {
+		d.Ops = []Op{}
+	}
+
+	value, err := json.Marshal(d)
+	return string(value), err
+}
+
+// HTML renders the document the way the editor shows it. Headers, paragraphs,
+// blockquotes, lists and code blocks are rendered from the formats on each
+// line, and images and videos from embeds. Unknown formats and embeds are
+// left out. The HTML is not sanitized, so links and embeds may point
+// anywhere; run it through a sanitize.Policy before serving it.
+func (d Delta) HTML() string {
+	r := renderer{}
+
+	for _, op := range d.Ops {
+		switch insert := op.Insert.(type) {
+		case string:
+			lines := strings.Split(insert, "\n")
+			for i, text := range lines {
+				if len(text) > 0 {
+					r.line.WriteString(inline(html.EscapeString(text), op.Attributes))
+					r.plain.WriteString(html.EscapeString(text))
+				}
+
+				if i < len(lines)-1 {
+					r.endLine(op.Attributes)
+				}
+			}
+		case map[string]interface{}:
+			r.embed(insert, op.Attributes)
+		}
+	}
+
+	// Documents end with a newline, but one missing it loses nothing
+	if r.line.Len() > 0 {
+		r.endLine(nil)
+	}
+	r.closeGroups()
+
+	return r.b.String()
+}
+
+// renderer builds HTML a line at a time. Consecutive list items share a
+// list, and consecutive lines of code share a block.
+type renderer struct {
+	b bytes.Buffer
+
+	// line holds the formatted content of the line being read, and plain
+	// the same content unformatted, for code blocks
+	line  bytes.Buffer
+	plain bytes.Buffer
+
+	// list is the tag of the open list, if any
+	list string
+
+	// code holds the lines of the open code block, in language if it has one
+	code     []string
+	codeOpen bool
+	language string
+}
+
+// endLine renders the line just read as the block its formats make it
+func (r *renderer) endLine(attrs map[string]interface{}) {
+	defer r.line.Reset()
+	defer r.plain.Reset()
+
+	if block, ok := attrs["code-block"]; ok && block != false {
+		language, _ := block.(string)
+		if language == "plain" {
+			language = ""
+		}
+
+		if r.codeOpen && r.language != language {
+			r.closeCode()
+		}
+		r.closeList()
+
+		r.code = append(r.code, r.plain.String())
+		r.codeOpen = true
+		r.language = language
+		return
+	}
+
+	content := r.line.String()
+	if len(content) == 0 {
+		content = "<br>"
+	}
+
+	if list, ok := attrs["list"].(string); ok {
+		tag := "ul"
+		if list == "ordered" {
+			tag = "ol"
+		}
+
+		r.closeCode()
+		if r.list != tag {
+			r.closeList()
+			r.b.WriteString("<" + tag + ">")
+			r.list = tag
+		}
+
+		r.b.WriteString("<li" + blockClass(attrs) + ">" + content + "</li>")
+		return
+	}
+
+	r.closeGroups()
+
+	tag := "p"
+	if level := number(attrs["header"]); level >= 1 && level <= 6 {
+		tag = fmt.Sprintf("h%d", level)
+	} else if attrs["blockquote"] == true {
+		tag = "blockquote"
+	}
+
+	r.b.WriteString("<" + tag + blockClass(attrs) + ">" + content + "</" + tag + ">")
+}
+
+// embed renders an embed. Images sit inside a line, while videos are blocks
+// of their own.
+func (r *renderer) embed(insert, attrs map[string]interface{}) {
+	if src, ok := insert["image"].(string); ok {
+		image := `<img src="` + html.EscapeString(src) + `"`
+		for _, name := range []string{"alt", "width", "height"} {
+			if value, ok := attrs[name].(string); ok {
+				image += " " + name + `="` + html.EscapeString(value) + `"`
+			}
+		}
+		image += ">"
+
+		if link, ok := attrs["link"].(string); ok {
+			image = `<a href="` + html.EscapeString(link) + `" target="_blank">` + image + "</a>"
+		}
+
+		r.line.WriteString(image)
+		return
+	}
+
+	if src, ok := insert["video"].(string); ok {
+		if r.line.Len() > 0 {
+			r.endLine(nil)
+		}
+		r.closeGroups()
+
+		r.b.WriteString(`<iframe class="ql-video" src="` + html.EscapeString(src) + `" frameborder="0" allowfullscreen="true"></iframe>`)
+	}
+}
+
+// closeGroups closes the open list or code block
+func (r *renderer) closeGroups() {
+	r.closeList()
+	r.closeCode()
+}
+
+func (r *renderer) closeList() {
+	if len(r.list) > 0 {
+		r.b.WriteString("</" + r.list + ">")
+		r.list = ""
+	}
+}
+
+// closeCode writes the open code block. Blocks in a language are marked up
+// the way fenced markdown code is; others the way the editor marks them.
+func (r *renderer) closeCode() {
+	if !r.codeOpen {
+		return
+	}
+
+	code := strings.Join(r.code, "\n")
+	if len(r.language) > 0 {
+		r.b.WriteString(`<pre><code class="language-` + html.EscapeString(r.language) + `">` + code + "\n</code></pre>")
+	} else {
+		r.b.WriteString(`<pre class="ql-syntax" spellcheck="false">` + code + "\n</pre>")
+	}
+
+	r.code = nil
+	r.codeOpen = false
+	r.language = ""
+}
+
+// inline wraps text in its inline formats, nested the way the editor nests
+// them
+func inline(content string, attrs map[string]interface{}) string {
+	var styles []string
+	if color, ok := attrs["color"].(string); ok {
+		styles = append(styles, "color: "+color)
+	}
+	if background, ok := attrs["background"].(string); ok {
+		styles = append(styles, "background-color: "+background)
+	}
+	if len(styles) > 0 {
+		content = `<span style="` + html.EscapeString(strings.Join(styles, "; ")) + `">` + content + "</span>"
+	}
+
+	if attrs["underline"] == true {
+		content = "<u>" + content + "</u>"
+	}
+	if attrs["strike"] == true {
+		content = "<s>" + content + "</s>"
+	}
+	if attrs["italic"] == true {
+		content = "<em>" + content + "</em>"
+	}
+	if attrs["bold"] == true {
+		content = "<strong>" + content + "</strong>"
+	}
+
+	switch attrs["script"] {
+	case "sub":
+		content = "<sub>" + content + "</sub>"
+	case "super":
+		content = "<sup>" + content + "</sup>"
+	}
+
+	if link, ok := attrs["link"].(string); ok {
+		content = `<a href="` + html.EscapeString(link) + `" target="_blank">` + content + "</a>"
+	}
+
+	if attrs["code"] == true {
+		content = "<code>" + content + "</code>"
+	}
+
+	return content
+}
+
+// blockClass returns the class attribute for a line's alignment, indent and
+// direction, or nothing when it has none
+func blockClass(attrs map[string]interface{}) string {
+	var classes []string
+
+	switch align := attrs["align"]; align {
+	case "center", "right", "justify":
+		classes = append(classes, "ql-align-"+align.(string))
+	}
+
+	if indent := number(attrs["indent"]); indent >= 1 && indent <= 8 {
+		classes = append(classes, fmt.Sprintf("ql-indent-%d", indent))
+	}
+
+	if attrs["direction"] == "rtl" {
+		classes = append(classes, "ql-direction-rtl")
+	}
+
+	if len(classes) == 0 {
+		return ""
+	}
+
+	return ` class="` + strings.Join(classes, " ") + `"`
+}
+
+// number reads a format's value as a whole number, returning 0 when it is not
+// one. JSON numbers are read as floats, but the editor sometimes sends
+// numbers as strings.
+func number(value interface{}) int {
+	switch v := value.(type) {
+	case float64:
+		if v == float64(int(v)) {
+			return int(v)
+		}
+	case string:
+		var n int
+		if _, err := fmt.Sscanf(v, "%d", &n); err == nil && fmt.Sprint(n) == v {
+			return n
+		}
+	}
+
+	return 0
+}
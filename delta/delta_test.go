@@ -0,0 +1,76 @@
+package delta
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/DATA-DOG/godog"
+	"github.com/DATA-DOG/godog/gherkin"
+	"reflect"
+	"strings"
+)
+
+var (
+	source   string
+	parsed   *Delta
+	parseErr error
+)
+
+func iRenderTheDelta(document *gherkin.DocString) error {
+	source = document.Content
+	parsed, parseErr = Parse([]byte(source))
+	return nil
+}
+
+func theHTMLShouldBe(expected *gherkin.DocString) error {
+	if parseErr != nil {
+		return parseErr
+	}
+
+	rendered := parsed.HTML()
+	if strings.TrimSpace(rendered) == strings.TrimSpace(expected.Content) {
+		return nil
+	}
+	return fmt.Errorf("expected HTML '%s', but it was '%s'",
+		expected.Content,
+		rendered)
+}
+
+func theDeltaShouldSurviveARoundTrip() error {
+	if parseErr != nil {
+		return parseErr
+	}
+
+	stored, err := parsed.Value()
+	if err != nil {
+		return err
+	}
+
+	var loaded Delta
+	if err := loaded.Scan(stored); err != nil {
+		return err
+	}
+
+	var before, after interface{}
+	json.Unmarshal([]byte(source), &before)
+	reloaded, _ := json.Marshal(loaded)
+	json.Unmarshal(reloaded, &after)
+
+	if !reflect.DeepEqual(before, after) {
+		return fmt.Errorf("expected delta '%s', but it was '%s'", source, reloaded)
+	}
+	return nil
+}
+
+func theDeltaShouldBeRejected() error {
+	if parseErr == nil {
+		return fmt.Errorf("expected the delta to be rejected")
+	}
+	return nil
+}
+
+func FeatureContext(s *godog.Suite) {
+	s.Step(`^I render the delta:$`, iRenderTheDelta)
+	s.Step(`^the HTML should be:$`, theHTMLShouldBe)
+	s.Step(`^the delta should survive a round trip$`, theDeltaShouldSurviveARoundTrip)
+	s.Step(`^the delta should be rejected$`, theDeltaShouldBeRejected)
+}
@@ -0,0 +1,70 @@
+Feature: render Quill deltas
+	In order to keep the editor's document as the source of truth
+	As an author on timrourke.com
+	I need Quill deltas stored as they are and rendered to HTML on the server
+
+	Scenario: Render paragraphs and inline formats
+		When I render the delta:
+			"""
+			{"ops":[{"insert":"Some "},{"insert":"bold","attributes":{"bold":true}},{"insert":", "},{"insert":"italic","attributes":{"italic":true}},{"insert":", "},{"insert":"underlined","attributes":{"underline":true}},{"insert":", "},{"insert":"struck","attributes":{"strike":true}},{"insert":", "},{"insert":"code","attributes":{"code":true}},{"insert":", "},{"insert":"red","attributes":{"color":"#e60000"}},{"insert":" and H"},{"insert":"2","attributes":{"script":"sub"}},{"insert":"O & <tags>\n\n"}]}
+			"""
+		Then the HTML should be:
+			"""
+			<p>Some <strong>bold</strong>, <em>italic</em>, <u>underlined</u>, <s>struck</s>, <code>code</code>, <span style="color: #e60000">red</span> and H<sub>2</sub>O &amp; &lt;tags&gt;</p><p><br></p>
+			"""
+		And the delta should survive a round trip
+
+	Scenario: Render headers, blockquotes and alignment
+		When I render the delta:
+			"""
+			{"ops":[{"insert":"Title"},{"insert":"\n","attributes":{"header":1}},{"insert":"Subtitle"},{"insert":"\n","attributes":{"header":2,"align":"center"}},{"insert":"Quoted"},{"insert":"\n","attributes":{"blockquote":true}}]}
+			"""
+		Then the HTML should be:
+			"""
+			<h1>Title</h1><h2 class="ql-align-center">Subtitle</h2><blockquote>Quoted</blockquote>
+			"""
+		And the delta should survive a round trip
+
+	Scenario: Render ordered, bullet and indented lists
+		When I render the delta:
+			"""
+			{"ops":[{"insert":"one"},{"insert":"\n","attributes":{"list":"ordered"}},{"insert":"two"},{"insert":"\n","attributes":{"list":"ordered"}},{"insert":"inner"},{"insert":"\n","attributes":{"list":"ordered","indent":1}},{"insert":"dot"},{"insert":"\n","attributes":{"list":"bullet"}},{"insert":"after\n"}]}
+			"""
+		Then the HTML should be:
+			"""
+			<ol><li>one</li><li>two</li><li class="ql-indent-1">inner</li></ol><ul><li>dot</li></ul><p>after</p>
+			"""
+		And the delta should survive a round trip
+
+	Scenario: Render code blocks
+		When I render the delta:
+			"""
+			{"ops":[{"insert":"if a < b {"},{"insert":"\n","attributes":{"code-block":true}},{"insert":"\treturn"},{"insert":"\n","attributes":{"code-block":true}},{"insert":"}"},{"insert":"\n","attributes":{"code-block":true}},{"insert":"SELECT 1;"},{"insert":"\n","attributes":{"code-block":"sql"}}]}
+			"""
+		Then the HTML should be:
+			"""
+			<pre class="ql-syntax" spellcheck="false">if a &lt; b {
+				return
+			}
+			</pre><pre><code class="language-sql">SELECT 1;
+			</code></pre>
+			"""
+		And the delta should survive a round trip
+
+	Scenario: Render links, images and video embeds
+		When I render the delta:
+			"""
+			{"ops":[{"insert":"See "},{"insert":"this","attributes":{"link":"https://example.com/?a=1&b=2"}},{"insert":" "},{"insert":{"image":"https://example.com/cat.png"},"attributes":{"alt":"A cat"}},{"insert":"\n"},{"insert":{"video":"https://www.youtube.com/embed/abc"}},{"insert":{"formula":"e=mc^2"}},{"insert":"\n"}]}
+			"""
+		Then the HTML should be:
+			"""
+			<p>See <a href="https://example.com/?a=1&amp;b=2" target="_blank">this</a> <img src="https://example.com/cat.png" alt="A cat"></p><iframe class="ql-video" src="https://www.youtube.com/embed/abc" frameborder="0" allowfullscreen="true"></iframe><p><br></p>
+			"""
+		And the delta should survive a round trip
+
+	Scenario: Reject changes that are not documents
+		When I render the delta:
+			"""
+			{"ops":[{"retain":3},{"insert":"x"}]}
+			"""
+		Then the delta should be rejected
@@ -0,0 +1,152 @@
+// Package diff compares two versions of a piece of text, such as a post's
+// content before and after an edit
+package diff
+
+import (
+	"unicode"
+)
+
+const (
+	// OpEqual marks text both versions share
+	OpEqual = "equal"
+
+	// OpInsert marks text only the newer version has
+	OpInsert = "insert"
+
+	// OpDelete marks text only the older version has
+	OpDelete = "delete"
+)
+
+// maxCells caps the size of the table used to compare the parts of two texts
+// that differ. Anything larger is reported as deleted and inserted wholesale
+// rather than letting one huge edit use up the server's memory.
+const maxCells = 4000000
+
+// Chunk is a run of text that was kept, inserted or deleted
+type Chunk struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// Words compares two texts word by word, treating HTML tags and runs of
+// whitespace as words of their own, and returns the chunks that turn a into b
+func Words(a, b string) []Chunk {
+	return compare(tokenize(a), tokenize(b))
+}
+
+// compare finds the longest common subsequence of two lists of tokens and
+// returns the edits around it
+func compare(a, b []string) []Chunk {
+	chunks := []Chunk{}
+
+	// Edits tend to be small, so trim what the texts share at either end
+	// before building the table
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	chunks = appendTokens(chunks, OpEqual, a[:prefix])
+
+	middleA := a[prefix : len(a)-suffix]
+	middleB := b[prefix : len(b)-suffix]
+
+	if (len(middleA)+1)*(len(middleB)+1) > maxCells {
+		chunks = appendTokens(chunks, OpDelete, middleA)
+		chunks = appendTokens(chunks, OpInsert, middleB)
+	} else {
+		chunks = appendMiddle(chunks, middleA, middleB)
+	}
+
+	return appendTokens(chunks, OpEqual, a[len(a)-suffix:])
+}
+
+// appendMiddle appends the edits between two lists of tokens that share
+// neither their first nor their last token
+func appendMiddle(chunks []Chunk, a, b []string) []Chunk {
+	// lengths[i][j] holds the length of the longest common subsequence of
+	// a[i:] and b[j:]
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else if lengths[i+1][j] >= lengths[i][j+1] {
+				lengths[i][j] = lengths[i+1][j]
+			} else {
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if a[i] == b[j] {
+			chunks = appendTokens(chunks, OpEqual, a[i:i+1])
+			i++
+			j++
+		} else if lengths[i+1][j] >= lengths[i][j+1] {
+			chunks = appendTokens(chunks, OpDelete, a[i:i+1])
+			i++
+		} else {
+			chunks = appendTokens(chunks, OpInsert, b[j:j+1])
+			j++
+		}
+	}
+
+	chunks = appendTokens(chunks, OpDelete, a[i:])
+	return appendTokens(chunks, OpInsert, b[j:])
+}
+
+// appendTokens appends tokens to the chunks, merging them into the last chunk
+// when it has the same op
+func appendTokens(chunks []Chunk, op string, tokens []string) []Chunk {
+	for _, token := range tokens {
+		if last := len(chunks) - 1; last >= 0 && chunks[last].Op == op {
+			chunks[last].Text += token
+		} else {
+			chunks = append(chunks, Chunk{Op: op, Text: token})
+		}
+	}
+
+	return chunks
+}
+
+// tokenize splits text into HTML tags, runs of whitespace and words
+func tokenize(text string) []string {
+	tokens := []string{}
+	runes := []rune(text)
+
+	for start := 0; start < len(runes); {
+		end := start + 1
+
+		switch {
+		case runes[start] == '<':
+			for end < len(runes) && runes[end-1] != '>' {
+				end++
+			}
+		case unicode.IsSpace(runes[start]):
+			for end < len(runes) && unicode.IsSpace(runes[end]) {
+				end++
+			}
+		default:
+			for end < len(runes) && runes[end] != '<' && !unicode.IsSpace(runes[end]) {
+				end++
+			}
+		}
+
+		tokens = append(tokens, string(runes[start:end]))
+		start = end
+	}
+
+	return tokens
+}
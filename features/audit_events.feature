@@ -0,0 +1,78 @@
+Feature: audit log
+	In order to find out who changed a post
+	As an admin of timrourke.com
+	I need every write through the API to be recorded
+
+	Background:
+		Given there are users:
+			| id | username | email               | created_at           | updated_at           | password_hash | role   |
+			| 1  | admin1   | admin1@example.com  | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | fakehash      | admin  |
+			| 2  | author1  | author1@example.com | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | fakehash      | author |
+		And there are posts:
+			| id | title       | excerpt | content       | permalink   | user_id | created_at           | updated_at           |
+			| 1  | First post  | First   | Original text | first-post  | 2       | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z |
+
+	Scenario: should record who changed a post and how
+		Given I am authenticated as user "2"
+		When I send "PATCH" request to "/api/posts/1" with body:
+			"""
+			{
+				"data": {
+					"type": "posts",
+					"id": "1",
+					"attributes": {
+						"version": 1,
+						"content": "Changed text"
+					}
+				}
+			}
+			"""
+		Then the response code should be 204
+		Given I am authenticated as user "1"
+		When I send "GET" request to "/api/audit-events?filter[resource-type]=posts&filter[resource-id]=1"
+		Then the response code should be 200
+		And the response should contain text "Original text"
+		And the response should contain text "Changed text"
+		And the response should contain text "192.0.2.1"
+		And the response should not contain text "First post"
+
+	Scenario: should record the address the request came from, not a forged one
+		Given I am authenticated as user "2"
+		And I set the "X-Forwarded-For" header to "198.51.100.7"
+		When I send "PATCH" request to "/api/posts/1" with body:
+			"""
+			{
+				"data": {
+					"type": "posts",
+					"id": "1",
+					"attributes": {
+						"version": 1,
+						"content": "Changed text"
+					}
+				}
+			}
+			"""
+		Then the response code should be 204
+		Given I am authenticated as user "1"
+		When I send "GET" request to "/api/audit-events?filter[resource-type]=posts&filter[resource-id]=1"
+		Then the response code should be 200
+		And the response should contain text "192.0.2.1"
+		And the response should not contain text "198.51.100.7"
+
+	Scenario: should redact password hashes
+		Given there are users:
+			| id | username | email               | created_at           | updated_at           | password_hash | role   |
+			| 3  | author3  | author3@example.com | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | fakehash      | author |
+		And I am authenticated as user "1"
+		When I send "DELETE" request to "/api/users/3"
+		Then the response code should be 204
+		When I send "GET" request to "/api/audit-events?filter[action]=delete"
+		Then the response code should be 200
+		And the response should contain text "[redacted]"
+		And the response should not contain text "fakehash"
+
+	Scenario: only admins may read the audit log
+		Given I am authenticated as user "2"
+		When I send "GET" request to "/api/audit-events"
+		Then the response code should be 403
+
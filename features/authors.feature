@@ -0,0 +1,28 @@
+Feature: author pages
+	In order to know who wrote a post
+	As a reader of timrourke.com
+	I need a public page for each author listing their posts
+
+	Background:
+		Given there are users:
+			| id | username | email               | created_at           | updated_at           | password_hash | role   |
+			| 1  | author1  | author1@example.com | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | fakehash      | author |
+			| 2  | author2  | author2@example.com | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | fakehash      | author |
+		And there are posts:
+			| id | title        | excerpt       | content | permalink    | user_id | status    | published_at         | created_at           | updated_at           |
+			| 1  | First post   | About Go      | Go      | first-post   | 1       | published | 2016-02-07T03:27:16Z | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z |
+			| 2  | Someone else | Not by them   | Other   | someone-else | 2       | published | 2016-02-07T03:27:16Z | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z |
+			| 3  | Unfinished   | Still a draft | Draft   | unfinished   | 1       | draft     | 2016-02-07T03:27:16Z | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z |
+
+	Scenario: should list an author's posts
+		When I send "GET" request to "/authors/author1"
+		Then the response code should be 200
+		And the response should contain text "author1"
+		And the response should contain text "First post"
+		And the response should not contain text "Someone else"
+		And the response should not contain text "Unfinished"
+		And the response should not contain text "author1@example.com"
+
+	Scenario: should not find a missing author
+		When I send "GET" request to "/authors/nobody"
+		Then the response code should be 404
@@ -0,0 +1,107 @@
+Feature: categories
+	In order to file posts under nested subjects
+	As an editor on timrourke.com
+	I need to arrange categories and list the posts beneath them
+
+	Background:
+		Given there are users:
+			| id | username | email               | created_at           | updated_at           | password_hash | role   |
+			| 1  | author1  | author1@example.com | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | fakehash      | author |
+			| 2  | editor1  | editor1@example.com | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | fakehash      | editor |
+		And there are categories:
+			| id | name        | slug        | parent_id | path     |
+			| 1  | Programming | programming |           | /1/      |
+			| 2  | Go          | go          | 1         | /1/2/    |
+			| 3  | Concurrency | concurrency | 2         | /1/2/3/  |
+			| 4  | Cooking     | cooking     |           | /4/      |
+		And there are posts:
+			| id | title      | excerpt | content | permalink  | user_id | category_id | status    | published_at         | created_at           | updated_at           |
+			| 1  | Channels   | a       | a       | channels   | 1       | 3           | published | 2016-02-07T03:27:16Z | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z |
+			| 2  | Paradigms  | b       | b       | paradigms  | 1       | 1           | published | 2016-02-07T03:27:16Z | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z |
+			| 3  | Bread      | c       | c       | bread      | 1       | 4           | published | 2016-02-07T03:27:16Z | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z |
+			| 4  | Goroutines | d       | d       | goroutines | 1       | 2           | published | 2016-02-07T03:27:16Z | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z |
+
+	Scenario: should list posts from a category and its descendants
+		When I send "GET" request to "/api/categories/1/posts"
+		Then the response code should be 200
+		And the response should contain text "Channels"
+		And the response should contain text "Paradigms"
+		And the response should contain text "Goroutines"
+		And the response should not contain text "Bread"
+
+	Scenario: should filter posts by category slug
+		When I send "GET" request to "/api/posts?filter[category]=go"
+		Then the response code should be 200
+		And the response should contain text "Channels"
+		And the response should contain text "Goroutines"
+		And the response should not contain text "Paradigms"
+
+	Scenario: should move a category along with its descendants
+		Given I am authenticated as user "2"
+		When I send "PATCH" request to "/api/categories/2" with body:
+			"""
+			{
+				"data": {
+					"type": "categories",
+					"id": "2",
+					"relationships": {
+						"parent": {
+							"data": {"type": "categories", "id": "4"}
+						}
+					}
+				}
+			}
+			"""
+		Then the response code should be 204
+		When I send "GET" request to "/api/categories/3"
+		Then the response should contain text "/4/2/3/"
+		When I send "GET" request to "/api/categories/4/posts"
+		Then the response should contain text "Channels"
+		And the response should contain text "Bread"
+		And the response should not contain text "Paradigms"
+
+	Scenario: should not move a category beneath its own descendant
+		Given I am authenticated as user "2"
+		When I send "PATCH" request to "/api/categories/1" with body:
+			"""
+			{
+				"data": {
+					"type": "categories",
+					"id": "1",
+					"relationships": {
+						"parent": {
+							"data": {"type": "categories", "id": "3"}
+						}
+					}
+				}
+			}
+			"""
+		Then the response code should be 422
+		And the response should contain text "/data/relationships/parent"
+
+	Scenario: should move children and posts up when deleting a category
+		Given I am authenticated as user "2"
+		When I send "DELETE" request to "/api/categories/2"
+		Then the response code should be 204
+		When I send "GET" request to "/api/categories/3"
+		Then the response should contain text "/1/3/"
+		When I send "GET" request to "/api/categories/1/children"
+		Then the response should contain text "Concurrency"
+		When I send "GET" request to "/api/categories/1/posts"
+		Then the response should contain text "Goroutines"
+		And the response should contain text "Channels"
+
+	Scenario: only editors may change categories
+		Given I am authenticated as user "1"
+		When I send "POST" request to "/api/categories" with body:
+			"""
+			{
+				"data": {
+					"type": "categories",
+					"attributes": {
+						"name": "Gardening"
+					}
+				}
+			}
+			"""
+		Then the response code should be 403
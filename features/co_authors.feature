@@ -0,0 +1,119 @@
+Feature: co-authors
+	In order to credit everyone who wrote a post
+	As an author on timrourke.com
+	I need to share a post's byline and its editing rights with other authors
+
+	Background:
+		Given there are users:
+			| id | username | email               | created_at           | updated_at           | password_hash | role   |
+			| 1  | author1  | author1@example.com | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | fakehash      | author |
+			| 2  | author2  | author2@example.com | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | fakehash      | author |
+			| 3  | author3  | author3@example.com | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | fakehash      | author |
+		And there are posts:
+			| id | title    | excerpt | content | permalink | user_id | status    | published_at         | created_at           | updated_at           |
+			| 1  | Together | Joint   | Joint   | together  | 1       | published | 2016-02-07T03:27:16Z | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z |
+			| 2  | Alone    | Solo    | Solo    | alone     | 3       | published | 2016-02-07T03:27:16Z | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z |
+		And the posts have authors:
+			| post_id | user_id | position |
+			| 1       | 1       | 1        |
+			| 1       | 2       | 2        |
+
+	Scenario: should list a post's authors in byline order
+		When I send "GET" request to "/api/posts/1/authors"
+		Then the response code should be 200
+		And the response should contain text "author1"
+		And the response should contain text "author2"
+		And the response should not contain text "author3"
+
+	Scenario: should filter posts by any of their authors
+		When I send "GET" request to "/api/posts?filter[author]=author2"
+		Then the response code should be 200
+		And the response should contain text "Together"
+		And the response should not contain text "Alone"
+		When I send "GET" request to "/api/posts?filter[author]=3"
+		Then the response should contain text "Alone"
+		And the response should not contain text "Together"
+
+	Scenario: should let a co-author change the post
+		Given I am authenticated as user "2"
+		When I send "PATCH" request to "/api/posts/1" with body:
+			"""
+			{
+				"data": {
+					"type": "posts",
+					"id": "1",
+					"attributes": {
+						"title": "Together Again",
+						"version": 1
+					}
+				}
+			}
+			"""
+		Then the response code should be 204
+
+	Scenario: should not let other authors change the post
+		Given I am authenticated as user "3"
+		When I send "PATCH" request to "/api/posts/1" with body:
+			"""
+			{
+				"data": {
+					"type": "posts",
+					"id": "1",
+					"attributes": {
+						"title": "Taken Over",
+						"version": 1
+					}
+				}
+			}
+			"""
+		Then the response code should be 403
+
+	Scenario: should add a co-author and keep the owner on the byline
+		Given I am authenticated as user "3"
+		When I send "PATCH" request to "/api/posts/2" with body:
+			"""
+			{
+				"data": {
+					"type": "posts",
+					"id": "2",
+					"attributes": {
+						"version": 1
+					},
+					"relationships": {
+						"authors": {
+							"data": [
+								{"type": "users", "id": "1"}
+							]
+						}
+					}
+				}
+			}
+			"""
+		Then the response code should be 204
+		When I send "GET" request to "/api/posts?filter[author]=author1"
+		Then the response should contain text "Alone"
+		When I send "GET" request to "/api/posts/2/authors"
+		Then the response should contain text "author3"
+
+	Scenario: should reject authors that do not exist
+		Given I am authenticated as user "1"
+		When I send "PATCH" request to "/api/posts/1" with body:
+			"""
+			{
+				"data": {
+					"type": "posts",
+					"id": "1",
+					"attributes": {
+						"version": 1
+					},
+					"relationships": {
+						"authors": {
+							"data": [
+								{"type": "users", "id": "99"}
+							]
+						}
+					}
+				}
+			}
+			"""
+		Then the response code should be 422
@@ -0,0 +1,78 @@
+Feature: delta content
+	In order to keep what the editor wrote as the source of truth
+	As an author on timrourke.com
+	I need posts to store the editor's Quill delta and render their HTML from it
+
+	Background:
+		Given there are users:
+			| id | username | email               | created_at           | updated_at           | password_hash | role   |
+			| 1  | author1  | author1@example.com | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | fakehash      | author |
+		And I am authenticated as user "1"
+
+	Scenario: should render HTML from the delta and keep the delta
+		When I send "POST" request to "/api/posts" with body:
+			"""
+			{
+				"data": {
+					"type": "posts",
+					"attributes": {
+						"title": "Written in the editor",
+						"content": "<p>Ignored</p>",
+						"content-format": "delta",
+						"content-delta": {
+							"ops": [
+								{"insert": "Hello"},
+								{"insert": "\n", "attributes": {"header": 1}},
+								{"insert": "Some "},
+								{"insert": "bold", "attributes": {"bold": true}},
+								{"insert": " words\n"},
+								{"insert": {"video": "https://evil.example.com/embed"}},
+								{"insert": "\n"}
+							]
+						},
+						"status": "published"
+					}
+				}
+			}
+			"""
+		Then the response code should be 201
+		When I send "GET" request to "/api/posts/1"
+		Then the response code should be 200
+		And the response should contain text "content-delta"
+		And the response should contain text "header"
+		When I send "GET" request to "/posts/written-in-the-editor"
+		Then the response code should be 200
+		And the response should contain text "<h1>Hello</h1><p>Some <strong>bold</strong> words</p>"
+		And the response should not contain text "Ignored"
+		And the response should not contain text "evil.example.com"
+
+	Scenario: should require a delta for delta content
+		When I send "POST" request to "/api/posts" with body:
+			"""
+			{
+				"data": {
+					"type": "posts",
+					"attributes": {
+						"title": "Missing its delta",
+						"content-format": "delta"
+					}
+				}
+			}
+			"""
+		Then the response code should be 422
+
+	Scenario: should reject deltas that are not documents
+		When I send "POST" request to "/api/posts" with body:
+			"""
+			{
+				"data": {
+					"type": "posts",
+					"attributes": {
+						"title": "A change",
+						"content-format": "delta",
+						"content-delta": {"ops": [{"retain": 3}, {"insert": "x"}]}
+					}
+				}
+			}
+			"""
+		Then the response code should be 422
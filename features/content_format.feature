@@ -0,0 +1,99 @@
+Feature: content formats
+	In order to write posts without the rich text editor
+	As an author on timrourke.com
+	I need posts written in markdown to be rendered to HTML on the server
+
+	Background:
+		Given there are users:
+			| id | username | email               | created_at           | updated_at           | password_hash | role   |
+			| 1  | author1  | author1@example.com | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | fakehash      | author |
+		And there are posts:
+			| id | title      | excerpt | content        | permalink  | user_id | status    | published_at         | created_at           | updated_at           |
+			| 1  | First post | Short   | <p>Welcome</p> | first-post | 1       | published | 2016-02-07T03:27:16Z | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z |
+		And I am authenticated as user "1"
+
+	Scenario: should render markdown posts to HTML
+		When I send "POST" request to "/api/posts" with body:
+			"""
+			{
+				"data": {
+					"type": "posts",
+					"attributes": {
+						"title": "Written in markdown",
+						"excerpt": "Markdown",
+						"content": "Some **bold** words",
+						"content-format": "markdown",
+						"status": "published"
+					}
+				}
+			}
+			"""
+		Then the response code should be 201
+		And the response should contain text "content-html"
+		When I send "GET" request to "/posts/written-in-markdown"
+		Then the response code should be 200
+		And the response should contain text "<p>Some <strong>bold</strong> words</p>"
+
+	Scenario: should default to HTML content
+		When I send "GET" request to "/api/posts/1"
+		Then the response code should be 200
+		And the response should contain text "content-format"
+		When I send "GET" request to "/posts/first-post"
+		Then the response should contain text "<p>Welcome</p>"
+
+	Scenario: should render again when the format changes
+		When I send "PATCH" request to "/api/posts/1" with body:
+			"""
+			{
+				"data": {
+					"type": "posts",
+					"id": "1",
+					"attributes": {
+						"content": "# Welcome",
+						"content-format": "markdown",
+						"version": 1
+					}
+				}
+			}
+			"""
+		Then the response code should be 204
+		When I send "GET" request to "/posts/first-post"
+		Then the response should contain text "<h1>Welcome</h1>"
+
+	Scenario: should restore a revision in the format it was written in
+		When I send "PATCH" request to "/api/posts/1" with body:
+			"""
+			{
+				"data": {
+					"type": "posts",
+					"id": "1",
+					"attributes": {
+						"content": "# Welcome",
+						"content-format": "markdown",
+						"version": 1
+					}
+				}
+			}
+			"""
+		Then the response code should be 204
+		When I send "POST" request to "/api/posts/1/revisions/1/restore"
+		Then the response code should be 200
+		When I send "GET" request to "/posts/first-post"
+		Then the response should contain text "<p>Welcome</p>"
+		And the response should not contain text "&lt;p&gt;"
+
+	Scenario: should reject unknown formats
+		When I send "POST" request to "/api/posts" with body:
+			"""
+			{
+				"data": {
+					"type": "posts",
+					"attributes": {
+						"title": "Written in wiki text",
+						"content": "'''bold'''",
+						"content-format": "wiki"
+					}
+				}
+			}
+			"""
+		Then the response code should be 422
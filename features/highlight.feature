@@ -0,0 +1,37 @@
+Feature: highlight code in posts
+	In order to read code in posts without waiting on scripts
+	As a reader of timrourke.com
+	I need code blocks highlighted on the server and styled by a stylesheet
+
+	Background:
+		Given there are users:
+			| id | username | email               | created_at           | updated_at           | password_hash | role   |
+			| 1  | author1  | author1@example.com | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | fakehash      | author |
+		And I am authenticated as user "1"
+
+	Scenario: should highlight code blocks when posts are saved
+		When I send "POST" request to "/api/posts" with body:
+			"""
+			{
+				"data": {
+					"type": "posts",
+					"attributes": {
+						"title": "Some Go",
+						"content": "```go\nfunc main() {}\n```",
+						"content-format": "markdown",
+						"status": "published"
+					}
+				}
+			}
+			"""
+		Then the response code should be 201
+		When I send "GET" request to "/posts/some-go"
+		Then the response code should be 200
+		And the response should contain text "hl-keyword"
+		And the response should contain text "/css/highlight.css"
+
+	Scenario: should serve the stylesheet for highlighted code
+		When I send "GET" request to "/css/highlight.css"
+		Then the response code should be 200
+		And the response header "Content-Type" should be "text/css; charset=utf-8"
+		And the response should contain text ".hl-keyword {"
@@ -0,0 +1,177 @@
+Feature: login endpoint
+	In order to change content on the site
+	As an author on timrourke.com
+	I need to be able to log in and use an access token
+
+	Background:
+		Given there are users:
+			| id | username  | email                 | created_at           | updated_at           | password_hash                                                |
+			| 1  | testuser1 | testuser1@example.com | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | $2a$10$JJTZNZZMoBVWMr0n.4bUcuBvqW7xZdi2QSax5sQoFdvg0p2WTEvCq |
+
+	Scenario: should issue a token for valid credentials
+		When I send "POST" request to "/api/login" with body:
+			"""
+			{
+				"username": "testuser1",
+				"password": "correct horse battery staple"
+			}
+			"""
+		Then the response code should be 200
+		And the response should contain text "access_token"
+		And the response should contain text "user_id"
+
+	Scenario: should reject an invalid password
+		When I send "POST" request to "/api/login" with body:
+			"""
+			{
+				"username": "testuser1",
+				"password": "not my password"
+			}
+			"""
+		Then the response code should be 401
+		And the response should match json:
+			"""
+			{
+				"errors": [
+					{
+						"status": "401",
+						"title": "Invalid username or password"
+					}
+				]
+			}
+			"""
+
+	Scenario: should reject writes without a token
+		When I send "DELETE" request to "/api/users/1"
+		Then the response code should be 401
+
+	Scenario: should accept writes with a token
+		Given I am authenticated as user "1"
+		When I send "PATCH" request to "/api/users/1" with body:
+			"""
+			{
+				"data": {
+					"type": "users",
+					"id": "1",
+					"attributes": {
+						"version": 1,
+						"username": "renameduser1"
+					}
+				}
+			}
+			"""
+		Then the response code should be 204
+
+	Scenario: should lock an account out after repeated failures
+		Given I fail to log in as "testuser1" 5 times
+		When I send "POST" request to "/api/login" with body:
+			"""
+			{
+				"username": "testuser1",
+				"password": "correct horse battery staple"
+			}
+			"""
+		Then the response code should be 429
+		And the response should contain text "Too many failed logins, try again later"
+
+	Scenario: should lock a client out after repeated failures across accounts
+		Given I fail to log in as "nobody" 20 times
+		When I send "POST" request to "/api/login" with body:
+			"""
+			{
+				"username": "testuser1",
+				"password": "correct horse battery staple"
+			}
+			"""
+		Then the response code should be 429
+
+	Scenario: should not let a client escape its lockout by forging forwarded headers
+		Given I set the "X-Forwarded-For" header to "198.51.100.1"
+		And I fail to log in as "nobody" 20 times
+		And I set the "X-Forwarded-For" header to "198.51.100.2"
+		When I send "POST" request to "/api/login" with body:
+			"""
+			{
+				"username": "testuser1",
+				"password": "correct horse battery staple"
+			}
+			"""
+		Then the response code should be 429
+
+	Scenario: should let an admin unlock an account
+		Given there are users:
+			| id | username | email              | created_at           | updated_at           | password_hash | role  |
+			| 2  | admin1   | admin1@example.com | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | fakehash      | admin |
+		And I fail to log in as "testuser1" 5 times
+		And I am authenticated as user "2"
+		When I send "PATCH" request to "/api/users/1" with body:
+			"""
+			{
+				"data": {
+					"type": "users",
+					"id": "1",
+					"attributes": {
+						"version": 1,
+						"locked-until": null
+					}
+				}
+			}
+			"""
+		Then the response code should be 204
+		When I send "POST" request to "/api/login" with body:
+			"""
+			{
+				"username": "testuser1",
+				"password": "correct horse battery staple"
+			}
+			"""
+		Then the response code should be 200
+
+	Scenario: should keep an account locked when unlocking it conflicts
+		Given there are users:
+			| id | username | email              | created_at           | updated_at           | password_hash | role  |
+			| 2  | admin1   | admin1@example.com | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | fakehash      | admin |
+		And I fail to log in as "testuser1" 5 times
+		And I am authenticated as user "2"
+		When I send "PATCH" request to "/api/users/1" with body:
+			"""
+			{
+				"data": {
+					"type": "users",
+					"id": "1",
+					"attributes": {
+						"version": 2,
+						"locked-until": null
+					}
+				}
+			}
+			"""
+		Then the response code should be 409
+		When I send "POST" request to "/api/login" with body:
+			"""
+			{
+				"username": "testuser1",
+				"password": "correct horse battery staple"
+			}
+			"""
+		Then the response code should be 429
+
+	Scenario: should not let users unlock themselves
+		Given there are users:
+			| id | username  | email                 | created_at           | updated_at           | password_hash | locked_until         |
+			| 3  | testuser3 | testuser3@example.com | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | fakehash      | 2099-01-01T00:00:00Z |
+		And I am authenticated as user "3"
+		When I send "PATCH" request to "/api/users/3" with body:
+			"""
+			{
+				"data": {
+					"type": "users",
+					"id": "3",
+					"attributes": {
+						"version": 1,
+						"locked-until": null
+					}
+				}
+			}
+			"""
+		Then the response code should be 403
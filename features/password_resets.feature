@@ -0,0 +1,53 @@
+Feature: password resets
+	In order to get back into my account
+	As an author on timrourke.com
+	I need to be able to reset a forgotten password
+
+	Background:
+		Given there are users:
+			| id | username  | email                 | created_at           | updated_at           | password_hash |
+			| 1  | testuser1 | testuser1@example.com | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | fakehash      |
+
+	Scenario: should accept a reset request for a known email
+		When I send "POST" request to "/api/password-resets" with body:
+			"""
+			{
+				"email": "testuser1@example.com"
+			}
+			"""
+		Then the response code should be 202
+
+	Scenario: should accept a reset request for an unknown email
+		When I send "POST" request to "/api/password-resets" with body:
+			"""
+			{
+				"email": "nobody@example.com"
+			}
+			"""
+		Then the response code should be 202
+
+	Scenario: should reject an unknown token
+		When I send "POST" request to "/api/password-resets/confirm" with body:
+			"""
+			{
+				"token": "not-a-real-token",
+				"password": "correct horse battery staple",
+				"password-confirmation": "correct horse battery staple"
+			}
+			"""
+		Then the response code should be 422
+		And the response should match json:
+			"""
+			{
+				"errors": [
+					{
+						"status": "422",
+						"title": "Invalid Attribute",
+						"detail": "token is invalid or has expired",
+						"source": {
+							"pointer": "/token"
+						}
+					}
+				]
+			}
+			"""
@@ -0,0 +1,105 @@
+Feature: permalinks
+	In order to link to posts that may be renamed
+	As a reader of timrourke.com
+	I need every post to have a unique permalink, and old permalinks to redirect
+
+	Background:
+		Given there are users:
+			| id | username | email               | created_at           | updated_at           | password_hash | role   |
+			| 1  | author1  | author1@example.com | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | fakehash      | author |
+		And there are posts:
+			| id | title      | excerpt | content        | permalink  | user_id | status    | published_at         | created_at           | updated_at           |
+			| 1  | First post | Short   | <p>Welcome</p> | first-post | 1       | published | 2016-02-07T03:27:16Z | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z |
+		And I am authenticated as user "1"
+
+	Scenario: should generate a permalink from the title
+		When I send "POST" request to "/api/posts" with body:
+			"""
+			{
+				"data": {
+					"type": "posts",
+					"attributes": {
+						"title": "Crème brûlée & Straße",
+						"excerpt": "Dessert",
+						"content": "Dessert"
+					}
+				}
+			}
+			"""
+		Then the response code should be 201
+		And the response should contain text "creme-brulee-and-strasse"
+
+	Scenario: should number permalinks that are taken
+		When I send "POST" request to "/api/posts" with body:
+			"""
+			{
+				"data": {
+					"type": "posts",
+					"attributes": {
+						"title": "First Post!",
+						"excerpt": "Again",
+						"content": "Again"
+					}
+				}
+			}
+			"""
+		Then the response code should be 201
+		And the response should contain text "first-post-2"
+
+	Scenario: should serve published posts by permalink
+		Given I am not authenticated
+		When I send "GET" request to "/posts/first-post"
+		Then the response code should be 200
+		And the response should contain text "<p>Welcome</p>"
+
+	Scenario: should redirect an old permalink to the current one
+		When I send "PATCH" request to "/api/posts/1" with body:
+			"""
+			{
+				"data": {
+					"type": "posts",
+					"id": "1",
+					"attributes": {
+						"version": 1,
+						"permalink": "Renamed Post"
+					}
+				}
+			}
+			"""
+		Then the response code should be 204
+		Given I am not authenticated
+		When I send "GET" request to "/posts/first-post"
+		Then the response code should be 301
+		And the response header "Location" should be "/posts/renamed-post"
+		When I send "GET" request to "/posts/renamed-post"
+		Then the response code should be 200
+
+	Scenario: should not give a post another post's old permalink
+		When I send "PATCH" request to "/api/posts/1" with body:
+			"""
+			{
+				"data": {
+					"type": "posts",
+					"id": "1",
+					"attributes": {
+						"version": 1,
+						"permalink": "renamed-post"
+					}
+				}
+			}
+			"""
+		And I send "POST" request to "/api/posts" with body:
+			"""
+			{
+				"data": {
+					"type": "posts",
+					"attributes": {
+						"title": "First post",
+						"excerpt": "Again",
+						"content": "Again"
+					}
+				}
+			}
+			"""
+		Then the response code should be 201
+		And the response should contain text "first-post-2"
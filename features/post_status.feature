@@ -0,0 +1,111 @@
+Feature: post publication status
+	In order to write posts before readers can see them
+	As an author on timrourke.com
+	I need posts to move between draft, published, scheduled and archived
+
+	Background:
+		Given there are users:
+			| id | username     | email                    | created_at           | updated_at           | password_hash | role        |
+			| 1  | author1      | author1@example.com      | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | fakehash      | author      |
+			| 2  | contributor1 | contributor1@example.com | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | fakehash      | contributor |
+		And there are posts:
+			| id | title      | excerpt | content | permalink  | user_id | status    | published_at         | created_at           | updated_at           |
+			| 1  | Published  | Live    | Live    | published  | 1       | published | 2016-02-07T03:27:16Z | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z |
+			| 2  | Draft      | Hidden  | Hidden  | draft      | 1       | draft     | 2016-02-07T03:27:16Z | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z |
+			| 3  | Archived   | Gone    | Gone    | archived   | 1       | archived  | 2016-02-07T03:27:16Z | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z |
+			| 4  | Scheduled  | Soon    | Soon    | scheduled  | 2       | scheduled | 2099-02-07T03:27:16Z | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z |
+
+	Scenario: anonymous readers should only see published posts
+		When I send "GET" request to "/api/posts?filter[status]=draft"
+		Then the response code should be 200
+		And the response should not contain text "Hidden"
+		When I send "GET" request to "/api/posts?page[number]=1&page[size]=10"
+		Then the response code should be 200
+		And the response should contain text "Published"
+		And the response should not contain text "Hidden"
+		And the response should not contain text "Gone"
+		And the response should not contain text "Soon"
+
+	Scenario: anonymous readers should not find unpublished posts
+		When I send "GET" request to "/api/posts/2"
+		Then the response code should be 404
+
+	Scenario: logged in users should see every post
+		Given I am authenticated as user "1"
+		When I send "GET" request to "/api/posts?filter[status]=draft"
+		Then the response code should be 200
+		And the response should contain text "Hidden"
+
+	Scenario: should publish a draft
+		Given I am authenticated as user "1"
+		When I send "PATCH" request to "/api/posts/2" with body:
+			"""
+			{
+				"data": {
+					"type": "posts",
+					"id": "2",
+					"attributes": {
+						"version": 1,
+						"status": "published"
+					}
+				}
+			}
+			"""
+		Then the response code should be 204
+		Given I am not authenticated
+		When I send "GET" request to "/api/posts/2"
+		Then the response code should be 200
+
+	Scenario: should not allow a transition that skips a step
+		Given I am authenticated as user "1"
+		When I send "PATCH" request to "/api/posts/3" with body:
+			"""
+			{
+				"data": {
+					"type": "posts",
+					"id": "3",
+					"attributes": {
+						"version": 1,
+						"status": "published"
+					}
+				}
+			}
+			"""
+		Then the response code should be 422
+		And the response should contain text "cannot change from archived to published"
+
+	Scenario: should not schedule a post in the past
+		Given I am authenticated as user "1"
+		When I send "PATCH" request to "/api/posts/2" with body:
+			"""
+			{
+				"data": {
+					"type": "posts",
+					"id": "2",
+					"attributes": {
+						"version": 1,
+						"status": "scheduled",
+						"published-at": "2016-01-01T00:00:00Z"
+					}
+				}
+			}
+			"""
+		Then the response code should be 422
+		And the response should contain text "/data/attributes/published-at"
+
+	Scenario: contributors should not publish posts
+		Given I am authenticated as user "2"
+		When I send "PATCH" request to "/api/posts/4" with body:
+			"""
+			{
+				"data": {
+					"type": "posts",
+					"id": "4",
+					"attributes": {
+						"version": 1,
+						"status": "published"
+					}
+				}
+			}
+			"""
+		Then the response code should be 403
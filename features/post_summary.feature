@@ -0,0 +1,89 @@
+Feature: post summaries
+	In order to show readers what a post is about before they open it
+	As an author on timrourke.com
+	I need excerpts, word counts and reading times worked out from a post's content
+
+	Background:
+		Given there are users:
+			| id | username | email               | created_at           | updated_at           | password_hash | role   |
+			| 1  | author1  | author1@example.com | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | fakehash      | author |
+		And I am authenticated as user "1"
+		When I send "POST" request to "/api/posts" with body:
+			"""
+			{
+				"data": {
+					"type": "posts",
+					"attributes": {
+						"title": "Summarized",
+						"content": "<p>The first sentence of this post talks at some length about why automatic excerpts are worth having on a blog like this one.</p><p>The second sentence carries on in much the same way, so that the two of them together come close to the excerpt length. Nobody should ever read this third sentence in an excerpt.</p>",
+						"status": "published"
+					}
+				}
+			}
+			"""
+		Then the response code should be 201
+
+	Scenario: should generate an excerpt ending at a sentence
+		When I send "GET" request to "/authors/author1"
+		Then the response code should be 200
+		And the response should contain text "close to the excerpt length."
+		And the response should not contain text "Nobody"
+
+	Scenario: should keep an excerpt the author wrote
+		When I send "PATCH" request to "/api/posts/1" with body:
+			"""
+			{
+				"data": {
+					"type": "posts",
+					"id": "1",
+					"attributes": {
+						"excerpt": "Written by hand",
+						"content": "<p>Short and sweet.</p>",
+						"version": 1
+					}
+				}
+			}
+			"""
+		Then the response code should be 204
+		When I send "GET" request to "/authors/author1"
+		Then the response should contain text "Written by hand"
+
+	Scenario: should generate the excerpt again when the content changes
+		When I send "PATCH" request to "/api/posts/1" with body:
+			"""
+			{
+				"data": {
+					"type": "posts",
+					"id": "1",
+					"attributes": {
+						"content": "<p>Short and sweet.</p>",
+						"version": 1
+					}
+				}
+			}
+			"""
+		Then the response code should be 204
+		When I send "GET" request to "/authors/author1"
+		Then the response should contain text "Short and sweet."
+		And the response should not contain text "The first sentence"
+
+	Scenario: should filter and sort posts by word count and reading time
+		When I send "GET" request to "/api/posts?filter[word-count]=56&filter[reading-time]=1"
+		Then the response code should be 200
+		And the response should contain text "Summarized"
+		When I send "GET" request to "/api/posts?filter[word-count]=55"
+		Then the response should not contain text "Summarized"
+		When I send "GET" request to "/api/posts?sort=-word-count,reading-time"
+		Then the response code should be 200
+
+	Scenario: should summarize posts saved before they were summarized
+		Given there are posts:
+			| id | title    | excerpt | content                         | permalink | user_id | status    | published_at         | created_at           | updated_at           |
+			| 2  | Backfill |         | <p>One two three four five.</p> | backfill  | 1       | published | 2016-02-07T03:27:16Z | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z |
+		When the scheduled jobs run
+		Then the job "render_posts" should have run handling 1 item
+		When I send "GET" request to "/api/posts?filter[word-count]=5&filter[reading-time]=1"
+		Then the response code should be 200
+		And the response should contain text "Backfill"
+		When I send "GET" request to "/authors/author1"
+		Then the response should contain text "One two three four five."
@@ -0,0 +1,99 @@
+Feature: posts endpoint
+	In order to publish articles
+	As an author on timrourke.com
+	I need to be able to create posts attributed to the right user
+
+	Background:
+		Given there are users:
+			| id | username | email               | created_at           | updated_at           | password_hash | role   |
+			| 1  | editor1  | editor1@example.com | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | fakehash      | editor |
+			| 2  | author1  | author1@example.com | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | fakehash      | author |
+
+	Scenario: should reject a post assigned to a missing user
+		Given I am authenticated as user "1"
+		When I send "POST" request to "/api/posts" with body:
+			"""
+			{
+				"data": {
+					"type": "posts",
+					"attributes": {
+						"title": "Hello",
+						"permalink": "hello"
+					},
+					"relationships": {
+						"user": {
+							"data": {
+								"type": "users",
+								"id": "99"
+							}
+						}
+					}
+				}
+			}
+			"""
+		Then the response code should be 422
+		And the response should match json:
+			"""
+			{
+				"errors": [
+					{
+						"status": "422",
+						"title": "Invalid Relationship",
+						"detail": "No user found with the id: 99",
+						"source": {
+							"pointer": "/data/relationships/user"
+						}
+					}
+				]
+			}
+			"""
+
+	Scenario: an author may not create a post for someone else
+		Given I am authenticated as user "2"
+		When I send "POST" request to "/api/posts" with body:
+			"""
+			{
+				"data": {
+					"type": "posts",
+					"attributes": {
+						"title": "Hello",
+						"permalink": "hello"
+					},
+					"relationships": {
+						"user": {
+							"data": {
+								"type": "users",
+								"id": "1"
+							}
+						}
+					}
+				}
+			}
+			"""
+		Then the response code should be 403
+
+	Scenario: an editor may create a post for an author
+		Given I am authenticated as user "1"
+		When I send "POST" request to "/api/posts" with body:
+			"""
+			{
+				"data": {
+					"type": "posts",
+					"attributes": {
+						"title": "Hello",
+						"permalink": "hello"
+					},
+					"relationships": {
+						"user": {
+							"data": {
+								"type": "users",
+								"id": "2"
+							}
+						}
+					}
+				}
+			}
+			"""
+		Then the response code should be 201
+		And I send "GET" request to "/api/users/2/posts"
+		And the response should contain text "hello"
@@ -0,0 +1,85 @@
+Feature: post revisions
+	In order to undo a bad save
+	As an author on timrourke.com
+	I need every change to a post to keep a revision I can compare and restore
+
+	Background:
+		Given there are users:
+			| id | username | email               | created_at           | updated_at           | password_hash | role   |
+			| 1  | author1  | author1@example.com | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | fakehash      | author |
+			| 2  | author2  | author2@example.com | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | fakehash      | author |
+		And there are posts:
+			| id | title    | excerpt | content              | permalink | user_id | status    | published_at         | created_at           | updated_at           |
+			| 1  | Original | Short   | <p>The first cut</p> | original  | 1       | published | 2016-02-07T03:27:16Z | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z |
+		And I am authenticated as user "1"
+		And I send "PATCH" request to "/api/posts/1" with body:
+			"""
+			{
+				"data": {
+					"type": "posts",
+					"id": "1",
+					"attributes": {
+						"version": 1,
+						"title": "Ruined",
+						"excerpt": "Short",
+						"content": "<p>The bad cut</p>",
+						"permalink": "original",
+						"status": "published"
+					}
+				}
+			}
+			"""
+
+	Scenario: should save the previous version when a post changes
+		When I send "GET" request to "/api/posts/1/revisions"
+		Then the response code should be 200
+		And the response should contain text "Original"
+		And the response should contain text "The first cut"
+		And the response should not contain text "Ruined"
+
+	Scenario: anonymous readers should not see revisions
+		Given I am not authenticated
+		When I send "GET" request to "/api/posts/1/revisions"
+		Then the response code should be 401
+
+	Scenario: should compare a revision with the current post
+		When I send "GET" request to "/api/posts/1/revisions/diff?from=1"
+		Then the response code should be 200
+		And the response should match json:
+			"""
+			{
+				"from": "1",
+				"to": "current",
+				"changes": {
+					"title": [
+						{"op": "delete", "text": "Original"},
+						{"op": "insert", "text": "Ruined"}
+					],
+					"content": [
+						{"op": "equal", "text": "<p>The "},
+						{"op": "delete", "text": "first"},
+						{"op": "insert", "text": "bad"},
+						{"op": "equal", "text": " cut</p>"}
+					]
+				}
+			}
+			"""
+
+	Scenario: should not compare revisions of another post
+		When I send "GET" request to "/api/posts/1/revisions/diff?from=99"
+		Then the response code should be 404
+
+	Scenario: should restore a revision
+		When I send "POST" request to "/api/posts/1/revisions/1/restore"
+		Then the response code should be 200
+		And the response should contain text "The first cut"
+		When I send "GET" request to "/api/posts/1"
+		Then the response should contain text "Original"
+		When I send "GET" request to "/api/posts/1/revisions/diff?from=2&to=current"
+		Then the response code should be 200
+		And the response should contain text "Ruined"
+
+	Scenario: should not let other authors restore a revision
+		Given I am authenticated as user "2"
+		When I send "POST" request to "/api/posts/1/revisions/1/restore"
+		Then the response code should be 403
@@ -0,0 +1,68 @@
+Feature: role-based authorization
+	In order to protect everyone's work on a shared install
+	As an admin of timrourke.com
+	I need each role to be limited to what it may change
+
+	Background:
+		Given there are users:
+			| id | username | email                | created_at           | updated_at           | password_hash | role   |
+			| 1  | admin1   | admin1@example.com   | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | fakehash      | admin  |
+			| 2  | editor1  | editor1@example.com  | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | fakehash      | editor |
+			| 3  | author1  | author1@example.com  | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | fakehash      | author |
+			| 4  | author2  | author2@example.com  | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | fakehash      | author |
+		And there are posts:
+			| id | user_id | title        | excerpt | content | permalink    | created_at           | updated_at           |
+			| 1  | 3       | First post   | First   | First   | first-post   | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z |
+
+	Scenario: an author may not delete another author's post
+		Given I am authenticated as user "4"
+		When I send "DELETE" request to "/api/posts/1"
+		Then the response code should be 403
+		And the response should match json:
+			"""
+			{
+				"errors": [
+					{
+						"status": "403",
+						"title": "You may not delete this post"
+					}
+				]
+			}
+			"""
+
+	Scenario: an author may delete their own post
+		Given I am authenticated as user "3"
+		When I send "DELETE" request to "/api/posts/1"
+		Then the response code should be 204
+
+	Scenario: an editor may delete another author's post
+		Given I am authenticated as user "2"
+		When I send "DELETE" request to "/api/posts/1"
+		Then the response code should be 204
+
+	Scenario: an editor may not manage users
+		Given I am authenticated as user "2"
+		When I send "DELETE" request to "/api/users/4"
+		Then the response code should be 403
+
+	Scenario: an author may not promote themself
+		Given I am authenticated as user "3"
+		When I send "PATCH" request to "/api/users/3" with body:
+			"""
+			{
+				"data": {
+					"type": "users",
+					"id": "3",
+					"attributes": {
+						"version": 1,
+						"role": "admin"
+					}
+				}
+			}
+			"""
+		Then the response code should be 403
+
+	Scenario: an admin may delete a user
+		Given I am authenticated as user "1"
+		When I send "DELETE" request to "/api/users/4"
+		Then the response code should be 204
@@ -0,0 +1,63 @@
+Feature: sanitize post HTML
+	In order to keep readers safe from scripts slipped into a post
+	As an author on timrourke.com
+	I need post HTML sanitized every time a post is saved
+
+	Background:
+		Given there are users:
+			| id | username | email               | created_at           | updated_at           | password_hash | role   |
+			| 1  | author1  | author1@example.com | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | fakehash      | author |
+		And there are posts:
+			| id | title      | excerpt | content        | permalink  | user_id | status    | published_at         | created_at           | updated_at           |
+			| 1  | First post | Short   | <p>Welcome</p> | first-post | 1       | published | 2016-02-07T03:27:16Z | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z |
+		And I am authenticated as user "1"
+
+	Scenario: should strip scripts from new posts
+		When I send "POST" request to "/api/posts" with body:
+			"""
+			{
+				"data": {
+					"type": "posts",
+					"attributes": {
+						"title": "Sneaky",
+						"excerpt": "<b onmouseover='steal()'>Short</b>",
+						"content": "<p>Hello</p><script>steal()</script>",
+						"status": "published"
+					}
+				}
+			}
+			"""
+		Then the response code should be 201
+		And the response should not contain text "steal"
+		When I send "GET" request to "/posts/sneaky"
+		Then the response code should be 200
+		And the response should contain text "<p>Hello</p>"
+		And the response should not contain text "steal"
+
+	Scenario: should strip javascript links from changed posts
+		When I send "PATCH" request to "/api/posts/1" with body:
+			"""
+			{
+				"data": {
+					"type": "posts",
+					"id": "1",
+					"attributes": {
+						"content": "<p><a href='javascript:steal()'>Welcome</a></p>",
+						"version": 1
+					}
+				}
+			}
+			"""
+		Then the response code should be 204
+		When I send "GET" request to "/api/posts/1"
+		Then the response should contain text "Welcome"
+		And the response should not contain text "javascript"
+
+	Scenario: should sanitize posts saved before their HTML was cached
+		Given there are posts:
+			| id | title       | excerpt | content                             | permalink   | user_id | status    | published_at         | created_at           | updated_at           |
+			| 2  | Legacy post | Old     | <p>Old</p><script>alert(1)</script> | legacy-post | 1       | published | 2016-02-07T03:27:16Z | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z |
+		When I send "GET" request to "/posts/legacy-post"
+		Then the response code should be 200
+		And the response should contain text "<p>Old</p>"
+		And the response should not contain text "alert(1)"
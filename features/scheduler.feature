@@ -0,0 +1,34 @@
+Feature: scheduled publishing
+	In order to write posts ahead of time
+	As an author on timrourke.com
+	I need scheduled posts to be published once their publish time has passed
+
+	Background:
+		Given there are users:
+			| id | username | email               | created_at           | updated_at           | password_hash | role   |
+			| 1  | author1  | author1@example.com | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | fakehash      | author |
+			| 2  | admin1   | admin1@example.com  | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | fakehash      | admin  |
+		And there are posts:
+			| id | title   | excerpt | content | permalink | user_id | status    | published_at         | created_at           | updated_at           |
+			| 1  | Due     | Due     | Due     | due       | 1       | scheduled | 2016-02-07T03:27:16Z | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z |
+			| 2  | Later   | Later   | Later   | later     | 1       | scheduled | 2099-02-07T03:27:16Z | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z |
+
+	Scenario: should publish scheduled posts that are due
+		When the scheduled jobs run
+		Then the job "publish_scheduled_posts" should have run handling 1 item
+		When I send "GET" request to "/api/posts/1"
+		Then the response code should be 200
+		When I send "GET" request to "/api/posts/2"
+		Then the response code should be 404
+
+	Scenario: should not publish a post twice
+		When the scheduled jobs run
+		And the scheduled jobs run
+		Then the job "publish_scheduled_posts" should have run handling 0 items
+
+	Scenario: should record scheduled publishing in the audit log
+		When the scheduled jobs run
+		And I am authenticated as user "2"
+		And I send "GET" request to "/api/audit-events?filter[resource-id]=1"
+		Then the response code should be 200
+		And the response should contain text "published"
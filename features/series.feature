@@ -0,0 +1,124 @@
+Feature: series
+	In order to publish multi-part tutorials
+	As an editor on timrourke.com
+	I need to put posts in order and let readers move between them
+
+	Background:
+		Given there are users:
+			| id | username | email               | created_at           | updated_at           | password_hash | role   |
+			| 1  | author1  | author1@example.com | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | fakehash      | author |
+			| 2  | editor1  | editor1@example.com | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | fakehash      | editor |
+		And there are posts:
+			| id | title      | excerpt | content | permalink  | user_id | status    | published_at         | created_at           | updated_at           |
+			| 1  | Part One   | a       | a       | part-one   | 1       | published | 2016-02-07T03:27:16Z | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z |
+			| 2  | Part Two   | b       | b       | part-two   | 1       | published | 2016-02-07T03:27:16Z | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z |
+			| 3  | Part Three | c       | c       | part-three | 1       | published | 2016-02-07T03:27:16Z | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z |
+			| 4  | Part Draft | d       | d       | part-draft | 1       | draft     | 2016-02-07T03:27:16Z | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z |
+			| 5  | Part Five  | e       | e       | part-five  | 1       | published | 2016-02-07T03:27:16Z | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z |
+		And there are series:
+			| id | title       | slug        | description     |
+			| 1  | Go Tutorial | go-tutorial | Learn Go slowly |
+		And the series contain posts:
+			| series_id | post_id | position |
+			| 1         | 1       | 1        |
+			| 1         | 2       | 2        |
+			| 1         | 4       | 3        |
+			| 1         | 3       | 4        |
+
+	Scenario: should link a post to the published posts around it
+		When I send "GET" request to "/api/posts/2"
+		Then the response code should be 200
+		And the response should contain text "Part One"
+		And the response should contain text "Part Three"
+		And the response should not contain text "Part Draft"
+
+	Scenario: should link a post to unpublished posts for those who may read them
+		Given I am authenticated as user "2"
+		When I send "GET" request to "/api/posts/2"
+		Then the response code should be 200
+		And the response should contain text "Part Draft"
+
+	Scenario: should list the published posts in a series to anonymous readers
+		When I send "GET" request to "/api/series/1/posts"
+		Then the response code should be 200
+		And the response should contain text "Part One"
+		And the response should contain text "Part Three"
+		And the response should not contain text "Part Draft"
+		And the response should not contain text "Part Five"
+
+	Scenario: should reorder a series
+		Given I am authenticated as user "2"
+		When I send "PATCH" request to "/api/series/1/relationships/posts" with body:
+			"""
+			{
+				"data": [
+					{"type": "posts", "id": "3"},
+					{"type": "posts", "id": "2"},
+					{"type": "posts", "id": "1"}
+				]
+			}
+			"""
+		Then the response code should be 204
+		When I send "GET" request to "/api/posts/1"
+		Then the response should contain text "Part Two"
+		And the response should not contain text "Part Three"
+
+	Scenario: should add a post to the end of its new series
+		Given I am authenticated as user "1"
+		When I send "PATCH" request to "/api/posts/5" with body:
+			"""
+			{
+				"data": {
+					"type": "posts",
+					"id": "5",
+					"attributes": {
+						"version": 1
+					},
+					"relationships": {
+						"series": {
+							"data": {"type": "series", "id": "1"}
+						}
+					}
+				}
+			}
+			"""
+		Then the response code should be 204
+		When I am not authenticated
+		And I send "GET" request to "/api/posts/3"
+		Then the response should contain text "Part Five"
+
+	Scenario: should reject posts that do not exist
+		Given I am authenticated as user "2"
+		When I send "PATCH" request to "/api/series/1" with body:
+			"""
+			{
+				"data": {
+					"type": "series",
+					"id": "1",
+					"relationships": {
+						"posts": {
+							"data": [
+								{"type": "posts", "id": "99"}
+							]
+						}
+					}
+				}
+			}
+			"""
+		Then the response code should be 422
+
+	Scenario: only editors may change series
+		Given I am authenticated as user "1"
+		When I send "PATCH" request to "/api/series/1" with body:
+			"""
+			{
+				"data": {
+					"type": "series",
+					"id": "1",
+					"attributes": {
+						"title": "Go Tutorial Redux"
+					}
+				}
+			}
+			"""
+		Then the response code should be 403
@@ -0,0 +1,126 @@
+Feature: tags
+	In order to group posts by subject
+	As an author on timrourke.com
+	I need to tag posts and find posts by tag
+
+	Background:
+		Given there are users:
+			| id | username | email               | created_at           | updated_at           | password_hash | role   |
+			| 1  | author1  | author1@example.com | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | fakehash      | author |
+			| 2  | editor1  | editor1@example.com | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | fakehash      | editor |
+		And there are posts:
+			| id | title     | excerpt | content | permalink | user_id | status    | published_at         | created_at           | updated_at           |
+			| 1  | About Go  | Go      | Go      | about-go  | 1       | published | 2016-02-07T03:27:16Z | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z |
+			| 2  | About SQL | SQL     | SQL     | about-sql | 1       | published | 2016-02-07T03:27:16Z | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z |
+		And there are tags:
+			| id | name     | slug     |
+			| 1  | Go       | go       |
+			| 2  | Database | database |
+		And the posts are tagged:
+			| post_id | tag_id |
+			| 1       | 1      |
+			| 2       | 2      |
+
+	Scenario: should filter posts by tag slug or id
+		When I send "GET" request to "/api/posts?filter[tags]=go"
+		Then the response code should be 200
+		And the response should contain text "About Go"
+		And the response should not contain text "About SQL"
+		When I send "GET" request to "/api/posts?filter[tags]=2"
+		Then the response should contain text "About SQL"
+		And the response should not contain text "About Go"
+
+	Scenario: should list a post's tags
+		When I send "GET" request to "/api/posts/1/tags"
+		Then the response code should be 200
+		And the response should contain text "Go"
+		And the response should not contain text "Database"
+
+	Scenario: should create a tag with a slug
+		Given I am authenticated as user "1"
+		When I send "POST" request to "/api/tags" with body:
+			"""
+			{
+				"data": {
+					"type": "tags",
+					"attributes": {
+						"name": "Café Culture"
+					}
+				}
+			}
+			"""
+		Then the response code should be 201
+		And the response should contain text "cafe-culture"
+
+	Scenario: should not create a tag twice
+		Given I am authenticated as user "1"
+		When I send "POST" request to "/api/tags" with body:
+			"""
+			{
+				"data": {
+					"type": "tags",
+					"attributes": {
+						"name": "GO"
+					}
+				}
+			}
+			"""
+		Then the response code should be 422
+
+	Scenario: should attach and detach tags
+		Given I am authenticated as user "1"
+		When I send "POST" request to "/api/posts/2/relationships/tags" with body:
+			"""
+			{
+				"data": [
+					{"type": "tags", "id": "1"}
+				]
+			}
+			"""
+		Then the response code should be 204
+		When I send "GET" request to "/api/posts?filter[tags]=go"
+		Then the response should contain text "About SQL"
+		When I send "DELETE" request to "/api/posts/2/relationships/tags" with body:
+			"""
+			{
+				"data": [
+					{"type": "tags", "id": "1"}
+				]
+			}
+			"""
+		Then the response code should be 204
+		When I send "GET" request to "/api/posts?filter[tags]=go"
+		Then the response should not contain text "About SQL"
+
+	Scenario: should reject tags that do not exist
+		Given I am authenticated as user "1"
+		When I send "PATCH" request to "/api/posts/1" with body:
+			"""
+			{
+				"data": {
+					"type": "posts",
+					"id": "1",
+					"attributes": {
+						"version": 1
+					},
+					"relationships": {
+						"tags": {
+							"data": [
+								{"type": "tags", "id": "99"}
+							]
+						}
+					}
+				}
+			}
+			"""
+		Then the response code should be 422
+
+	Scenario: only editors may delete tags
+		Given I am authenticated as user "1"
+		When I send "DELETE" request to "/api/tags/1"
+		Then the response code should be 403
+		Given I am authenticated as user "2"
+		When I send "DELETE" request to "/api/tags/1"
+		Then the response code should be 204
+		When I send "GET" request to "/api/posts?filter[tags]=go"
+		Then the response should not contain text "About Go"
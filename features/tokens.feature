@@ -0,0 +1,91 @@
+Feature: personal access tokens
+	In order to publish posts from scripts
+	As an author of timrourke.com
+	I need scoped tokens that scripts can authenticate with
+
+	Background:
+		Given there are users:
+			| id | username | email               | created_at           | updated_at           | password_hash | role   |
+			| 1  | admin1   | admin1@example.com  | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | fakehash      | admin  |
+			| 2  | author1  | author1@example.com | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | fakehash      | author |
+
+	Scenario: should create a token and only show it once
+		Given I am authenticated as user "2"
+		When I send "POST" request to "/api/tokens" with body:
+			"""
+			{
+				"data": {
+					"type": "tokens",
+					"attributes": {
+						"name": "deploy script",
+						"scopes": ["posts:read", "posts:write"]
+					}
+				}
+			}
+			"""
+		Then the response code should be 201
+		And the response should contain text "trk_"
+		When I send "GET" request to "/api/tokens"
+		Then the response code should be 200
+		And the response should contain text "deploy script"
+		And the response should not contain text "trk_"
+
+	Scenario: should reject unknown scopes
+		Given I am authenticated as user "2"
+		When I send "POST" request to "/api/tokens" with body:
+			"""
+			{
+				"data": {
+					"type": "tokens",
+					"attributes": {
+						"name": "deploy script",
+						"scopes": ["posts:destroy"]
+					}
+				}
+			}
+			"""
+		Then the response code should be 422
+
+	Scenario: only admins may create tokens that manage users
+		Given I am authenticated as user "2"
+		When I send "POST" request to "/api/tokens" with body:
+			"""
+			{
+				"data": {
+					"type": "tokens",
+					"attributes": {
+						"name": "user sync",
+						"scopes": ["users:admin"]
+					}
+				}
+			}
+			"""
+		Then the response code should be 403
+
+	Scenario: should let a token create posts within its scopes
+		Given I am authenticated as user "2" with a token scoped to "posts:write"
+		When I send "POST" request to "/api/posts" with body:
+			"""
+			{
+				"data": {
+					"type": "posts",
+					"attributes": {
+						"title": "From a script",
+						"excerpt": "Automated",
+						"content": "Published by a script",
+						"permalink": "from-a-script"
+					}
+				}
+			}
+			"""
+		Then the response code should be 201
+
+	Scenario: should not let a token act outside its scopes
+		Given I am authenticated as user "1" with a token scoped to "posts:read"
+		When I send "DELETE" request to "/api/users/2"
+		Then the response code should be 403
+
+	Scenario: should not let a token create more tokens
+		Given I am authenticated as user "2" with a token scoped to "posts:write"
+		When I send "GET" request to "/api/tokens"
+		Then the response code should be 403
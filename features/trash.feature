@@ -0,0 +1,95 @@
+Feature: trash
+	In order to undo deleting a post or user by mistake
+	As an author or admin on timrourke.com
+	I need deleted posts and users to wait in a trash before they are purged
+
+	Background:
+		Given there are users:
+			| id | username | email               | created_at           | updated_at           | password_hash | role   | deleted_at           |
+			| 1  | author1  | author1@example.com | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | fakehash      | author |                      |
+			| 2  | admin1   | admin1@example.com  | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | fakehash      | admin  |                      |
+			| 3  | author2  | author2@example.com | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | fakehash      | author |                      |
+			| 4  | gone1    | gone1@example.com   | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | fakehash      | author | 2016-03-17T12:27:49Z |
+		And there are posts:
+			| id | title    | excerpt  | content  | permalink | user_id | status    | published_at         | created_at           | updated_at           | deleted_at           |
+			| 1  | Kept     | Kept     | Kept     | kept      | 1       | published | 2016-02-07T03:27:16Z | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z |                      |
+			| 2  | Binned   | Binned   | Binned   | binned    | 1       | published | 2016-02-07T03:27:16Z | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | 2016-03-17T12:27:49Z |
+			| 3  | Others   | Others   | Others   | others    | 3       | published | 2016-02-07T03:27:16Z | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | 2016-03-17T12:27:49Z |
+
+	Scenario: should hide deleted posts from every listing
+		Given I am authenticated as user "1"
+		When I send "DELETE" request to "/api/posts/1"
+		Then the response code should be 204
+		When I send "GET" request to "/api/posts/1"
+		Then the response code should be 404
+		When I send "GET" request to "/api/posts"
+		Then the response code should be 200
+		And the response should not contain text "Kept"
+		And the response should not contain text "Binned"
+
+	Scenario: should list only an author's own posts in the trash
+		Given I am authenticated as user "1"
+		When I send "GET" request to "/api/trash/posts"
+		Then the response code should be 200
+		And the response should contain text "Binned"
+		And the response should not contain text "Others"
+
+	Scenario: should list every post in the trash for editors
+		Given I am authenticated as user "2"
+		When I send "GET" request to "/api/trash/posts"
+		Then the response code should be 200
+		And the response should contain text "Binned"
+		And the response should contain text "Others"
+
+	Scenario: should restore a post from the trash
+		Given I am authenticated as user "1"
+		When I send "POST" request to "/api/posts/2/restore"
+		Then the response code should be 200
+		When I send "GET" request to "/api/posts/2"
+		Then the response code should be 200
+		And the response should contain text "Binned"
+
+	Scenario: should not restore another author's post
+		Given I am authenticated as user "1"
+		When I send "POST" request to "/api/posts/3/restore"
+		Then the response code should be 403
+
+	Scenario: should not restore a post that is not in the trash
+		Given I am authenticated as user "1"
+		When I send "POST" request to "/api/posts/1/restore"
+		Then the response code should be 404
+
+	Scenario: should keep a deleted user from logging in until restored
+		Given I am authenticated as user "2"
+		When I send "GET" request to "/api/users/4"
+		Then the response code should be 404
+		When I send "GET" request to "/api/trash/users"
+		Then the response code should be 200
+		And the response should contain text "gone1"
+		When I send "POST" request to "/api/users/4/restore"
+		Then the response code should be 200
+		When I send "GET" request to "/api/users/4"
+		Then the response code should be 200
+
+	Scenario: should only let admins see users in the trash
+		Given I am authenticated as user "1"
+		When I send "GET" request to "/api/trash/users"
+		Then the response code should be 403
+
+	Scenario: should purge posts and users once their retention has passed
+		When the scheduled jobs run
+		Then the job "purge_trash" should have run handling 3 items
+		Given I am authenticated as user "2"
+		When I send "GET" request to "/api/trash/posts"
+		Then the response code should be 200
+		And the response should not contain text "Binned"
+		When I send "POST" request to "/api/users/4/restore"
+		Then the response code should be 404
+
+	Scenario: should keep recently deleted posts in the trash
+		Given I am authenticated as user "1"
+		When I send "DELETE" request to "/api/posts/1"
+		And the scheduled jobs run
+		And I send "GET" request to "/api/trash/posts"
+		Then the response code should be 200
+		And the response should contain text "Kept"
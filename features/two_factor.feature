@@ -0,0 +1,61 @@
+Feature: two-factor authentication
+	In order to protect accounts that can delete every post
+	As an admin of timrourke.com
+	I need logins to require a TOTP code
+
+	Background:
+		Given there are users:
+			| id | username | email              | created_at           | updated_at           | password_hash                                                | role   | totp_secret                      | totp_enabled_at      |
+			| 1  | admin1   | admin1@example.com | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | $2a$10$JJTZNZZMoBVWMr0n.4bUcuBvqW7xZdi2QSax5sQoFdvg0p2WTEvCq | admin  | GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ | 2016-03-17T12:27:49Z |
+		And there are users:
+			| id | username | email               | created_at           | updated_at           | password_hash | role   |
+			| 2  | editor1  | editor1@example.com | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | fakehash      | editor |
+
+	Scenario: should ask for a code when logging in with two-factor enabled
+		When I send "POST" request to "/api/login" with body:
+			"""
+			{
+				"username": "admin1",
+				"password": "correct horse battery staple"
+			}
+			"""
+		Then the response code should be 401
+		And the response should match json:
+			"""
+			{
+				"errors": [
+					{
+						"status": "401",
+						"code": "two_factor_required",
+						"title": "A two-factor code is required"
+					}
+				]
+			}
+			"""
+
+	Scenario: should reject a wrong code
+		When I send "POST" request to "/api/login" with body:
+			"""
+			{
+				"username": "admin1",
+				"password": "correct horse battery staple",
+				"code": "000000"
+			}
+			"""
+		Then the response code should be 401
+
+	Scenario: only admins may change the two-factor policy
+		Given I am authenticated as user "2"
+		When I send "PUT" request to "/api/two-factor/policy" with body:
+			"""
+			{
+				"roles": ["admin", "editor"]
+			}
+			"""
+		Then the response code should be 403
+
+	Scenario: should let a user start enrolling
+		Given I am authenticated as user "2"
+		When I send "POST" request to "/api/two-factor/enroll"
+		Then the response code should be 200
+		And the response should contain text "otpauth://totp/timrourke.com:editor1"
@@ -32,7 +32,13 @@ Feature: users endpoint
 						"created-at": "2016-02-07T03:27:16Z",
 						"updated-at": "2016-03-17T12:27:49Z",
 						"username": "testuser1",
-						"email": "testuser1@example.com"
+						"role": "author",
+						"display-name": "",
+						"bio": "",
+						"avatar-url": "",
+						"website": "",
+						"social-links": {},
+						"version": 1
 					}
 				},
 				"meta": {
@@ -63,7 +69,13 @@ Feature: users endpoint
 							"created-at": "2016-02-07T03:27:16Z",
 							"updated-at": "2016-03-17T12:27:49Z",
 							"username": "testuser1",
-							"email": "testuser1@example.com"
+							"role": "author",
+							"display-name": "",
+							"bio": "",
+							"avatar-url": "",
+							"website": "",
+							"social-links": {},
+							"version": 1
 						}
 					},
 					{
@@ -73,7 +85,13 @@ Feature: users endpoint
 							"created-at": "2016-02-07T04:27:16Z",
 							"updated-at": "2016-04-17T12:27:49Z",
 							"username": "testuser2",
-							"email": "testuser2@example.com"
+							"role": "author",
+							"display-name": "",
+							"bio": "",
+							"avatar-url": "",
+							"website": "",
+							"social-links": {},
+							"version": 1
 						}
 					},
 					{
@@ -83,7 +101,13 @@ Feature: users endpoint
 							"created-at": "2016-02-07T05:27:16Z",
 							"updated-at": "2016-05-17T12:27:49Z",
 							"username": "testuser3",
-							"email": "testuser3@example.com"
+							"role": "author",
+							"display-name": "",
+							"bio": "",
+							"avatar-url": "",
+							"website": "",
+							"social-links": {},
+							"version": 1
 						}
 					},
 					{
@@ -93,7 +117,13 @@ Feature: users endpoint
 							"created-at": "2016-02-07T06:27:16Z",
 							"updated-at": "2016-06-17T12:27:49Z",
 							"username": "testuser4",
-							"email": "testuser4@example.com"
+							"role": "author",
+							"display-name": "",
+							"bio": "",
+							"avatar-url": "",
+							"website": "",
+							"social-links": {},
+							"version": 1
 						}
 					},
 					{
@@ -103,7 +133,13 @@ Feature: users endpoint
 							"created-at": "2016-02-07T07:27:16Z",
 							"updated-at": "2016-07-17T12:27:49Z",
 							"username": "testuser5",
-							"email": "testuser5@example.com"
+							"role": "author",
+							"display-name": "",
+							"bio": "",
+							"avatar-url": "",
+							"website": "",
+							"social-links": {},
+							"version": 1
 						}
 					}
 				],
@@ -112,3 +148,187 @@ Feature: users endpoint
 				}
 			}
 			"""
+
+	Scenario: should reject a user whose password confirmation does not match
+		When I send "POST" request to "/api/users" with body:
+			"""
+			{
+				"data": {
+					"type": "users",
+					"attributes": {
+						"username": "testuser1",
+						"email": "testuser1@example.com",
+						"password": "correct horse battery staple",
+						"password-confirmation": "correct horse battery stapler"
+					}
+				}
+			}
+			"""
+		Then the response code should be 422
+		And the response should match json:
+			"""
+			{
+				"errors": [
+					{
+						"status": "422",
+						"title": "Invalid Attribute",
+						"detail": "password-confirmation does not match password",
+						"source": {
+							"pointer": "/data/attributes/password-confirmation"
+						}
+					}
+				]
+			}
+			"""
+
+	Scenario: should reject a user with a weak password
+		When I send "POST" request to "/api/users" with body:
+			"""
+			{
+				"data": {
+					"type": "users",
+					"attributes": {
+						"username": "testuser1",
+						"email": "testuser1@example.com",
+						"password": "short",
+						"password-confirmation": "short"
+					}
+				}
+			}
+			"""
+		Then the response code should be 422
+		And the response should match json:
+			"""
+			{
+				"errors": [
+					{
+						"status": "422",
+						"title": "Invalid Attribute",
+						"detail": "password must be at least 10 characters long",
+						"source": {
+							"pointer": "/data/attributes/password"
+						}
+					}
+				]
+			}
+			"""
+
+	Scenario: should reject a password repeating a single multibyte character
+		When I send "POST" request to "/api/users" with body:
+			"""
+			{
+				"data": {
+					"type": "users",
+					"attributes": {
+						"username": "testuser1",
+						"email": "testuser1@example.com",
+						"password": "éééééééééééé",
+						"password-confirmation": "éééééééééééé"
+					}
+				}
+			}
+			"""
+		Then the response code should be 422
+		And the response should contain text "must not repeat a single character"
+
+	Scenario: should create a user without exposing the password
+		When I send "POST" request to "/api/users" with body:
+			"""
+			{
+				"data": {
+					"type": "users",
+					"attributes": {
+						"username": "testuser1",
+						"email": "testuser1@example.com",
+						"password": "correct horse battery staple",
+						"password-confirmation": "correct horse battery staple"
+					}
+				}
+			}
+			"""
+		Then the response code should be 201
+		And the response should not contain text "password"
+		And the response should not contain text "$2a$"
+
+	Scenario: should show a user their own email address
+		Given there are users:
+			| id | username  | email                 | created_at            | updated_at           | password_hash |
+			| 1  | testuser1 | testuser1@example.com | 2016-02-07T03:27:16Z  | 2016-03-17T12:27:49Z | fakehash      |
+			| 2  | testuser2 | testuser2@example.com | 2016-02-07T04:27:16Z  | 2016-04-17T12:27:49Z | fakehash2     |
+		And I am authenticated as user "1"
+		When I send "GET" request to "/api/users"
+		Then the response code should be 200
+		And the response should contain text "testuser1@example.com"
+		And the response should not contain text "testuser2@example.com"
+
+	Scenario: should not let anyone but admins search users by email address
+		Given there are users:
+			| id | username  | email                 | created_at            | updated_at           | password_hash |
+			| 1  | testuser1 | testuser1@example.com | 2016-02-07T03:27:16Z  | 2016-03-17T12:27:49Z | fakehash      |
+			| 2  | testuser2 | testuser2@example.com | 2016-02-07T04:27:16Z  | 2016-04-17T12:27:49Z | fakehash2     |
+		When I send "GET" request to "/api/users?filter[email]=testuser1@example.com"
+		Then the response code should be 200
+		And the response should contain text "testuser1"
+		And the response should contain text "testuser2"
+		When I send "GET" request to "/api/users?sort=email"
+		Then the response code should be 400
+
+	Scenario: should let admins search users by email address
+		Given there are users:
+			| id | username  | email                 | created_at            | updated_at           | password_hash | role   |
+			| 1  | admin1    | admin1@example.com    | 2016-02-07T03:27:16Z  | 2016-03-17T12:27:49Z | fakehash      | admin  |
+			| 2  | testuser2 | testuser2@example.com | 2016-02-07T04:27:16Z  | 2016-04-17T12:27:49Z | fakehash2     | author |
+		And I am authenticated as user "1"
+		When I send "GET" request to "/api/users?filter[email]=testuser2@example.com"
+		Then the response code should be 200
+		And the response should contain text "testuser2@example.com"
+		And the response should not contain text "admin1@example.com"
+
+	Scenario: should let a user change their profile
+		Given there are users:
+			| id | username  | email                 | created_at            | updated_at           | password_hash |
+			| 1  | testuser1 | testuser1@example.com | 2016-02-07T03:27:16Z  | 2016-03-17T12:27:49Z | fakehash      |
+		And I am authenticated as user "1"
+		When I send "PATCH" request to "/api/users/1" with body:
+			"""
+			{
+				"data": {
+					"type": "users",
+					"id": "1",
+					"attributes": {
+						"version": 1,
+						"display-name": "Test User",
+						"bio": "Writes about Go.",
+						"website": "https://example.com",
+						"social-links": {
+							"github": "https://github.com/testuser1"
+						}
+					}
+				}
+			}
+			"""
+		Then the response code should be 204
+		When I send "GET" request to "/api/users/1"
+		Then the response should contain text "Test User"
+		And the response should contain text "https://github.com/testuser1"
+
+	Scenario: should reject profile links that are not web URLs
+		Given there are users:
+			| id | username  | email                 | created_at            | updated_at           | password_hash |
+			| 1  | testuser1 | testuser1@example.com | 2016-02-07T03:27:16Z  | 2016-03-17T12:27:49Z | fakehash      |
+		And I am authenticated as user "1"
+		When I send "PATCH" request to "/api/users/1" with body:
+			"""
+			{
+				"data": {
+					"type": "users",
+					"id": "1",
+					"attributes": {
+						"version": 1,
+						"website": "javascript:alert(1)"
+					}
+				}
+			}
+			"""
+		Then the response code should be 422
+		And the response should contain text "/data/attributes/website"
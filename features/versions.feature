@@ -0,0 +1,123 @@
+Feature: optimistic concurrency
+	In order not to lose each other's changes
+	As an editor on timrourke.com
+	I need updates made against an old version of a post or user to be rejected
+
+	Background:
+		Given there are users:
+			| id | username | email               | created_at           | updated_at           | password_hash | role   |
+			| 1  | admin1   | admin1@example.com  | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z | fakehash      | admin  |
+		And there are posts:
+			| id | title | excerpt | content | permalink | user_id | status    | published_at         | created_at           | updated_at           |
+			| 1  | Title | Short   | Long    | title     | 1       | published | 2016-02-07T03:27:16Z | 2016-02-07T03:27:16Z | 2016-03-17T12:27:49Z |
+		And I am authenticated as user "1"
+
+	Scenario: should require the version being updated
+		When I send "PATCH" request to "/api/posts/1" with body:
+			"""
+			{
+				"data": {
+					"type": "posts",
+					"id": "1",
+					"attributes": {
+						"title": "Changed"
+					}
+				}
+			}
+			"""
+		Then the response code should be 428
+
+	Scenario: should bump the version on every update
+		When I send "PATCH" request to "/api/posts/1" with body:
+			"""
+			{
+				"data": {
+					"type": "posts",
+					"id": "1",
+					"attributes": {
+						"version": 1,
+						"title": "Changed"
+					}
+				}
+			}
+			"""
+		Then the response code should be 204
+		When I send "PATCH" request to "/api/posts/1" with body:
+			"""
+			{
+				"data": {
+					"type": "posts",
+					"id": "1",
+					"attributes": {
+						"version": 2,
+						"title": "Changed again"
+					}
+				}
+			}
+			"""
+		Then the response code should be 204
+
+	Scenario: should reject a stale version attribute with a conflict
+		When I send "PATCH" request to "/api/posts/1" with body:
+			"""
+			{
+				"data": {
+					"type": "posts",
+					"id": "1",
+					"attributes": {
+						"version": 1,
+						"title": "First editor"
+					}
+				}
+			}
+			"""
+		And I send "PATCH" request to "/api/posts/1" with body:
+			"""
+			{
+				"data": {
+					"type": "posts",
+					"id": "1",
+					"attributes": {
+						"version": 1,
+						"title": "Second editor"
+					}
+				}
+			}
+			"""
+		Then the response code should be 409
+		And the response should contain text "stale_version"
+		And the response should contain text "It is now at version 2"
+		When I send "GET" request to "/api/posts/1"
+		Then the response should contain text "First editor"
+
+	Scenario: should reject a stale If-Match header
+		Given I set the "If-Match" header to "7"
+		When I send "PATCH" request to "/api/users/1" with body:
+			"""
+			{
+				"data": {
+					"type": "users",
+					"id": "1",
+					"attributes": {
+						"bio": "Changed"
+					}
+				}
+			}
+			"""
+		Then the response code should be 412
+
+	Scenario: should accept a current If-Match header
+		Given I set the "If-Match" header to "1"
+		When I send "PATCH" request to "/api/users/1" with body:
+			"""
+			{
+				"data": {
+					"type": "users",
+					"id": "1",
+					"attributes": {
+						"bio": "Changed"
+					}
+				}
+			}
+			"""
+		Then the response code should be 204
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"database/sql"
+	"github.com/gin-gonic/gin"
+	"github.com/timrourke/timrourke.com/model"
+	"github.com/timrourke/timrourke.com/storage"
+	"net/http"
+)
+
+// Authors serves the public page of each author
+type Authors struct {
+	UserStorage *storage.UserStorage
+	PostStorage *storage.PostStorage
+}
+
+// authorPage is the data the author page is rendered from
+type authorPage struct {
+	Author model.User
+	Posts  []model.Post
+}
+
+var authorTemplate = newPageTemplate("author", `
+{{define "title"}}{{.Author.Name}}{{end}}
+{{define "content"}}
+		<article class="author">
+			<header class="author__header">
+				{{if .Author.AvatarURL}}<img class="author__avatar" src="{{.Author.AvatarURL}}" alt="{{.Author.Name}}">{{end}}
+				<h1 class="author__name">{{.Author.Name}}</h1>
+				{{if .Author.Bio}}<p class="author__bio">{{.Author.Bio}}</p>{{end}}
+				<ul class="author__links">
+					{{if .Author.Website}}<li><a href="{{.Author.Website}}" rel="me">Website</a></li>{{end}}
+					{{range $network := .Author.SocialLinks.Networks}}<li><a href="{{index $.Author.SocialLinks $network}}" rel="me">{{$network}}</a></li>{{end}}
+				</ul>
+			</header>
+			<section class="author__posts">
+				<h2>Posts</h2>
+				{{range .Posts}}
+				<article class="author__post">
+					<h3><a href="{{postURL .}}">{{.Title}}</a></h3>
+					<time datetime="{{.PublishedAt.Format "2006-01-02T15:04:05Z07:00"}}">{{.PublishedAt.Format "January 2, 2006"}}</time>
+					<p>{{plainText .Excerpt}}</p>
+				</article>
+				{{else}}
+				<p>No posts yet.</p>
+				{{end}}
+			</section>
+		</article>
+{{end}}
+`)
+
+// Show renders an author's profile and their published posts
+func (h Authors) Show(c *gin.Context) {
+	user, err := h.UserStorage.GetByUsername(c.Param("username"))
+	if err == sql.ErrNoRows {
+		renderNotFound(c)
+		return
+	} else if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	posts, err := h.PostStorage.GetPublishedByUser(user.GetID())
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	renderPage(c, http.StatusOK, authorTemplate, authorPage{
+		Author: *user,
+		Posts:  posts,
+	})
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/timrourke/timrourke.com/model"
+	"net/http"
+	"strconv"
+)
+
+// errorObject mirrors the jsonapi error object used by the api2go resources
+type errorObject struct {
+	Status string       `json:"status"`
+	Code   string       `json:"code,omitempty"`
+	Title  string       `json:"title"`
+	Detail string       `json:"detail,omitempty"`
+	Source *errorSource `json:"source,omitempty"`
+}
+
+// errorSource points at the member of the request body that caused an error
+type errorSource struct {
+	Pointer string `json:"pointer"`
+}
+
+// abortWithError writes a jsonapi error document and stops the handler chain
+func abortWithError(c *gin.Context, status int, title string) {
+	c.Abort()
+	c.JSON(status, gin.H{
+		"errors": []errorObject{
+			{
+				Status: strconv.Itoa(status),
+				Title:  title,
+			},
+		},
+	})
+}
+
+// abortWithErrorCode writes a jsonapi error document with an application
+// specific error code the admin can act on, and stops the handler chain
+func abortWithErrorCode(c *gin.Context, status int, code string, title string) {
+	c.Abort()
+	c.JSON(status, gin.H{
+		"errors": []errorObject{
+			{
+				Status: strconv.Itoa(status),
+				Code:   code,
+				Title:  title,
+			},
+		},
+	})
+}
+
+// abortWithValidationErrors writes a 422 jsonapi error document holding one
+// error object per invalid member of the request body
+func abortWithValidationErrors(c *gin.Context, errs []model.ValidationError) {
+	var errorObjects []errorObject
+
+	for _, validationErr := range errs {
+		errorObjects = append(errorObjects, errorObject{
+			Status: strconv.Itoa(http.StatusUnprocessableEntity),
+			Title:  "Invalid Attribute",
+			Detail: validationErr.Error(),
+			Source: &errorSource{
+				Pointer: "/" + validationErr.Attribute,
+			},
+		})
+	}
+
+	c.Abort()
+	c.JSON(http.StatusUnprocessableEntity, gin.H{
+		"errors": errorObjects,
+	})
+}
@@ -0,0 +1,18 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/timrourke/timrourke.com/highlight"
+	"net/http"
+)
+
+// Highlight serves the stylesheet for the code highlighted in posts
+type Highlight struct {
+	Theme highlight.Theme
+}
+
+// CSS renders the stylesheet for the chosen theme
+func (h Highlight) CSS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.Data(http.StatusOK, "text/css; charset=utf-8", []byte(highlight.CSS(h.Theme)))
+}
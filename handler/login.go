@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/timrourke/timrourke.com/auth"
+	"github.com/timrourke/timrourke.com/clientip"
+	"github.com/timrourke/timrourke.com/model"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dummyUser has the hash of a random password. It is checked when no user
+// matches a login, so unknown usernames take as long to reject as bad
+// passwords.
+var dummyUser = model.User{
+	PasswordHash: "$2a$10$JJTZNZZMoBVWMr0n.4bUcuBvqW7xZdi2QSax5sQoFdvg0p2WTEvCq",
+}
+
+// credentials is the body of a login request
+type credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+
+	// Code is a TOTP or recovery code, required from users enrolled in
+	// two-factor authentication
+	Code string `json:"code"`
+}
+
+// tokenResponse follows the OAuth2 access token response so the admin can use
+// a standard password grant authenticator
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	UserID      string `json:"user_id"`
+}
+
+// Login returns a handler that exchanges a username and password for an
+// access token. Credentials may be sent as JSON or as a form. Accounts and
+// client IPs that fail too often are locked out for a while.
+func Login(authenticator *auth.Authenticator) gin.HandlerFunc {
+	throttle := authenticator.Throttle
+
+	return func(c *gin.Context) {
+		creds, err := readCredentials(c)
+		if err != nil || len(creds.Username) == 0 || len(creds.Password) == 0 {
+			abortWithError(c, http.StatusBadRequest, "A username and password are required")
+			return
+		}
+
+		clientIP := clientip.FromRequest(c.Request)
+
+		user, err := authenticator.UserStorage.GetByUsername(creds.Username)
+		if err == sql.ErrNoRows {
+			user = nil
+		} else if err != nil {
+			abortWithError(c, http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+
+		lockedUntil, err := throttle.Check(user, clientIP, time.Now())
+		if err == auth.ErrLockedOut {
+			abortWithLockout(c, lockedUntil)
+			return
+		} else if err != nil {
+			abortWithError(c, http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+
+		if user == nil {
+			dummyUser.CheckPassword(creds.Password)
+			failLogin(c, throttle, nil, clientIP, "Invalid username or password")
+			return
+		}
+
+		if !user.CheckPassword(creds.Password) {
+			failLogin(c, throttle, user, clientIP, "Invalid username or password")
+			return
+		}
+
+		twoFactor := user.HasTwoFactor()
+		if twoFactor {
+			if len(creds.Code) == 0 {
+				abortWithErrorCode(c, http.StatusUnauthorized, "two_factor_required", "A two-factor code is required")
+				return
+			}
+
+			err = authenticator.VerifySecondFactor(user, creds.Code)
+			if err == auth.ErrInvalidSecondFactor {
+				failLogin(c, throttle, user, clientIP, err.Error())
+				return
+			} else if err != nil {
+				abortWithError(c, http.StatusInternalServerError, "Internal Server Error")
+				return
+			}
+		}
+
+		if err = throttle.Succeed(user); err != nil {
+			abortWithError(c, http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+
+		token, expiresAt, err := authenticator.IssueToken(user, twoFactor)
+		if err != nil {
+			abortWithError(c, http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+
+		c.JSON(http.StatusOK, tokenResponse{
+			AccessToken: token,
+			TokenType:   "Bearer",
+			ExpiresIn:   int64(expiresAt.Sub(time.Now()).Seconds()),
+			UserID:      user.GetID(),
+		})
+	}
+}
+
+// failLogin records a failed login and writes a 401 error
+func failLogin(c *gin.Context, throttle *auth.Throttle, user *model.User, clientIP, title string) {
+	if err := throttle.Fail(user, clientIP, time.Now()); err != nil {
+		abortWithError(c, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	abortWithError(c, http.StatusUnauthorized, title)
+}
+
+// abortWithLockout writes a 429 error telling the client when to try again
+func abortWithLockout(c *gin.Context, lockedUntil time.Time) {
+	retryAfter := int64(math.Ceil(lockedUntil.Sub(time.Now()).Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+
+	c.Header("Retry-After", strconv.FormatInt(retryAfter, 10))
+	abortWithError(c, http.StatusTooManyRequests, auth.ErrLockedOut.Error())
+}
+
+// readCredentials reads a login request body sent either as JSON or as an
+// urlencoded form
+func readCredentials(c *gin.Context) (credentials, error) {
+	var creds credentials
+
+	if strings.HasPrefix(c.Request.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		creds.Username = c.PostForm("username")
+		creds.Password = c.PostForm("password")
+		creds.Code = c.PostForm("code")
+		return creds, nil
+	}
+
+	err := json.NewDecoder(c.Request.Body).Decode(&creds)
+	return creds, err
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"bytes"
+	"github.com/gin-gonic/gin"
+	"github.com/timrourke/timrourke.com/sanitize"
+	"html/template"
+	"net/http"
+)
+
+// layoutTemplate wraps every public page in the site's header and footer.
+// Pages define a "title" and a "content" template.
+const layoutTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="UTF-8">
+	<meta http-equiv="X-UA-Compatible" content="IE=edge">
+	<title>{{template "title" .}} - Tim Rourke</title>
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+
+	<link rel="stylesheet" href="/css/base.css">
+	<link rel="stylesheet" href="/css/highlight.css">
+</head>
+<body>
+	<header class="site-header">
+		<h1 class="site-header__title">
+			<a href="/"><span class="color-orange">T</span>IM
+			<span class="color-orange">R</span>OURKE</a>
+		</h1>
+	</header>
+	<main>
+{{template "content" .}}
+	</main>
+	<footer class="site-footer">
+		<div class="site-footer__copyright">
+			<small>COPYRIGHT TIM ROURKE</small>
+		</div>
+	</footer>
+</body>
+</html>
+`
+
+// notFoundTemplate is the page for anything public that does not exist
+var notFoundTemplate = newPageTemplate("not-found", `
+{{define "title"}}Not found{{end}}
+{{define "content"}}
+		<h1>Not found</h1>
+		<p>There is nothing here.</p>
+{{end}}
+`)
+
+// pageFuncs are the functions available to every page
+var pageFuncs = template.FuncMap{
+	"postURL":   PostURL,
+	"plainText": sanitize.Text,
+}
+
+// newPageTemplate parses a page into the site layout
+func newPageTemplate(name, page string) *template.Template {
+	return template.Must(template.Must(template.New(name).Funcs(pageFuncs).Parse(layoutTemplate)).Parse(page))
+}
+
+// renderPage writes a page as HTML, or a 500 error when it cannot be rendered
+func renderPage(c *gin.Context, status int, page *template.Template, data interface{}) {
+	var buf bytes.Buffer
+
+	if err := page.Execute(&buf, data); err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.Data(status, "text/html; charset=utf-8", buf.Bytes())
+}
+
+// renderNotFound writes the not found page
+func renderNotFound(c *gin.Context) {
+	renderPage(c, http.StatusNotFound, notFoundTemplate, nil)
+}
@@ -0,0 +1,167 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/timrourke/timrourke.com/auth"
+	"github.com/timrourke/timrourke.com/mail"
+	"github.com/timrourke/timrourke.com/model"
+	"github.com/timrourke/timrourke.com/storage"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// DefaultPasswordResetTTL is how long a password reset link stays valid when
+// no TTL is configured
+const DefaultPasswordResetTTL = time.Hour
+
+// PasswordReset handles the "forgot password" flow: mailing a single-use link
+// and then accepting a new password for it
+type PasswordReset struct {
+	UserStorage          *storage.UserStorage
+	PasswordResetStorage *storage.PasswordResetStorage
+	Mailer               mail.Mailer
+
+	// ResetURL is the page of the admin that accepts a new password. The token
+	// is appended as the "token" query param.
+	ResetURL string
+	TTL      time.Duration
+}
+
+// passwordResetRequest is the body of a request for a reset link
+type passwordResetRequest struct {
+	Email string `json:"email"`
+}
+
+// passwordResetConfirmation is the body of a request to set a new password
+type passwordResetConfirmation struct {
+	Token                string `json:"token"`
+	Password             string `json:"password"`
+	PasswordConfirmation string `json:"password-confirmation"`
+}
+
+// Request mails a password reset link to the user with the given email. It
+// responds the same way whether or not the email belongs to anyone, so it
+// cannot be used to discover accounts.
+func (h PasswordReset) Request(c *gin.Context) {
+	var body passwordResetRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&body); err != nil || len(body.Email) == 0 {
+		abortWithError(c, http.StatusBadRequest, "An email is required")
+		return
+	}
+
+	user, err := h.UserStorage.GetByEmail(body.Email)
+	if err == nil {
+		err = h.sendResetLink(user)
+	}
+
+	if err != nil && err != sql.ErrNoRows {
+		log.Println("password reset error", err)
+	}
+
+	c.AbortWithStatus(http.StatusAccepted)
+}
+
+// Confirm sets a new password for the user a reset token was issued to
+func (h PasswordReset) Confirm(c *gin.Context) {
+	var body passwordResetConfirmation
+	if err := json.NewDecoder(c.Request.Body).Decode(&body); err != nil || len(body.Token) == 0 {
+		abortWithError(c, http.StatusBadRequest, "A token is required")
+		return
+	}
+
+	invalidToken := []model.ValidationError{
+		{Attribute: "token", Message: "is invalid or has expired"},
+	}
+
+	reset, err := h.PasswordResetStorage.GetUsable(auth.HashSecret(body.Token))
+	if err == sql.ErrNoRows {
+		abortWithValidationErrors(c, invalidToken)
+		return
+	} else if err != nil {
+		abortWithError(c, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	user, err := h.UserStorage.GetOne(reset.UserId)
+	if err == sql.ErrNoRows {
+		abortWithValidationErrors(c, invalidToken)
+		return
+	} else if err != nil {
+		abortWithError(c, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	newUser := model.NewUser{
+		Username:             user.Username,
+		Email:                user.Email,
+		Password:             body.Password,
+		PasswordConfirmation: body.PasswordConfirmation,
+	}
+	if errs := newUser.ValidatePassword(); len(errs) > 0 {
+		abortWithValidationErrors(c, errs)
+		return
+	}
+
+	// Spend the token before changing anything so it works at most once
+	err = h.PasswordResetStorage.Use(reset)
+	if err == sql.ErrNoRows {
+		abortWithValidationErrors(c, invalidToken)
+		return
+	} else if err != nil {
+		abortWithError(c, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	user.PasswordHash, err = model.HashPassword(body.Password)
+	if err == nil {
+		err = h.UserStorage.Update(user, nil, nil)
+	}
+
+	if err != nil {
+		abortWithError(c, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	c.AbortWithStatus(http.StatusNoContent)
+}
+
+// sendResetLink stores a new reset token for the user and mails it to them
+func (h PasswordReset) sendResetLink(user *model.User) error {
+	token, err := auth.GenerateSecret()
+	if err != nil {
+		return err
+	}
+
+	ttl := h.TTL
+	if ttl <= 0 {
+		ttl = DefaultPasswordResetTTL
+	}
+
+	err = h.PasswordResetStorage.Insert(model.PasswordReset{
+		ExpiresAt: time.Now().Add(ttl),
+		UserId:    user.GetID(),
+		TokenHash: auth.HashSecret(token),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s?token=%s", h.ResetURL, url.QueryEscape(token))
+
+	return h.Mailer.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Someone asked to reset the password for your account. If it was you, "+
+			"follow this link within %s to choose a new one:\n\n%s\n\n"+
+			"If it was not you, you can ignore this email.\n",
+			user.Username,
+			ttl,
+			link),
+	})
+}
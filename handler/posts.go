@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"database/sql"
+	"github.com/gin-gonic/gin"
+	"github.com/timrourke/timrourke.com/model"
+	"github.com/timrourke/timrourke.com/sanitize"
+	"github.com/timrourke/timrourke.com/storage"
+	"html/template"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Posts serves the public page of each published post
+type Posts struct {
+	PostStorage *storage.PostStorage
+	Sanitizer   *sanitize.Policy
+}
+
+// postPage is the data the post page is rendered from
+type postPage struct {
+	Post    model.Post
+	Content template.HTML
+}
+
+var postTemplate = newPageTemplate("post", `
+{{define "title"}}{{.Post.Title}}{{end}}
+{{define "content"}}
+		<article class="post">
+			<header class="post__header">
+				<h1 class="post__title">{{.Post.Title}}</h1>
+				<time datetime="{{.Post.PublishedAt.Format "2006-01-02T15:04:05Z07:00"}}">{{.Post.PublishedAt.Format "January 2, 2006"}}</time>
+			</header>
+			<div class="post__content">
+{{.Content}}
+			</div>
+		</article>
+{{end}}
+`)
+
+// PostURL is the public URL of a post
+func PostURL(post model.Post) string {
+	return "/posts/" + url.PathEscape(post.Permalink)
+}
+
+// Show renders a published post. Permalinks a post used to have redirect to
+// its current one, so renaming a post keeps links to it working.
+func (h Posts) Show(c *gin.Context) {
+	permalink := c.Param("permalink")
+
+	post, err := h.PostStorage.GetByPermalink(permalink)
+	if err == sql.ErrNoRows {
+		h.redirectOldPermalink(c, permalink)
+		return
+	} else if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if !post.IsPublic(time.Now()) {
+		renderNotFound(c)
+		return
+	}
+
+	// Post content is HTML written in the admin's editor, or rendered from
+	// markdown when it was saved
+	renderPage(c, http.StatusOK, postTemplate, postPage{
+		Post:    *post,
+		Content: template.HTML(post.HTML(h.Sanitizer)),
+	})
+}
+
+// redirectOldPermalink permanently redirects a permalink a post used to have
+// to its current URL, or renders the not found page
+func (h Posts) redirectOldPermalink(c *gin.Context, permalink string) {
+	post, err := h.PostStorage.GetByOldPermalink(permalink)
+	if err == sql.ErrNoRows || (err == nil && !post.IsPublic(time.Now())) {
+		renderNotFound(c)
+		return
+	} else if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.Redirect(http.StatusMovedPermanently, PostURL(*post))
+}
@@ -0,0 +1,228 @@
+package handler
+
+import (
+	"database/sql"
+	"github.com/gin-gonic/gin"
+	"github.com/manyminds/api2go/jsonapi"
+	"github.com/timrourke/timrourke.com/auth"
+	"github.com/timrourke/timrourke.com/clientip"
+	"github.com/timrourke/timrourke.com/diff"
+	"github.com/timrourke/timrourke.com/model"
+	"github.com/timrourke/timrourke.com/sanitize"
+	"github.com/timrourke/timrourke.com/storage"
+	"net/http"
+	"strconv"
+)
+
+// currentRevision stands for a post's live fields in place of a revision id
+const currentRevision = "current"
+
+// Revisions handles comparing a post's revisions and restoring them. Listing
+// revisions is left to the post-revisions resource.
+type Revisions struct {
+	Authenticator       *auth.Authenticator
+	PostStorage         *storage.PostStorage
+	PostRevisionStorage *storage.PostRevisionStorage
+	AuditEventStorage   *storage.AuditEventStorage
+	Sanitizer           *sanitize.Policy
+	ExcerptLength       int
+}
+
+// revisionDiff is the response to comparing two revisions, holding only the
+// fields that differ
+type revisionDiff struct {
+	From    string                  `json:"from"`
+	To      string                  `json:"to"`
+	Changes map[string][]diff.Chunk `json:"changes"`
+}
+
+// Diff compares two revisions of a post, named by the from and to query
+// params. Either may be "current" for the post as it is now, and to defaults
+// to it.
+func (h Revisions) Diff(c *gin.Context) {
+	if _, ok := h.authenticate(c, model.ScopePostsRead); !ok {
+		return
+	}
+
+	post, ok := h.findPost(c)
+	if !ok {
+		return
+	}
+
+	fromID := c.Query("from")
+	toID := c.DefaultQuery("to", currentRevision)
+	if len(fromID) == 0 {
+		abortWithError(c, http.StatusBadRequest, "A revision to compare from is required")
+		return
+	}
+
+	from, ok := h.findRevision(c, post, fromID)
+	if !ok {
+		return
+	}
+
+	to, ok := h.findRevision(c, post, toID)
+	if !ok {
+		return
+	}
+
+	changes := map[string][]diff.Chunk{}
+	fields := []struct {
+		name     string
+		from, to string
+	}{
+		{"title", from.Title, to.Title},
+		{"excerpt", from.Excerpt, to.Excerpt},
+		{"content", from.Content, to.Content},
+		{"permalink", from.Permalink, to.Permalink},
+	}
+
+	for _, field := range fields {
+		if field.from != field.to {
+			changes[field.name] = diff.Words(field.from, field.to)
+		}
+	}
+
+	c.JSON(http.StatusOK, revisionDiff{
+		From:    fromID,
+		To:      toID,
+		Changes: changes,
+	})
+}
+
+// Restore copies a revision's fields back onto its post. The fields being
+// replaced are saved as a revision first, so a restore can itself be undone.
+func (h Revisions) Restore(c *gin.Context) {
+	user, ok := h.authenticate(c, model.ScopePostsWrite)
+	if !ok {
+		return
+	}
+
+	post, ok := h.findPost(c)
+	if !ok {
+		return
+	}
+
+	if !user.CanUpdatePost(*post) {
+		abortWithError(c, http.StatusForbidden, "You may only change your own posts")
+		return
+	}
+
+	revision, ok := h.findRevision(c, post, c.Param("revision"))
+	if !ok {
+		return
+	}
+
+	before := *post
+	revision.Restore(post)
+	post.DeriveContent()
+	post.Sanitize(h.Sanitizer)
+	post.GenerateExcerpt(h.ExcerptLength, &before)
+
+	// The revision's permalink may have gone to another post since
+	permalink, err := h.PostStorage.UniquePermalink(post.Permalink, post.ID)
+	if err != nil {
+		abortWithError(c, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	post.Permalink = permalink
+
+	actorID := user.GetID()
+	audit := h.AuditEventStorage.Record(model.AuditEvent{
+		Action:       model.AuditActionUpdate,
+		ResourceType: "posts",
+		ResourceId:   post.GetID(),
+		ClientIP:     clientip.FromRequest(c.Request),
+		ActorId:      &actorID,
+	}, before)
+
+	err = h.PostStorage.Update(post, user.GetID(), audit)
+	if err == storage.ErrStaleVersion || err == storage.ErrPermalinkTaken {
+		abortWithError(c, http.StatusConflict, err.Error())
+		return
+	} else if err != nil {
+		abortWithError(c, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	restored, err := h.PostStorage.GetOne(post.GetID())
+	if err != nil {
+		abortWithError(c, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	body, err := jsonapi.Marshal(restored)
+	if err != nil {
+		abortWithError(c, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	c.Data(http.StatusOK, "application/vnd.api+json", body)
+}
+
+// authenticate resolves the current user, and writes a 401 or 403 error unless
+// they logged in or hold a token with the given scope
+func (h Revisions) authenticate(c *gin.Context, scope string) (*model.User, bool) {
+	session, err := h.Authenticator.Authenticate(c.Request)
+	if err == auth.ErrTwoFactorRequired {
+		abortWithError(c, http.StatusForbidden, err.Error())
+		return nil, false
+	} else if err != nil {
+		abortWithError(c, http.StatusUnauthorized, err.Error())
+		return nil, false
+	}
+
+	if !session.Allows(scope) {
+		abortWithError(c, http.StatusForbidden, "This token does not have the "+scope+" scope")
+		return nil, false
+	}
+
+	return session.User, true
+}
+
+// findPost loads the post named in the path, writing a 400, 404 or 500 error
+// when it cannot
+func (h Revisions) findPost(c *gin.Context) (*model.Post, bool) {
+	id := c.Param("id")
+	if _, err := strconv.ParseInt(id, 10, 64); err != nil {
+		abortWithError(c, http.StatusBadRequest, "Post id must be integer: "+id)
+		return nil, false
+	}
+
+	post, err := h.PostStorage.GetOne(id)
+	if err == sql.ErrNoRows {
+		abortWithError(c, http.StatusNotFound, "No post found with the id: "+id)
+		return nil, false
+	} else if err != nil {
+		abortWithError(c, http.StatusInternalServerError, "Internal Server Error")
+		return nil, false
+	}
+
+	return post, true
+}
+
+// findRevision loads a revision of a post, or the post's current fields for
+// "current", writing a 400, 404 or 500 error when it cannot. Revisions of
+// other posts are not found.
+func (h Revisions) findRevision(c *gin.Context, post *model.Post, id string) (*model.PostRevision, bool) {
+	if id == currentRevision {
+		revision := model.RevisionOf(*post)
+		return &revision, true
+	}
+
+	if _, err := strconv.ParseInt(id, 10, 64); err != nil {
+		abortWithError(c, http.StatusBadRequest, "Post revision id must be integer: "+id)
+		return nil, false
+	}
+
+	revision, err := h.PostRevisionStorage.GetOne(id)
+	if err == sql.ErrNoRows || (err == nil && revision.PostId != post.GetID()) {
+		abortWithError(c, http.StatusNotFound, "No post revision found with the id: "+id)
+		return nil, false
+	} else if err != nil {
+		abortWithError(c, http.StatusInternalServerError, "Internal Server Error")
+		return nil, false
+	}
+
+	return revision, true
+}
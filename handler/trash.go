@@ -0,0 +1,248 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/manyminds/api2go"
+	"github.com/manyminds/api2go/jsonapi"
+	"github.com/timrourke/timrourke.com/auth"
+	"github.com/timrourke/timrourke.com/clientip"
+	"github.com/timrourke/timrourke.com/model"
+	"github.com/timrourke/timrourke.com/query"
+	"github.com/timrourke/timrourke.com/resource"
+	"github.com/timrourke/timrourke.com/storage"
+	"net/http"
+	"strconv"
+)
+
+// Trash handles listing the posts and users in the trash and taking them back
+// out. Moving them in is left to deleting them through their resources, and
+// emptying the trash to the purge job.
+type Trash struct {
+	Authenticator     *auth.Authenticator
+	PostStorage       *storage.PostStorage
+	UserStorage       *storage.UserStorage
+	AuditEventStorage *storage.AuditEventStorage
+}
+
+// Posts lists the posts in the trash, taking the same filter, sort and page
+// params as the posts resource. Editors see every post in the trash, and
+// everyone else only the ones they wrote or co-wrote.
+func (h Trash) Posts(c *gin.Context) {
+	user, ok := h.authenticate(c, model.ScopePostsRead)
+	if !ok {
+		return
+	}
+
+	q, ok := h.parseQueryParams(c, resource.PostFilterableFields)
+	if !ok {
+		return
+	}
+
+	if !user.CanEditOthersPosts() {
+		q.Where("(posts.user_id = :trashUserID OR posts.id IN (SELECT post_authors.post_id FROM post_authors WHERE post_authors.user_id = :trashUserID))")
+		q.Bind("trashUserID", user.GetID())
+	}
+
+	count, posts, err := h.PostStorage.GetTrash(q)
+	if err != nil {
+		abortWithError(c, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	h.render(c, http.StatusOK, posts, count)
+}
+
+// RestorePost takes a post out of the trash, for anyone who may delete it
+func (h Trash) RestorePost(c *gin.Context) {
+	user, ok := h.authenticate(c, model.ScopePostsWrite)
+	if !ok {
+		return
+	}
+
+	id := c.Param("id")
+	if _, err := strconv.ParseInt(id, 10, 64); err != nil {
+		abortWithError(c, http.StatusBadRequest, "Post id must be integer: "+id)
+		return
+	}
+
+	post, err := h.PostStorage.GetTrashed(id)
+	if err == sql.ErrNoRows {
+		abortWithError(c, http.StatusNotFound, "No post in the trash found with the id: "+id)
+		return
+	} else if err != nil {
+		abortWithError(c, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	if !user.CanDeletePost(*post) {
+		abortWithError(c, http.StatusForbidden, "You may only restore posts you may delete")
+		return
+	}
+
+	before := *post
+
+	// Someone else restoring the post first leaves nothing to restore
+	err = h.PostStorage.Restore(post, h.restoreAudit(c, user, "posts", id, before))
+	if err == sql.ErrNoRows {
+		abortWithError(c, http.StatusNotFound, "No post in the trash found with the id: "+id)
+		return
+	} else if err != nil {
+		abortWithError(c, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	restored, err := h.PostStorage.GetOne(id)
+	if err != nil {
+		abortWithError(c, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	h.render(c, http.StatusOK, restored, 0)
+}
+
+// Users lists the users in the trash, taking the same filter, sort and page
+// params as the users resource, for admins only
+func (h Trash) Users(c *gin.Context) {
+	if _, ok := h.authenticateAdmin(c); !ok {
+		return
+	}
+
+	q, ok := h.parseQueryParams(c, resource.AdminUserFilterableFields)
+	if !ok {
+		return
+	}
+
+	count, users, err := h.UserStorage.GetTrash(q)
+	if err != nil {
+		abortWithError(c, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	h.render(c, http.StatusOK, users, count)
+}
+
+// RestoreUser takes a user out of the trash, letting them log in again, for
+// admins only
+func (h Trash) RestoreUser(c *gin.Context) {
+	admin, ok := h.authenticateAdmin(c)
+	if !ok {
+		return
+	}
+
+	id := c.Param("id")
+	if _, err := strconv.ParseInt(id, 10, 64); err != nil {
+		abortWithError(c, http.StatusBadRequest, "User id must be integer: "+id)
+		return
+	}
+
+	user, err := h.UserStorage.GetTrashed(id)
+	if err == sql.ErrNoRows {
+		abortWithError(c, http.StatusNotFound, "No user in the trash found with the id: "+id)
+		return
+	} else if err != nil {
+		abortWithError(c, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	before := *user
+
+	err = h.UserStorage.Restore(user, h.restoreAudit(c, admin, "users", id, before))
+	if err == sql.ErrNoRows {
+		abortWithError(c, http.StatusNotFound, "No user in the trash found with the id: "+id)
+		return
+	} else if err != nil {
+		abortWithError(c, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	h.render(c, http.StatusOK, user, 0)
+}
+
+// authenticate resolves the current user, and writes a 401 or 403 error unless
+// they logged in or hold a token with the given scope
+func (h Trash) authenticate(c *gin.Context, scope string) (*model.User, bool) {
+	session, err := h.Authenticator.Authenticate(c.Request)
+	if err == auth.ErrTwoFactorRequired {
+		abortWithError(c, http.StatusForbidden, err.Error())
+		return nil, false
+	} else if err != nil {
+		abortWithError(c, http.StatusUnauthorized, err.Error())
+		return nil, false
+	}
+
+	if !session.Allows(scope) {
+		abortWithError(c, http.StatusForbidden, "This token does not have the "+scope+" scope")
+		return nil, false
+	}
+
+	return session.User, true
+}
+
+// authenticateAdmin resolves the current user, and writes a 401 or 403 error
+// unless they are an admin allowed to manage users
+func (h Trash) authenticateAdmin(c *gin.Context) (*model.User, bool) {
+	user, ok := h.authenticate(c, model.ScopeUsersAdmin)
+	if !ok {
+		return nil, false
+	}
+
+	if !user.IsAdmin() {
+		abortWithError(c, http.StatusForbidden, "Only admins may manage users in the trash")
+		return nil, false
+	}
+
+	return user, true
+}
+
+// parseQueryParams builds a query from the request's filter, sort and page
+// params, writing a 400 error when they are invalid
+func (h Trash) parseQueryParams(c *gin.Context, filterableFields map[string]bool) (*query.Query, bool) {
+	r := api2go.Request{
+		PlainRequest: c.Request,
+		QueryParams:  c.Request.URL.Query(),
+	}
+
+	q, err := resource.ParseQueryParams(r, filterableFields, nil)
+	if err != nil {
+		abortWithError(c, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+
+	return q, true
+}
+
+// restoreAudit returns the audit recording a post or user being restored
+func (h Trash) restoreAudit(c *gin.Context, user *model.User, resourceType, id string, before interface{}) storage.Audit {
+	actorID := user.GetID()
+	return h.AuditEventStorage.Record(model.AuditEvent{
+		Action:       model.AuditActionRestore,
+		ResourceType: resourceType,
+		ResourceId:   id,
+		ClientIP:     clientip.FromRequest(c.Request),
+		ActorId:      &actorID,
+	}, before)
+}
+
+// render writes a jsonapi document, with the total number of matches in its
+// meta when listing
+func (h Trash) render(c *gin.Context, status int, data interface{}, count uint) {
+	document, err := jsonapi.MarshalToStruct(data, nil)
+	if err != nil {
+		abortWithError(c, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	if document.Data != nil && document.Data.DataArray != nil {
+		document.Meta = map[string]interface{}{"total": count}
+	}
+
+	body, err := json.Marshal(document)
+	if err != nil {
+		abortWithError(c, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	c.Data(status, "application/vnd.api+json", body)
+}
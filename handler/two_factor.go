@@ -0,0 +1,273 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/timrourke/timrourke.com/auth"
+	"github.com/timrourke/timrourke.com/model"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// TwoFactor handles enrolling in and leaving TOTP two-factor authentication,
+// and the policy of which roles must use it
+type TwoFactor struct {
+	Authenticator *auth.Authenticator
+
+	// Issuer names the site in users' authenticator apps
+	Issuer string
+}
+
+// twoFactorEnrollment is the response to starting enrollment
+type twoFactorEnrollment struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth-uri"`
+}
+
+// twoFactorCode is the body of a request that proves possession of a second
+// factor
+type twoFactorCode struct {
+	Code     string `json:"code"`
+	Password string `json:"password"`
+}
+
+// twoFactorPolicy is the body of the policy endpoints
+type twoFactorPolicy struct {
+	Roles []string `json:"roles"`
+}
+
+// Enroll generates a new TOTP secret for the current user. Two-factor
+// authentication is not enforced until a code from it is confirmed.
+func (h TwoFactor) Enroll(c *gin.Context) {
+	user, ok := h.authenticate(c)
+	if !ok {
+		return
+	}
+
+	if user.HasTwoFactor() {
+		abortWithError(c, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		abortWithError(c, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	user.TOTPSecret = &secret
+	user.TOTPEnabledAt = nil
+	if err = h.Authenticator.UserStorage.UpdateTwoFactor(user); err != nil {
+		abortWithError(c, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	c.JSON(http.StatusOK, twoFactorEnrollment{
+		Secret:     secret,
+		OTPAuthURI: auth.TOTPURI(h.Issuer, user.Username, secret),
+	})
+}
+
+// Confirm enables two-factor authentication once the user proves their app
+// produces valid codes, and responds with a fresh set of recovery codes. The
+// user must log in again with a code afterwards.
+func (h TwoFactor) Confirm(c *gin.Context) {
+	user, ok := h.authenticate(c)
+	if !ok {
+		return
+	}
+
+	var body twoFactorCode
+	if err := json.NewDecoder(c.Request.Body).Decode(&body); err != nil || len(body.Code) == 0 {
+		abortWithError(c, http.StatusBadRequest, "A code is required")
+		return
+	}
+
+	if user.HasTwoFactor() {
+		abortWithError(c, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	} else if user.TOTPSecret == nil {
+		abortWithError(c, http.StatusConflict, "Two-factor enrollment has not been started")
+		return
+	}
+
+	step, ok := auth.ValidateTOTP(*user.TOTPSecret, body.Code, time.Now(), user.TOTPLastStep)
+	if !ok {
+		abortWithValidationErrors(c, []model.ValidationError{
+			{Attribute: "code", Message: "is invalid"},
+		})
+		return
+	}
+
+	codes, err := auth.GenerateRecoveryCodes()
+	if err != nil {
+		abortWithError(c, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	codeHashes := make([]string, len(codes))
+	for i, code := range codes {
+		codeHashes[i] = auth.HashSecret(auth.NormalizeRecoveryCode(code))
+	}
+
+	now := time.Now()
+	user.TOTPEnabledAt = &now
+
+	err = h.Authenticator.RecoveryCodeStorage.Replace(user.GetID(), codeHashes)
+	if err == nil {
+		err = h.Authenticator.UserStorage.UpdateTwoFactor(user)
+	}
+	if err == nil {
+		err = h.Authenticator.UserStorage.UseTOTPStep(user, step)
+	}
+
+	if err != nil {
+		abortWithError(c, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"recovery-codes": codes,
+	})
+}
+
+// Disable turns off two-factor authentication for the current user, who must
+// give both their password and a code. Users whose role requires two-factor
+// authentication may not turn it off.
+func (h TwoFactor) Disable(c *gin.Context) {
+	user, ok := h.authenticate(c)
+	if !ok {
+		return
+	}
+
+	var body twoFactorCode
+	if err := json.NewDecoder(c.Request.Body).Decode(&body); err != nil || len(body.Code) == 0 {
+		abortWithError(c, http.StatusBadRequest, "A password and code are required")
+		return
+	}
+
+	required, err := h.Authenticator.RequiresTwoFactor(user)
+	if err != nil {
+		abortWithError(c, http.StatusInternalServerError, "Internal Server Error")
+		return
+	} else if required {
+		abortWithError(c, http.StatusForbidden, "Your role requires two-factor authentication")
+		return
+	}
+
+	if !user.CheckPassword(body.Password) {
+		abortWithError(c, http.StatusUnauthorized, "Invalid password")
+		return
+	}
+
+	err = h.Authenticator.VerifySecondFactor(user, body.Code)
+	if err == auth.ErrInvalidSecondFactor {
+		abortWithError(c, http.StatusUnauthorized, err.Error())
+		return
+	} else if err != nil {
+		abortWithError(c, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	user.TOTPSecret = nil
+	user.TOTPEnabledAt = nil
+
+	err = h.Authenticator.UserStorage.UpdateTwoFactor(user)
+	if err == nil {
+		err = h.Authenticator.RecoveryCodeStorage.DeleteForUser(user.GetID())
+	}
+
+	if err != nil {
+		abortWithError(c, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	c.AbortWithStatus(http.StatusNoContent)
+}
+
+// GetPolicy responds with the roles that must use two-factor authentication
+func (h TwoFactor) GetPolicy(c *gin.Context) {
+	if _, ok := h.authenticateAdmin(c); !ok {
+		return
+	}
+
+	value, err := h.Authenticator.SettingStorage.Get(model.SettingTwoFactorRoles)
+	if err != nil {
+		abortWithError(c, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	c.JSON(http.StatusOK, twoFactorPolicy{Roles: model.ParseList(value)})
+}
+
+// UpdatePolicy sets the roles that must use two-factor authentication
+func (h TwoFactor) UpdatePolicy(c *gin.Context) {
+	if _, ok := h.authenticateAdmin(c); !ok {
+		return
+	}
+
+	var body twoFactorPolicy
+	if err := json.NewDecoder(c.Request.Body).Decode(&body); err != nil || body.Roles == nil {
+		abortWithError(c, http.StatusBadRequest, "A list of roles is required")
+		return
+	}
+
+	for _, role := range body.Roles {
+		if !model.Roles[role] {
+			abortWithValidationErrors(c, []model.ValidationError{
+				{Attribute: "roles", Message: "must only contain admin, editor, author or contributor"},
+			})
+			return
+		}
+	}
+
+	err := h.Authenticator.SettingStorage.Set(model.SettingTwoFactorRoles, strings.Join(body.Roles, ","))
+	if err != nil {
+		abortWithError(c, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	c.JSON(http.StatusOK, body)
+}
+
+// authenticate resolves the current user without enforcing the two-factor
+// policy, writing a 401 error when there is none
+func (h TwoFactor) authenticate(c *gin.Context) (*model.User, bool) {
+	user, err := h.Authenticator.AuthenticateForEnrollment(c.Request)
+	if err == auth.ErrLoginRequired {
+		abortWithError(c, http.StatusForbidden, err.Error())
+		return nil, false
+	} else if err != nil {
+		abortWithError(c, http.StatusUnauthorized, err.Error())
+		return nil, false
+	}
+
+	return user, true
+}
+
+// authenticateAdmin resolves the current user, enforcing the two-factor
+// policy, and writes a 401 or 403 error unless they are an admin who logged in
+func (h TwoFactor) authenticateAdmin(c *gin.Context) (*model.User, bool) {
+	session, err := h.Authenticator.Authenticate(c.Request)
+	if err == auth.ErrTwoFactorRequired {
+		abortWithError(c, http.StatusForbidden, err.Error())
+		return nil, false
+	} else if err != nil {
+		abortWithError(c, http.StatusUnauthorized, err.Error())
+		return nil, false
+	}
+
+	if session.IsPersonalToken() {
+		abortWithError(c, http.StatusForbidden, auth.ErrLoginRequired.Error())
+		return nil, false
+	}
+
+	user := session.User
+	if !user.IsAdmin() {
+		abortWithError(c, http.StatusForbidden, "Only admins may change the two-factor policy")
+		return nil, false
+	}
+
+	return user, true
+}
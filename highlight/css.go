@@ -0,0 +1,96 @@
+package highlight
+
+import (
+	"bytes"
+)
+
+// DefaultTheme is the theme used unless another is chosen
+const DefaultTheme = "light"
+
+// Style is how tokens of a kind look
+type Style struct {
+	Color  string
+	Bold   bool
+	Italic bool
+}
+
+// Theme is how highlighted code looks: the colors of its blocks, and the
+// style of each kind of token. Kinds without a style look like plain text.
+type Theme struct {
+	Background string
+	Foreground string
+	Styles     map[Kind]Style
+}
+
+// Themes are the themes highlighted code can be styled with, by name
+var Themes = map[string]Theme{
+	"light": {
+		Background: "#f6f8fa",
+		Foreground: "#24292e",
+		Styles: map[Kind]Style{
+			Keyword:   {Color: "#d73a49", Bold: true},
+			Builtin:   {Color: "#005cc5"},
+			Function:  {Color: "#6f42c1"},
+			Literal:   {Color: "#005cc5"},
+			String:    {Color: "#032f62"},
+			Number:    {Color: "#005cc5"},
+			Comment:   {Color: "#6a737d", Italic: true},
+			Variable:  {Color: "#e36209"},
+			Tag:       {Color: "#22863a"},
+			Attribute: {Color: "#6f42c1"},
+		},
+	},
+	"dark": {
+		Background: "#272822",
+		Foreground: "#f8f8f2",
+		Styles: map[Kind]Style{
+			Keyword:   {Color: "#f92672"},
+			Builtin:   {Color: "#66d9ef", Italic: true},
+			Function:  {Color: "#a6e22e"},
+			Literal:   {Color: "#ae81ff"},
+			String:    {Color: "#e6db74"},
+			Number:    {Color: "#ae81ff"},
+			Comment:   {Color: "#75715e", Italic: true},
+			Variable:  {Color: "#fd971f"},
+			Tag:       {Color: "#f92672"},
+			Attribute: {Color: "#a6e22e"},
+		},
+	},
+}
+
+// CSS generates the stylesheet for a theme, styling code blocks and the
+// classes HTML marks tokens up with
+func CSS(theme Theme) string {
+	var b bytes.Buffer
+
+	b.WriteString(`pre.ql-syntax, pre > code[class*="language-"] {` + "\n")
+	writeDeclaration(&b, "background-color", theme.Background)
+	writeDeclaration(&b, "color", theme.Foreground)
+	b.WriteString("}\n")
+
+	for _, kind := range kinds {
+		style, ok := theme.Styles[kind]
+		if !ok {
+			continue
+		}
+
+		b.WriteString("\n." + kind.Class() + " {\n")
+		writeDeclaration(&b, "color", style.Color)
+		if style.Bold {
+			writeDeclaration(&b, "font-weight", "bold")
+		}
+		if style.Italic {
+			writeDeclaration(&b, "font-style", "italic")
+		}
+		b.WriteString("}\n")
+	}
+
+	return b.String()
+}
+
+// writeDeclaration writes a single CSS declaration, unless it has no value
+func writeDeclaration(b *bytes.Buffer, property, value string) {
+	if len(value) > 0 {
+		b.WriteString("\t" + property + ": " + value + ";\n")
+	}
+}
@@ -0,0 +1,105 @@
+Feature: highlight code
+	In order to read code in posts without waiting on scripts
+	As a reader of timrourke.com
+	I need code blocks highlighted on the server
+
+	Scenario: Highlight Go
+		When I highlight the "go" code:
+			"""
+			// Hello says hi
+			func Hello(n int) string {
+				return fmt.Sprintf(`hi %d`, n)
+			}
+			"""
+		Then the HTML should be:
+			"""
+			<span class="hl-comment">// Hello says hi</span>
+			<span class="hl-keyword">func</span> <span class="hl-function">Hello</span>(n <span class="hl-builtin">int</span>) <span class="hl-builtin">string</span> {
+				<span class="hl-keyword">return</span> fmt.<span class="hl-function">Sprintf</span>(<span class="hl-string">`hi %d`</span>, n)
+			}
+			"""
+
+	Scenario: Highlight JavaScript
+		When I highlight the "js" code:
+			"""
+			const x = 42; // answer
+			console.log(x === null)
+			"""
+		Then the HTML should be:
+			"""
+			<span class="hl-keyword">const</span> x = <span class="hl-number">42</span>; <span class="hl-comment">// answer</span>
+			<span class="hl-builtin">console</span>.<span class="hl-function">log</span>(x === <span class="hl-literal">null</span>)
+			"""
+
+	Scenario: Highlight SQL
+		When I highlight the "sql" code:
+			"""
+			SELECT COUNT(*) FROM `posts` WHERE id = :id -- one
+			"""
+		Then the HTML should be:
+			"""
+			<span class="hl-keyword">SELECT</span> <span class="hl-builtin">COUNT</span>(*) <span class="hl-keyword">FROM</span> <span class="hl-variable">`posts`</span> <span class="hl-keyword">WHERE</span> id = <span class="hl-variable">:id</span> <span class="hl-comment">-- one</span>
+			"""
+
+	Scenario: Highlight shell
+		When I highlight the "bash" code:
+			"""
+			if [ -f go.mod ]; then echo $HOME --verbose; fi
+			"""
+		Then the HTML should be:
+			"""
+			<span class="hl-keyword">if</span> [ <span class="hl-attribute">-f</span> go.mod ]; <span class="hl-keyword">then</span> <span class="hl-builtin">echo</span> <span class="hl-variable">$HOME</span> <span class="hl-attribute">--verbose</span>; <span class="hl-keyword">fi</span>
+			"""
+
+	Scenario: Highlight YAML
+		When I highlight the "yaml" code:
+			"""
+			name: site # comment
+			ports:
+			  - 8000
+			enabled: true
+			"""
+		Then the HTML should be:
+			"""
+			<span class="hl-attribute">name</span>: site <span class="hl-comment"># comment</span>
+			<span class="hl-attribute">ports</span>:
+			  - <span class="hl-number">8000</span>
+			<span class="hl-attribute">enabled</span>: <span class="hl-literal">true</span>
+			"""
+
+	Scenario: Highlight HTML
+		When I highlight the "html" code:
+			"""
+			<a href=/x disabled>Tom &amp; Jerry</a><!-- note -->
+			"""
+		Then the HTML should be:
+			"""
+			<span class="hl-tag">&lt;a</span> <span class="hl-attribute">href</span>=<span class="hl-string">/x</span> <span class="hl-attribute">disabled</span><span class="hl-tag">&gt;</span>Tom <span class="hl-literal">&amp;amp;</span> Jerry<span class="hl-tag">&lt;/a&gt;</span><span class="hl-comment">&lt;!-- note --&gt;</span>
+			"""
+
+	Scenario: Highlight the code blocks in post HTML
+		When I highlight the HTML:
+			"""
+			<p>Code:</p><pre><code class="language-go">x := <span class="hl-keyword">nil</span></code></pre><pre class="ql-syntax" spellcheck="false">SELECT 1;</pre><pre><code class="language-cobol">MOVE A</code></pre>
+			"""
+		Then the HTML should be:
+			"""
+			<p>Code:</p><pre><code class="language-go">x := <span class="hl-literal">nil</span></code></pre><pre class="ql-syntax" spellcheck="false"><span class="hl-keyword">SELECT</span> <span class="hl-number">1</span>;</pre><pre><code class="language-cobol">MOVE A</code></pre>
+			"""
+
+	Scenario: Detect the language of editor code blocks
+		Then the language of "package main" should be detected as "go"
+		And the language of "SELECT * FROM posts" should be detected as "sql"
+		And the language of "$ go test ./..." should be detected as "shell"
+		And the language of "const a = () => 1" should be detected as "javascript"
+		And the language of "<p>Hi</p>" should be detected as "html"
+		And the language of "just some words" should be detected as ""
+
+	Scenario: Generate the CSS for a theme
+		Then the CSS for the "dark" theme should contain:
+			"""
+			.hl-comment {
+				color: #75715e;
+				font-style: italic;
+			}
+			"""
@@ -0,0 +1,200 @@
+// Package highlight marks up the code blocks in post HTML so they can be
+// styled by the stylesheet CSS generates, without any highlighting in the
+// reader's browser
+package highlight
+
+import (
+	"bytes"
+	"golang.org/x/net/html"
+	"io"
+	"strings"
+)
+
+// Kind is the kind of a token, which decides how it is styled
+type Kind int
+
+const (
+	Plain Kind = iota
+	Keyword
+	Builtin
+	Function
+	Literal
+	String
+	Number
+	Comment
+	Variable
+	Tag
+	Attribute
+)
+
+// kinds is every styled kind of token, in the order their styles are written
+var kinds = []Kind{Keyword, Builtin, Function, Literal, String, Number, Comment, Variable, Tag, Attribute}
+
+// classes are the classes tokens of each kind are marked up with
+var classes = map[Kind]string{
+	Keyword:   "hl-keyword",
+	Builtin:   "hl-builtin",
+	Function:  "hl-function",
+	Literal:   "hl-literal",
+	String:    "hl-string",
+	Number:    "hl-number",
+	Comment:   "hl-comment",
+	Variable:  "hl-variable",
+	Tag:       "hl-tag",
+	Attribute: "hl-attribute",
+}
+
+// Class returns the class tokens of this kind are marked up with, which is
+// empty for plain text
+func (k Kind) Class() string {
+	return classes[k]
+}
+
+// Token is a run of code of a single kind
+type Token struct {
+	Kind Kind
+	Text string
+}
+
+// Code highlights code in a language, returning HTML with each token that is
+// not plain text wrapped in a span classed by its kind. It reports false for
+// languages it does not know.
+func Code(code, language string) (string, bool) {
+	lexer, ok := lexers[strings.ToLower(language)]
+	if !ok {
+		return "", false
+	}
+
+	var b bytes.Buffer
+	for _, token := range lexer(code) {
+		text := html.EscapeString(token.Text)
+		if token.Kind == Plain {
+			b.WriteString(text)
+		} else {
+			b.WriteString(`<span class="` + token.Kind.Class() + `">` + text + "</span>")
+		}
+	}
+
+	return b.String(), true
+}
+
+// HTML highlights the code blocks in a fragment of HTML. Blocks marked up as
+// <pre><code class="language-xxx">, as markdown and deltas render them, are
+// highlighted in their language. The editor's <pre class="ql-syntax"> blocks
+// do not say their language, so it is detected. Any markup inside a block is
+// replaced, so highlighting highlighted HTML changes nothing. Blocks in
+// languages that are not known are left as they are.
+func HTML(fragment string) string {
+	var b bytes.Buffer
+
+	tokenizer := html.NewTokenizer(strings.NewReader(fragment))
+	for {
+		if tokenizer.Next() == html.ErrorToken {
+			if tokenizer.Err() != io.EOF {
+				return fragment
+			}
+			break
+		}
+
+		raw := string(tokenizer.Raw())
+		token := tokenizer.Token()
+
+		if token.Type == html.StartTagToken && token.Data == "pre" {
+			b.WriteString(highlightBlock(tokenizer, raw, hasClass(token, "ql-syntax")))
+		} else {
+			b.WriteString(raw)
+		}
+	}
+
+	return b.String()
+}
+
+// highlightBlock reads a <pre> block up to its end tag, whose start tag has
+// already been read as preRaw, and returns it highlighted
+func highlightBlock(tokenizer *html.Tokenizer, preRaw string, editorBlock bool) string {
+	var inner, text bytes.Buffer
+	codeRaw, language := "", ""
+	first := true
+	depth := 1
+
+	for depth > 0 {
+		if tokenizer.Next() == html.ErrorToken {
+			break
+		}
+
+		raw := string(tokenizer.Raw())
+		token := tokenizer.Token()
+
+		switch token.Type {
+		case html.StartTagToken:
+			if token.Data == "pre" {
+				depth++
+			} else if first && token.Data == "code" {
+				codeRaw, language = raw, languageOf(token)
+				first = false
+				continue
+			}
+		case html.EndTagToken:
+			if token.Data == "pre" {
+				depth--
+			}
+		case html.TextToken:
+			text.WriteString(token.Data)
+		}
+
+		if depth > 0 {
+			inner.WriteString(raw)
+		}
+		if token.Type != html.TextToken || len(strings.TrimSpace(token.Data)) > 0 {
+			first = false
+		}
+	}
+
+	code := text.String()
+	if editorBlock && len(codeRaw) == 0 {
+		language = Detect(code)
+	}
+
+	highlighted, ok := Code(code, language)
+	if !ok {
+		return preRaw + codeRaw + inner.String() + "</pre>"
+	}
+
+	if len(codeRaw) > 0 {
+		return preRaw + codeRaw + highlighted + "</code></pre>"
+	}
+
+	return preRaw + highlighted + "</pre>"
+}
+
+// languageOf returns the language a <code> element's language-xxx class
+// names
+func languageOf(token html.Token) string {
+	for _, attr := range token.Attr {
+		if attr.Key != "class" {
+			continue
+		}
+
+		for _, class := range strings.Fields(attr.Val) {
+			if strings.HasPrefix(class, "language-") {
+				return strings.TrimPrefix(class, "language-")
+			}
+		}
+	}
+
+	return ""
+}
+
+func hasClass(token html.Token, name string) bool {
+	for _, attr := range token.Attr {
+		if attr.Key == "class" {
+			for _, class := range strings.Fields(attr.Val) {
+				if class == name {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,56 @@
+package highlight
+
+import (
+	"fmt"
+	"github.com/DATA-DOG/godog"
+	"github.com/DATA-DOG/godog/gherkin"
+	"strings"
+)
+
+var highlighted string
+
+func iHighlightTheCode(language string, code *gherkin.DocString) error {
+	var ok bool
+	highlighted, ok = Code(code.Content, language)
+	if !ok {
+		return fmt.Errorf("expected %s to be highlighted", language)
+	}
+	return nil
+}
+
+func iHighlightTheHTML(fragment *gherkin.DocString) error {
+	highlighted = HTML(fragment.Content)
+	return nil
+}
+
+func theLanguageOfShouldBeDetectedAs(code, language string) error {
+	if detected := Detect(code); detected != language {
+		return fmt.Errorf("expected '%s' to be detected as '%s', but it was '%s'", code, language, detected)
+	}
+	return nil
+}
+
+func theCSSForTheThemeShouldContain(name string, expected *gherkin.DocString) error {
+	css := CSS(Themes[name])
+	if strings.Contains(css, expected.Content) {
+		return nil
+	}
+	return fmt.Errorf("expected CSS to contain '%s', but it was '%s'", expected.Content, css)
+}
+
+func theHTMLShouldBe(expected *gherkin.DocString) error {
+	if strings.TrimSpace(highlighted) == strings.TrimSpace(expected.Content) {
+		return nil
+	}
+	return fmt.Errorf("expected HTML '%s', but it was '%s'",
+		expected.Content,
+		highlighted)
+}
+
+func FeatureContext(s *godog.Suite) {
+	s.Step(`^I highlight the "([^"]*)" code:$`, iHighlightTheCode)
+	s.Step(`^I highlight the HTML:$`, iHighlightTheHTML)
+	s.Step(`^the language of "([^"]*)" should be detected as "([^"]*)"$`, theLanguageOfShouldBeDetectedAs)
+	s.Step(`^the CSS for the "([^"]*)" theme should contain:$`, theCSSForTheThemeShouldContain)
+	s.Step(`^the HTML should be:$`, theHTMLShouldBe)
+}
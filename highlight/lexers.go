@@ -0,0 +1,333 @@
+package highlight
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// lexer splits code into tokens. Joining their text gives back the code.
+type lexer func(code string) []Token
+
+// lexers are the lexers for each language, by the names posts may give it
+var lexers = map[string]lexer{
+	"go":         golang.lex,
+	"golang":     golang.lex,
+	"js":         javascript.lex,
+	"javascript": javascript.lex,
+	"jsx":        javascript.lex,
+	"json":       javascript.lex,
+	"sql":        sql.lex,
+	"mysql":      sql.lex,
+	"sh":         shell.lex,
+	"bash":       shell.lex,
+	"shell":      shell.lex,
+	"console":    shell.lex,
+	"yaml":       yaml.lex,
+	"yml":        yaml.lex,
+	"html":       lexHTML,
+	"xml":        lexHTML,
+}
+
+// rule matches a token at the start of the code left to lex. When the
+// pattern has a group, only the group is taken as the token, and lexing
+// carries on after it.
+type rule struct {
+	kind    Kind
+	pattern *regexp.Regexp
+}
+
+func newRule(kind Kind, pattern string) rule {
+	return rule{kind, regexp.MustCompile(`^(?:` + pattern + `)`)}
+}
+
+// language lexes code by trying its rules in order at each position, then
+// classifying words by the sets they belong to
+type language struct {
+	rules []rule
+	word  *regexp.Regexp
+
+	keywords map[string]bool
+	builtins map[string]bool
+	literals map[string]bool
+
+	// caseInsensitive languages match words in any case
+	caseInsensitive bool
+
+	// calls marks words followed by an opening parenthesis as functions
+	calls bool
+}
+
+func (l language) lex(code string) []Token {
+	var tokens []Token
+	emit := func(kind Kind, text string) {
+		if n := len(tokens); n > 0 && tokens[n-1].Kind == kind {
+			tokens[n-1].Text += text
+			return
+		}
+		tokens = append(tokens, Token{kind, text})
+	}
+
+	for pos := 0; pos < len(code); {
+		rest := code[pos:]
+
+		if kind, length := l.match(rest); length > 0 {
+			emit(kind, rest[:length])
+			pos += length
+			continue
+		}
+
+		if word := l.word.FindString(rest); len(word) > 0 {
+			emit(l.classify(word, rest[len(word):]), word)
+			pos += len(word)
+			continue
+		}
+
+		_, size := utf8.DecodeRuneInString(rest)
+		emit(Plain, rest[:size])
+		pos += size
+	}
+
+	return tokens
+}
+
+// match returns the kind and length of the token the first matching rule
+// finds at the start of the code
+func (l language) match(code string) (Kind, int) {
+	for _, r := range l.rules {
+		loc := r.pattern.FindStringSubmatchIndex(code)
+		if loc == nil {
+			continue
+		}
+
+		if len(loc) > 2 && loc[2] == 0 && loc[3] > 0 {
+			return r.kind, loc[3]
+		} else if loc[1] > 0 {
+			return r.kind, loc[1]
+		}
+	}
+
+	return Plain, 0
+}
+
+// classify returns the kind of a word, given the code following it
+func (l language) classify(word, after string) Kind {
+	key := word
+	if l.caseInsensitive {
+		key = strings.ToLower(word)
+	}
+
+	switch {
+	case l.keywords[key]:
+		return Keyword
+	case l.literals[key]:
+		return Literal
+	case l.builtins[key]:
+		return Builtin
+	case l.calls && strings.HasPrefix(after, "("):
+		return Function
+	}
+
+	return Plain
+}
+
+// set builds a set of words from a space separated list
+func set(words string) map[string]bool {
+	result := map[string]bool{}
+	for _, word := range strings.Fields(words) {
+		result[word] = true
+	}
+
+	return result
+}
+
+var (
+	identifier = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*`)
+	number     = `0[xX][0-9a-fA-F_]+|0[bB][01_]+|[0-9][0-9_]*(?:\.[0-9_]+)?(?:[eE][+-]?[0-9]+)?`
+)
+
+var golang = language{
+	rules: []rule{
+		newRule(Comment, `//[^\n]*|/\*[\s\S]*?(?:\*/|$)`),
+		newRule(String, `"(?:\\.|[^"\\\n])*"?|`+"`[^`]*`?"+`|'(?:\\.|[^'\\\n])+'`),
+		newRule(Number, `(?:`+number+`)i?\b`),
+	},
+	word: regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*`),
+	keywords: set(`break case chan const continue default defer else fallthrough
+		for func go goto if import interface map package range return select
+		struct switch type var`),
+	builtins: set(`bool byte complex64 complex128 error float32 float64 int int8
+		int16 int32 int64 rune string uint uint8 uint16 uint32 uint64 uintptr
+		any append cap close complex copy delete imag len make new panic print
+		println real recover`),
+	literals: set(`true false nil iota`),
+	calls:    true,
+}
+
+var javascript = language{
+	rules: []rule{
+		newRule(Comment, `//[^\n]*|/\*[\s\S]*?(?:\*/|$)`),
+		newRule(String, `"(?:\\.|[^"\\\n])*"?|'(?:\\.|[^'\\\n])*'?|`+"`(?:\\\\.|[^`\\\\])*`?"),
+		newRule(Number, `(?:`+number+`)n?\b`),
+	},
+	word: identifier,
+	keywords: set(`async await break case catch class const continue debugger
+		default delete do else export extends finally for from function if
+		import in instanceof let new of return static super switch this throw
+		try typeof var void while with yield`),
+	builtins: set(`Array Boolean Date Error JSON Map Math Number Object Promise
+		RegExp Set String Symbol console document window require module
+		exports process`),
+	literals: set(`true false null undefined NaN Infinity`),
+	calls:    true,
+}
+
+var sql = language{
+	rules: []rule{
+		newRule(Comment, `--[^\n]*|#[^\n]*|/\*[\s\S]*?(?:\*/|$)`),
+		newRule(String, `'(?:''|\\.|[^'\\])*'?|"(?:""|\\.|[^"\\])*"?`),
+		newRule(Variable, "`[^`]*`?|:[A-Za-z_][A-Za-z0-9_]*|@[A-Za-z_][A-Za-z0-9_]*"),
+		newRule(Number, `(?:`+number+`)\b`),
+	},
+	word:            regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*`),
+	caseInsensitive: true,
+	keywords: set(`add after all alter and as asc auto_increment before begin
+		between by cascade case check column commit constraint create cross
+		database default delete desc distinct drop duplicate else end engine
+		exists foreign from full group having if in index inner insert into is
+		join key left like limit modify not on or order outer primary
+		references rename replace restrict right rollback select set table
+		then to transaction trigger truncate union unique update use using
+		values view when where with`),
+	builtins: set(`avg cast coalesce concat count date datetime decimal enum
+		float group_concat ifnull int integer json length longtext lower max
+		min now sum text timestamp tinyint upper varchar`),
+	literals: set(`true false null`),
+}
+
+var shell = language{
+	rules: []rule{
+		newRule(Comment, `#[^\n]*`),
+		newRule(String, `"(?:\\.|[^"\\])*"?|'[^']*'?`),
+		newRule(Variable, `\$(?:\{[^}\n]*\}?|[A-Za-z_][A-Za-z0-9_]*|[0-9@#?$!*-])`),
+		newRule(Attribute, `--?[A-Za-z0-9][A-Za-z0-9-]*`),
+		newRule(Number, `[0-9]+\b`),
+	},
+	word: regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*`),
+	keywords: set(`case do done elif else esac fi for function if in local
+		return select then until while export`),
+	builtins: set(`alias apt awk brew cat cd chmod chown cp curl docker echo
+		exec exit find git go grep kill ln ls make mkdir mv npm printf pwd read
+		rm sed set source ssh sudo tar touch unset wget yarn`),
+}
+
+var yaml = language{
+	rules: []rule{
+		newRule(Comment, `#[^\n]*`),
+		newRule(Attribute, `([A-Za-z_][A-Za-z0-9_. -]*?|"[^"\n]*"|'[^'\n]*')[ \t]*:(?:[ \t]|\n|$)`),
+		newRule(String, `"(?:\\.|[^"\\])*"?|'(?:''|[^'])*'?`),
+		newRule(Variable, `[&*][A-Za-z0-9_-]+`),
+		newRule(Keyword, `---|\.\.\.|![A-Za-z0-9!/_-]*`),
+		newRule(Number, `[-+]?(?:`+number+`)\b`),
+	},
+	word:     regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*`),
+	literals: set(`true false yes no on off null`),
+}
+
+var (
+	htmlComment = regexp.MustCompile(`^(?:<!--[\s\S]*?(?:-->|$)|<![^>]*>?)`)
+	htmlTagName = regexp.MustCompile(`^</?[A-Za-z][A-Za-z0-9:-]*`)
+	htmlAttr    = regexp.MustCompile(`^[^\s"'>/=]+`)
+	htmlValue   = regexp.MustCompile(`^(?:"[^"]*"?|'[^']*'?|[^\s>]+)`)
+	htmlEntity  = regexp.MustCompile(`^&(?:#[0-9]+|#[xX][0-9a-fA-F]+|[A-Za-z][A-Za-z0-9]*);`)
+)
+
+// lexHTML lexes HTML, whose attributes can only be told from text by
+// knowing whether the lexer is inside a tag
+func lexHTML(code string) []Token {
+	var tokens []Token
+	emit := func(kind Kind, text string) {
+		if n := len(tokens); n > 0 && tokens[n-1].Kind == kind {
+			tokens[n-1].Text += text
+			return
+		}
+		tokens = append(tokens, Token{kind, text})
+	}
+
+	inTag := false
+	for pos := 0; pos < len(code); {
+		rest := code[pos:]
+		length, kind := 0, Plain
+
+		if !inTag {
+			if match := htmlComment.FindString(rest); len(match) > 0 {
+				length, kind = len(match), Comment
+			} else if match := htmlTagName.FindString(rest); len(match) > 0 {
+				length, kind = len(match), Tag
+				inTag = true
+			} else if match := htmlEntity.FindString(rest); len(match) > 0 {
+				length, kind = len(match), Literal
+			}
+		} else {
+			switch {
+			case strings.HasPrefix(rest, "/>"):
+				length, kind = 2, Tag
+				inTag = false
+			case rest[0] == '>':
+				length, kind = 1, Tag
+				inTag = false
+			case rest[0] == '=':
+				length = 1
+				if match := htmlValue.FindString(rest[1:]); len(match) > 0 {
+					emit(Plain, "=")
+					pos++
+					length, kind = len(match), String
+				}
+			default:
+				if match := htmlAttr.FindString(rest); len(match) > 0 {
+					length, kind = len(match), Attribute
+				}
+			}
+		}
+
+		if length == 0 {
+			_, length = utf8.DecodeRuneInString(rest)
+		}
+
+		emit(kind, code[pos:pos+length])
+		pos += length
+	}
+
+	return tokens
+}
+
+var (
+	goHint    = regexp.MustCompile(`(?m)^\s*(?:package \w+|import \(|func [\w(])|:= `)
+	sqlHint   = regexp.MustCompile(`(?i)^\s*(?:SELECT|INSERT|UPDATE|DELETE|CREATE|ALTER|DROP|WITH)\b`)
+	shellHint = regexp.MustCompile(`^(?:#!\s*/\S*(?:sh|bash)|\$ )|(?m)^\s*(?:sudo|cd|echo|export|git|npm|go|docker|curl|ls|mkdir) `)
+	jsHint    = regexp.MustCompile(`\b(?:function|const|let|var|require|console\.log|import .* from)\b|=>`)
+	yamlHint  = regexp.MustCompile(`(?m)^(?:---\s*$|[ \t]*[A-Za-z_][\w.-]*:(?:[ \t]|$)|[ \t]*- )`)
+)
+
+// Detect guesses the language of code that does not say, returning an empty
+// string when it cannot tell
+func Detect(code string) string {
+	trimmed := strings.TrimSpace(code)
+
+	switch {
+	case strings.HasPrefix(trimmed, "<"):
+		return "html"
+	case goHint.MatchString(code):
+		return "go"
+	case sqlHint.MatchString(code):
+		return "sql"
+	case shellHint.MatchString(trimmed):
+		return "shell"
+	case jsHint.MatchString(code):
+		return "javascript"
+	case yamlHint.MatchString(code):
+		return "yaml"
+	}
+
+	return ""
+}
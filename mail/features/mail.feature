@@ -0,0 +1,27 @@
+Feature: write email
+	In order to test email without a mail server
+	As the developer of timrourke.com
+	I need to be able to write email to a buffer
+
+	Scenario: Write a plain text email
+		When I create a writer mailer from "Site <no-reply@example.com>"
+		And I send an email to "author@example.com" with subject "Reset your password" and body "Follow this link"
+		Then the written email should contain "From: Site <no-reply@example.com>"
+		And the written email should contain "To: author@example.com"
+		And the written email should contain "Subject: Reset your password"
+		And the written email should contain "Content-Type: text/plain; charset=utf-8"
+		And the written email should contain "Follow this link"
+
+	Scenario: Strip line breaks from headers
+		When I create a writer mailer from "Site <no-reply@example.com>"
+		And I send an email to "author@example.com" with subject "Hello\r\nBcc: victim@example.com" and body "Hi"
+		Then the written email should contain "Subject: HelloBcc: victim@example.com"
+		And the written email should not contain "\r\nBcc:"
+
+	Scenario: Encode subjects that are not plain ASCII
+		When I create a writer mailer from "Site <no-reply@example.com>"
+		And I send an email to "author@example.com" with subject "Réinitialiser" and body "Hi"
+		Then the written email should contain "Subject: =?utf-8?q?R=C3=A9initialiser?="
+
+	Scenario: Send from the bare address of a named sender
+		Then the envelope sender for "timrourke.com <no-reply@timrourke.com>" should be "no-reply@timrourke.com"
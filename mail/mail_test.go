@@ -0,0 +1,72 @@
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/DATA-DOG/godog"
+	"strings"
+)
+
+var (
+	buffer *bytes.Buffer
+	mailer *WriterMailer
+)
+
+// unescape turns the escaped line breaks used in feature files into real ones
+func unescape(s string) string {
+	return strings.NewReplacer(`\r`, "\r", `\n`, "\n").Replace(s)
+}
+
+func iCreateAWriterMailerFrom(from string) error {
+	buffer = &bytes.Buffer{}
+	mailer = NewWriterMailer(buffer, from)
+	return nil
+}
+
+func iSendAnEmailToWithSubjectAndBody(to, subject, body string) error {
+	return mailer.Send(Message{
+		To:      to,
+		Subject: unescape(subject),
+		Body:    body,
+	})
+}
+
+func theWrittenEmailShouldContain(expected string) error {
+	if strings.Contains(buffer.String(), unescape(expected)) {
+		return nil
+	}
+	return fmt.Errorf("expected email to contain '%s', but it was '%s'",
+		expected,
+		buffer.String())
+}
+
+func theWrittenEmailShouldNotContain(unexpected string) error {
+	if !strings.Contains(buffer.String(), unescape(unexpected)) {
+		return nil
+	}
+	return fmt.Errorf("expected email not to contain '%s', but it was '%s'",
+		unexpected,
+		buffer.String())
+}
+
+func theEnvelopeSenderForShouldBe(from, expected string) error {
+	sender, err := envelopeSender(from)
+	if err != nil {
+		return err
+	}
+
+	if sender != expected {
+		return fmt.Errorf("expected envelope sender '%s', but it was '%s'",
+			expected,
+			sender)
+	}
+	return nil
+}
+
+func FeatureContext(s *godog.Suite) {
+	s.Step(`^I create a writer mailer from "([^"]*)"$`, iCreateAWriterMailerFrom)
+	s.Step(`^I send an email to "([^"]*)" with subject "([^"]*)" and body "([^"]*)"$`, iSendAnEmailToWithSubjectAndBody)
+	s.Step(`^the written email should contain "([^"]*)"$`, theWrittenEmailShouldContain)
+	s.Step(`^the written email should not contain "([^"]*)"$`, theWrittenEmailShouldNotContain)
+	s.Step(`^the envelope sender for "([^"]*)" should be "([^"]*)"$`, theEnvelopeSenderForShouldBe)
+}
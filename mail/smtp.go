@@ -0,0 +1,60 @@
+package mail
+
+import (
+	"net"
+	"net/mail"
+	"net/smtp"
+	"time"
+)
+
+// SMTPMailer sends email through an SMTP server
+type SMTPMailer struct {
+	Addr string
+	From string
+	Auth smtp.Auth
+}
+
+// NewSMTPMailer returns a new instance of SMTPMailer, or an error when from is
+// not an address. PLAIN authentication is only used when a username is given.
+func NewSMTPMailer(host, port, username, password, from string) (*SMTPMailer, error) {
+	if _, err := envelopeSender(from); err != nil {
+		return nil, err
+	}
+
+	var auth smtp.Auth
+	if len(username) > 0 {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return &SMTPMailer{
+		Addr: net.JoinHostPort(host, port),
+		From: from,
+		Auth: auth,
+	}, nil
+}
+
+// Send sends a message
+func (m *SMTPMailer) Send(message Message) error {
+	sender, err := envelopeSender(m.From)
+	if err != nil {
+		return err
+	}
+
+	return smtp.SendMail(
+		m.Addr,
+		m.Auth,
+		sender,
+		[]string{message.To},
+		format(m.From, message, time.Now()))
+}
+
+// envelopeSender returns the bare address of a From header, which may carry a
+// display name, for use as the SMTP envelope sender
+func envelopeSender(from string) (string, error) {
+	address, err := mail.ParseAddress(from)
+	if err != nil {
+		return "", err
+	}
+
+	return address.Address, nil
+}
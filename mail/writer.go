@@ -0,0 +1,45 @@
+package mail
+
+import (
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// WriterMailer writes email to an io.Writer instead of sending it, for
+// development and tests
+type WriterMailer struct {
+	From string
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterMailer returns a new instance of WriterMailer
+func NewWriterMailer(w io.Writer, from string) *WriterMailer {
+	return &WriterMailer{From: from, w: w}
+}
+
+// NewFileMailer returns a WriterMailer that appends to the named file
+func NewFileMailer(filename, from string) (*WriterMailer, error) {
+	file, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewWriterMailer(file, from), nil
+}
+
+// Send writes a message followed by a blank line
+func (m *WriterMailer) Send(message Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := m.w.Write(format(m.From, message, time.Now())); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(m.w, "\r\n")
+	return err
+}
@@ -0,0 +1,84 @@
+Feature: render markdown
+	In order to write posts without the rich text editor
+	As an author on timrourke.com
+	I need markdown rendered to HTML that is safe to serve
+
+	Scenario: Render headings, paragraphs and emphasis
+		When I render the markdown:
+			"""
+			# Hello *world*
+
+			Some **bold**, _emphasized_ and ~~struck~~ text with `code` in snake_case_names.
+			"""
+		Then the HTML should be:
+			"""
+			<h1>Hello <em>world</em></h1>
+			<p>Some <strong>bold</strong>, <em>emphasized</em> and <del>struck</del> text with <code>code</code> in snake_case_names.</p>
+			"""
+
+	Scenario: Render nested lists
+		When I render the markdown:
+			"""
+			- one
+			- two
+			  1. inner
+			"""
+		Then the HTML should be:
+			"""
+			<ul>
+			<li>one</li>
+			<li>
+			two
+			<ol>
+			<li>inner</li>
+			</ol>
+			</li>
+			</ul>
+			"""
+
+	Scenario: Render fenced code with its language
+		When I render the markdown:
+			"""
+			```go
+			x := a < b
+			```
+			"""
+		Then the HTML should be:
+			"""
+			<pre><code class="language-go">x := a &lt; b
+			</code></pre>
+			"""
+
+	Scenario: Render quotes and line breaks
+		When I render the markdown:
+			"""
+			> quoted\
+			> text
+			"""
+		Then the HTML should be:
+			"""
+			<blockquote>
+			<p>quoted<br>
+			text</p>
+			</blockquote>
+			"""
+
+	Scenario: Escape raw HTML
+		When I render the markdown:
+			"""
+			<script>alert(1)</script> &copy;
+			"""
+		Then the HTML should be:
+			"""
+			<p>&lt;script&gt;alert(1)&lt;/script&gt; &copy;</p>
+			"""
+
+	Scenario: Drop links and images with unsafe URLs
+		When I render the markdown:
+			"""
+			[safe](https://example.com "Example") [unsafe](javascript:alert(1)) ![a cat](/cat.png) ![x](JaVaScRiPt:alert(1))
+			"""
+		Then the HTML should be:
+			"""
+			<p><a href="https://example.com" title="Example">safe</a> unsafe <img src="/cat.png" alt="a cat"> x</p>
+			"""
@@ -0,0 +1,759 @@
+// Package markdown renders the markdown authors write posts in to HTML
+package markdown
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	atxHeading    = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	thematicBreak = regexp.MustCompile(`^ {0,3}(?:(?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	setextUnder   = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	fenceOpen     = regexp.MustCompile("^( {0,3})(`{3,}|~{3,})[ \t]*([^`]*)$")
+	bulletItem    = regexp.MustCompile(`^( {0,3})([-+*])([ \t]+|$)`)
+	orderedItem   = regexp.MustCompile(`^( {0,3})(\d{1,9})([.)])([ \t]+|$)`)
+	blockquote    = regexp.MustCompile(`^ {0,3}> ?`)
+	entity        = regexp.MustCompile(`^&(?:#[0-9]{1,7}|#[xX][0-9a-fA-F]{1,6}|[a-zA-Z][a-zA-Z0-9]{1,31});`)
+	htmlTag       = regexp.MustCompile(`<[^>]*>`)
+	autolink      = regexp.MustCompile(`^<([a-zA-Z][a-zA-Z0-9+.-]{1,31}:[^<>\s]*|[a-zA-Z0-9.!#$%&'*+/=?^_{|}~-]+@[a-zA-Z0-9-]+(?:\.[a-zA-Z0-9-]+)*)>`)
+)
+
+// safeSchemes are the URL schemes links and images may use. URLs without a
+// scheme are relative and always allowed.
+var safeSchemes = map[string]bool{
+	"http":   true,
+	"https":  true,
+	"mailto": true,
+}
+
+// Render converts markdown to HTML. Raw HTML in the source is escaped rather
+// than passed through, and links and images may only point at http, https,
+// mailto or relative URLs, so the result is safe to serve as is.
+func Render(source string) string {
+	source = strings.Replace(source, "\r\n", "\n", -1)
+	source = strings.Replace(source, "\r", "\n", -1)
+
+	var b bytes.Buffer
+	renderBlocks(&b, strings.Split(source, "\n"), false)
+
+	return b.String()
+}
+
+// renderBlocks renders lines as a sequence of blocks. Tight list items leave
+// their paragraphs unwrapped.
+func renderBlocks(b *bytes.Buffer, lines []string, tight bool) {
+	for i := range lines {
+		lines[i] = expandTabs(lines[i])
+	}
+
+	for i := 0; i < len(lines); {
+		line := lines[i]
+
+		switch {
+		case isBlank(line):
+			i++
+
+		case fenceOpen.MatchString(line):
+			i = renderFencedCode(b, lines, i)
+
+		case indentOf(line) >= 4:
+			i = renderIndentedCode(b, lines, i)
+
+		case atxHeading.MatchString(line):
+			match := atxHeading.FindStringSubmatch(line)
+			level := strconv.Itoa(len(match[1]))
+			b.WriteString("<h" + level + ">")
+			renderInline(b, strings.TrimSpace(match[2]))
+			b.WriteString("</h" + level + ">\n")
+			i++
+
+		case thematicBreak.MatchString(line):
+			b.WriteString("<hr>\n")
+			i++
+
+		case blockquote.MatchString(line):
+			i = renderBlockquote(b, lines, i)
+
+		case bulletItem.MatchString(line) || orderedItem.MatchString(line):
+			i = renderList(b, lines, i)
+
+		default:
+			i = renderParagraph(b, lines, i, tight)
+		}
+	}
+}
+
+// renderFencedCode renders a code block fenced by backticks or tildes, which
+// runs to the matching fence or the end of the document
+func renderFencedCode(b *bytes.Buffer, lines []string, start int) int {
+	match := fenceOpen.FindStringSubmatch(lines[start])
+	indent, fence, info := len(match[1]), match[2], strings.Fields(match[3])
+
+	b.WriteString("<pre><code")
+	if len(info) > 0 {
+		b.WriteString(` class="language-` + html.EscapeString(unescapePunctuation(info[0])) + `"`)
+	}
+	b.WriteString(">")
+
+	i := start + 1
+	for ; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if indentOf(lines[i]) < 4 && strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+			i++
+			break
+		}
+
+		b.WriteString(html.EscapeString(removeIndent(lines[i], indent)))
+		b.WriteString("\n")
+	}
+
+	b.WriteString("</code></pre>\n")
+	return i
+}
+
+// renderIndentedCode renders a code block indented by four spaces, which runs
+// until a line that is indented less
+func renderIndentedCode(b *bytes.Buffer, lines []string, start int) int {
+	end := start
+	for i := start; i < len(lines) && (isBlank(lines[i]) || indentOf(lines[i]) >= 4); i++ {
+		if !isBlank(lines[i]) {
+			end = i + 1
+		}
+	}
+
+	b.WriteString("<pre><code>")
+	for _, line := range lines[start:end] {
+		b.WriteString(html.EscapeString(removeIndent(line, 4)))
+		b.WriteString("\n")
+	}
+	b.WriteString("</code></pre>\n")
+
+	return end
+}
+
+// renderBlockquote renders consecutive lines marked with > as a quote, which
+// may hold any other blocks
+func renderBlockquote(b *bytes.Buffer, lines []string, start int) int {
+	var quoted []string
+
+	i := start
+	for ; i < len(lines) && blockquote.MatchString(lines[i]); i++ {
+		quoted = append(quoted, blockquote.ReplaceAllString(lines[i], ""))
+	}
+
+	b.WriteString("<blockquote>\n")
+	renderBlocks(b, quoted, false)
+	b.WriteString("</blockquote>\n")
+
+	return i
+}
+
+// listItem is one item of a list, with its lines stripped of the marker and
+// the indentation that belongs to the item
+type listItem struct {
+	lines []string
+}
+
+// renderList renders a bulleted or numbered list. Items continue over lines
+// indented past their marker, and a list whose items are separated by blank
+// lines is loose, wrapping each item's paragraphs.
+func renderList(b *bytes.Buffer, lines []string, start int) int {
+	ordered := !bulletItem.MatchString(lines[start])
+	first := listMarker(lines[start], ordered)
+
+	var items []listItem
+	loose := false
+	indent := 0
+
+	i := start
+	for i < len(lines) {
+		line := lines[i]
+
+		// Markers indented into the current item start a nested list instead
+		marker := listMarker(line, ordered)
+		if marker != nil && marker.delimiter == first.delimiter && (len(items) == 0 || indentOf(line) < indent) {
+			items = append(items, listItem{lines: []string{marker.rest}})
+			indent = marker.indent
+			i++
+			continue
+		}
+
+		if isBlank(line) {
+			// A blank line ends the list unless the list carries on after it
+			next := i + 1
+			for next < len(lines) && isBlank(lines[next]) {
+				next++
+			}
+
+			if next == len(lines) {
+				break
+			}
+
+			if indentOf(lines[next]) < indent {
+				marker := listMarker(lines[next], ordered)
+				if marker == nil || marker.delimiter != first.delimiter {
+					break
+				}
+			}
+
+			loose = true
+			for ; i < next; i++ {
+				items[len(items)-1].lines = append(items[len(items)-1].lines, "")
+			}
+			continue
+		}
+
+		current := &items[len(items)-1]
+		previous := current.lines[len(current.lines)-1]
+
+		if indentOf(line) >= indent {
+			current.lines = append(current.lines, removeIndent(line, indent))
+		} else if !isBlank(previous) && !startsBlock(line) {
+			// A lazy continuation of the item's paragraph
+			current.lines = append(current.lines, strings.TrimLeft(line, " "))
+		} else {
+			break
+		}
+
+		i++
+	}
+
+	tag := "ul"
+	if ordered {
+		tag = "ol"
+	}
+
+	b.WriteString("<" + tag)
+	if ordered && first.number != 1 {
+		b.WriteString(` start="` + strconv.Itoa(first.number) + `"`)
+	}
+	b.WriteString(">\n")
+
+	for _, item := range items {
+		b.WriteString("<li>")
+
+		var inner bytes.Buffer
+		renderBlocks(&inner, item.lines, !loose)
+
+		content := inner.String()
+		if loose || strings.Contains(strings.TrimSuffix(content, "\n"), "\n") {
+			b.WriteString("\n")
+		} else {
+			content = strings.TrimSuffix(content, "\n")
+		}
+
+		b.WriteString(content)
+		b.WriteString("</li>\n")
+	}
+
+	b.WriteString("</" + tag + ">\n")
+	return i
+}
+
+// marker is the start of a list item
+type marker struct {
+	delimiter string
+	number    int
+	indent    int
+	rest      string
+}
+
+// listMarker parses the marker starting a list item, or returns nil when a
+// line does not start one
+func listMarker(line string, ordered bool) *marker {
+	var m marker
+	var width int
+
+	if ordered {
+		match := orderedItem.FindStringSubmatch(line)
+		if match == nil {
+			return nil
+		}
+
+		m.delimiter = match[3]
+		m.number, _ = strconv.Atoi(match[2])
+		width = len(match[1]) + len(match[2]) + len(match[3])
+	} else {
+		match := bulletItem.FindStringSubmatch(line)
+		if match == nil {
+			return nil
+		}
+
+		m.delimiter = match[2]
+		width = len(match[1]) + len(match[2])
+	}
+
+	rest := line[width:]
+	spaces := len(rest) - len(strings.TrimLeft(rest, " "))
+
+	// Content indented five or more spaces is a code block one space in
+	if spaces == 0 || spaces > 4 || isBlank(rest) {
+		spaces = 1
+	}
+
+	m.indent = width + spaces
+	if len(rest) >= spaces {
+		m.rest = rest[spaces:]
+	}
+
+	return &m
+}
+
+// renderParagraph renders lines up to the next blank line or block as a
+// paragraph, or as a heading when they are underlined with = or -
+func renderParagraph(b *bytes.Buffer, lines []string, start int, tight bool) int {
+	var text []string
+
+	i := start
+	for ; i < len(lines); i++ {
+		line := lines[i]
+
+		if i > start {
+			if isBlank(line) {
+				break
+			}
+
+			if match := setextUnder.FindStringSubmatch(line); match != nil {
+				level := "1"
+				if match[1][0] == '-' {
+					level = "2"
+				}
+
+				b.WriteString("<h" + level + ">")
+				renderInline(b, strings.Join(text, "\n"))
+				b.WriteString("</h" + level + ">\n")
+				return i + 1
+			}
+
+			if startsBlock(line) {
+				break
+			}
+		}
+
+		text = append(text, strings.TrimLeft(line, " "))
+	}
+
+	if !tight {
+		b.WriteString("<p>")
+	}
+
+	renderInline(b, strings.TrimRight(strings.Join(text, "\n"), " "))
+
+	if !tight {
+		b.WriteString("</p>")
+	}
+	b.WriteString("\n")
+
+	return i
+}
+
+// startsBlock reports whether a line interrupts a paragraph by starting a
+// block of its own
+func startsBlock(line string) bool {
+	if indentOf(line) >= 4 {
+		return false
+	}
+
+	return atxHeading.MatchString(line) ||
+		thematicBreak.MatchString(line) ||
+		fenceOpen.MatchString(line) ||
+		blockquote.MatchString(line) ||
+		(bulletItem.MatchString(line) && !isBlank(bulletItem.ReplaceAllString(line, ""))) ||
+		(orderedItem.MatchString(line) && strings.HasPrefix(strings.TrimLeft(line, " "), "1"))
+}
+
+// renderInline renders the text of a paragraph or heading, turning emphasis,
+// code, links and images into HTML and escaping everything else
+func renderInline(b *bytes.Buffer, text string) {
+	for i := 0; i < len(text); {
+		c := text[i]
+
+		switch {
+		case c == '\\' && i+1 < len(text) && text[i+1] == '\n':
+			b.WriteString("<br>\n")
+			i += 2
+
+		case c == '\\' && i+1 < len(text) && isPunctuation(text[i+1]):
+			b.WriteString(html.EscapeString(text[i+1 : i+2]))
+			i += 2
+
+		case c == '\n':
+			// Two or more trailing spaces break the line
+			trimmed := strings.TrimRight(b.String(), " ")
+			if b.Len()-len(trimmed) >= 2 {
+				b.Truncate(len(trimmed))
+				b.WriteString("<br>\n")
+			} else {
+				b.Truncate(len(trimmed))
+				b.WriteString("\n")
+			}
+			i++
+
+		case c == '`':
+			i = renderCodeSpan(b, text, i)
+
+		case c == '!' && i+1 < len(text) && text[i+1] == '[':
+			if end, ok := renderLink(b, text, i+1, true); ok {
+				i = end
+			} else {
+				b.WriteString("!")
+				i++
+			}
+
+		case c == '[':
+			if end, ok := renderLink(b, text, i, false); ok {
+				i = end
+			} else {
+				b.WriteString("[")
+				i++
+			}
+
+		case c == '<':
+			if match := autolink.FindStringSubmatch(text[i:]); match != nil {
+				href := match[1]
+				if strings.Contains(href, "@") && !strings.Contains(href, ":") {
+					href = "mailto:" + href
+				}
+
+				if url, ok := safeURL(href); ok {
+					b.WriteString(`<a href="` + html.EscapeString(url) + `">` + html.EscapeString(match[1]) + "</a>")
+				} else {
+					b.WriteString(html.EscapeString(match[0]))
+				}
+
+				i += len(match[0])
+			} else {
+				b.WriteString("&lt;")
+				i++
+			}
+
+		case c == '&':
+			if match := entity.FindString(text[i:]); len(match) > 0 {
+				b.WriteString(match)
+				i += len(match)
+			} else {
+				b.WriteString("&amp;")
+				i++
+			}
+
+		case c == '*' || c == '_' || c == '~':
+			i = renderEmphasis(b, text, i)
+
+		default:
+			b.WriteString(html.EscapeString(text[i : i+1]))
+			i++
+		}
+	}
+}
+
+// renderCodeSpan renders text between matching runs of backticks as code,
+// or the backticks themselves when they are not closed
+func renderCodeSpan(b *bytes.Buffer, text string, start int) int {
+	run := 0
+	for start+run < len(text) && text[start+run] == '`' {
+		run++
+	}
+
+	for i := start + run; i < len(text); {
+		if text[i] != '`' {
+			i++
+			continue
+		}
+
+		closing := 0
+		for i+closing < len(text) && text[i+closing] == '`' {
+			closing++
+		}
+
+		if closing == run {
+			code := strings.Replace(text[start+run:i], "\n", " ", -1)
+			if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.TrimSpace(code) != "" {
+				code = code[1 : len(code)-1]
+			}
+
+			b.WriteString("<code>" + html.EscapeString(code) + "</code>")
+			return i + closing
+		}
+
+		i += closing
+	}
+
+	b.WriteString(text[start : start+run])
+	return start + run
+}
+
+// renderLink renders [text](url "title") as a link, or ![alt](url "title") as
+// an image, reporting false when the brackets do not form one. Links to
+// unsafe URLs keep their text but lose the link.
+func renderLink(b *bytes.Buffer, text string, open int, image bool) (int, bool) {
+	closeBracket := matchingBracket(text, open)
+	if closeBracket < 0 || closeBracket+1 >= len(text) || text[closeBracket+1] != '(' {
+		return 0, false
+	}
+
+	closeParen := matchingParen(text, closeBracket+1)
+	if closeParen < 0 {
+		return 0, false
+	}
+
+	label := text[open+1 : closeBracket]
+	destination, title := splitDestination(text[closeBracket+2 : closeParen])
+	url, safe := safeURL(destination)
+
+	titleAttr := ""
+	if len(title) > 0 {
+		titleAttr = ` title="` + html.EscapeString(title) + `"`
+	}
+
+	if image {
+		if safe {
+			b.WriteString(`<img src="` + html.EscapeString(url) + `" alt="` + html.EscapeString(plainText(label)) + `"` + titleAttr + ">")
+		} else {
+			b.WriteString(html.EscapeString(plainText(label)))
+		}
+	} else if safe {
+		b.WriteString(`<a href="` + html.EscapeString(url) + `"` + titleAttr + ">")
+		renderInline(b, label)
+		b.WriteString("</a>")
+	} else {
+		renderInline(b, label)
+	}
+
+	return closeParen + 1, true
+}
+
+// matchingBracket finds the ] closing the [ at open, allowing nested pairs
+func matchingBracket(text string, open int) int {
+	depth := 0
+	for i := open; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+// matchingParen finds the ) closing the ( at open, allowing nested pairs and
+// parentheses inside a quoted title
+func matchingParen(text string, open int) int {
+	depth := 0
+	var quote byte
+	for i := open; i < len(text); i++ {
+		c := text[i]
+
+		switch {
+		case c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' && depth == 1 && i > open+1 && text[i-1] == ' ':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+// splitDestination splits the inside of a link's parentheses into its URL and
+// its optional quoted title
+func splitDestination(inside string) (string, string) {
+	inside = strings.TrimSpace(inside)
+
+	destination, title := inside, ""
+	if space := strings.IndexAny(inside, " \n"); space >= 0 {
+		rest := strings.TrimSpace(inside[space:])
+		if len(rest) >= 2 && rest[0] == '"' && rest[len(rest)-1] == '"' {
+			destination, title = inside[:space], unescapePunctuation(rest[1:len(rest)-1])
+		}
+	}
+
+	destination = strings.TrimSuffix(strings.TrimPrefix(destination, "<"), ">")
+
+	return unescapePunctuation(destination), title
+}
+
+// safeURL reports whether a URL is relative or uses one of the safe schemes,
+// returning it with spaces encoded
+func safeURL(raw string) (string, bool) {
+	url := strings.Replace(strings.TrimSpace(raw), " ", "%20", -1)
+
+	// Browsers ignore control characters and whitespace inside a scheme
+	cleaned := strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, url)
+
+	colon := strings.Index(cleaned, ":")
+	if colon < 0 || strings.ContainsAny(cleaned[:colon], "/?#") {
+		return url, true
+	}
+
+	return url, safeSchemes[strings.ToLower(cleaned[:colon])]
+}
+
+// renderEmphasis renders text between matching delimiters as emphasis, strong
+// emphasis or strikethrough, or the delimiters themselves when they do not
+// open a span
+func renderEmphasis(b *bytes.Buffer, text string, start int) int {
+	c := text[start]
+	run := 0
+	for start+run < len(text) && text[start+run] == c {
+		run++
+	}
+
+	// Underscores inside words, like snake_case, are not emphasis
+	intraword := c == '_' && start > 0 && isWordByte(text[start-1])
+
+	size := 1
+	if run >= 2 {
+		size = 2
+	}
+
+	tags := map[int]string{1: "em", 2: "strong"}
+	if c == '~' {
+		if run != 2 {
+			b.WriteString(text[start : start+run])
+			return start + run
+		}
+		tags[2] = "del"
+	}
+
+	opens := start+run < len(text) && !isSpace(text[start+run])
+	if opens && !intraword {
+		delimiter := strings.Repeat(string(c), size)
+		if end := closingDelimiter(text, start+size, delimiter); end > start+size {
+			b.WriteString("<" + tags[size] + ">")
+			renderInline(b, text[start+size:end])
+			b.WriteString("</" + tags[size] + ">")
+			return end + size
+		}
+	}
+
+	b.WriteString(text[start : start+run])
+	return start + run
+}
+
+// closingDelimiter finds a delimiter closing a span that starts at from,
+// skipping escaped characters, code spans and longer runs of the same
+// delimiter character, or returns -1
+func closingDelimiter(text string, from int, delimiter string) int {
+	c := delimiter[0]
+
+	for i := from; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+			continue
+		case '`':
+			if end := strings.IndexByte(text[i+1:], '`'); end >= 0 {
+				i += end + 1
+			}
+			continue
+		}
+
+		if text[i] != c {
+			continue
+		}
+
+		run := 0
+		for i+run < len(text) && text[i+run] == c {
+			run++
+		}
+
+		closes := !isSpace(text[i-1]) && (c != '_' || i+run >= len(text) || !isWordByte(text[i+run]))
+		if closes && (run == len(delimiter) || (run > len(delimiter) && run != 2)) {
+			return i + run - len(delimiter)
+		}
+
+		i += run - 1
+	}
+
+	return -1
+}
+
+// plainText strips the markdown from a link's text, for an image's alt text
+func plainText(text string) string {
+	var b bytes.Buffer
+	renderInline(&b, text)
+
+	stripped := htmlTag.ReplaceAllString(b.String(), "")
+	return html.UnescapeString(stripped)
+}
+
+// unescapePunctuation removes the backslashes escaping punctuation
+func unescapePunctuation(text string) string {
+	var b bytes.Buffer
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\\' && i+1 < len(text) && isPunctuation(text[i+1]) {
+			i++
+		}
+		b.WriteByte(text[i])
+	}
+
+	return b.String()
+}
+
+// expandTabs replaces tabs in a line's indentation with four spaces each, so
+// indentation can be measured in spaces
+func expandTabs(line string) string {
+	indent := 0
+	for indent < len(line) && (line[indent] == ' ' || line[indent] == '\t') {
+		indent++
+	}
+
+	return strings.Replace(line[:indent], "\t", "    ", -1) + line[indent:]
+}
+
+// indentOf counts the spaces a line starts with
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// removeIndent removes up to n spaces from the start of a line
+func removeIndent(line string, n int) string {
+	for i := 0; i < n && len(line) > 0 && line[0] == ' '; i++ {
+		line = line[1:]
+	}
+
+	return line
+}
+
+func isBlank(line string) bool {
+	return len(strings.TrimSpace(line)) == 0
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n'
+}
+
+func isWordByte(c byte) bool {
+	return c >= 0x80 || c == '_' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isPunctuation(c byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
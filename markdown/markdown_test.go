@@ -0,0 +1,29 @@
+package markdown
+
+import (
+	"fmt"
+	"github.com/DATA-DOG/godog"
+	"github.com/DATA-DOG/godog/gherkin"
+	"strings"
+)
+
+var rendered string
+
+func iRenderTheMarkdown(source *gherkin.DocString) error {
+	rendered = Render(source.Content)
+	return nil
+}
+
+func theHTMLShouldBe(expected *gherkin.DocString) error {
+	if strings.TrimSpace(rendered) == strings.TrimSpace(expected.Content) {
+		return nil
+	}
+	return fmt.Errorf("expected HTML '%s', but it was '%s'",
+		expected.Content,
+		rendered)
+}
+
+func FeatureContext(s *godog.Suite) {
+	s.Step(`^I render the markdown:$`, iRenderTheMarkdown)
+	s.Step(`^the HTML should be:$`, theHTMLShouldBe)
+}
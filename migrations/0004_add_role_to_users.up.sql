@@ -0,0 +1,3 @@
+ALTER TABLE `users`
+ADD COLUMN `role` ENUM('admin', 'editor', 'author', 'contributor') NOT NULL DEFAULT 'author' AFTER `password_hash`;
+UPDATE `users` SET `role` = 'admin' ORDER BY `id` ASC LIMIT 1;
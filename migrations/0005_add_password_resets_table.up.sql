@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS `password_resets` (
+	`id` INT NOT NULL AUTO_INCREMENT,
+	`created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	`expires_at` DATETIME NOT NULL,
+	`used_at` DATETIME NULL,
+	`user_id` INT NOT NULL,
+	`token_hash` CHAR(64) NOT NULL,
+	UNIQUE KEY `token_hash` (`token_hash`),
+	INDEX `user_id` (`user_id`),
+	FOREIGN KEY (`user_id`)
+		REFERENCES users(`id`)
+		ON DELETE CASCADE,
+	PRIMARY KEY (`id`)
+) ENGINE=InnoDB;
@@ -0,0 +1,22 @@
+ALTER TABLE `users`
+ADD COLUMN `totp_secret` VARCHAR(64) NULL AFTER `role`,
+ADD COLUMN `totp_enabled_at` DATETIME NULL AFTER `totp_secret`,
+ADD COLUMN `totp_last_step` BIGINT NOT NULL DEFAULT 0 AFTER `totp_enabled_at`;
+CREATE TABLE IF NOT EXISTS `recovery_codes` (
+	`id` INT NOT NULL AUTO_INCREMENT,
+	`created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	`used_at` DATETIME NULL,
+	`user_id` INT NOT NULL,
+	`code_hash` CHAR(64) NOT NULL,
+	INDEX `user_id_code_hash` (`user_id`, `code_hash`),
+	FOREIGN KEY (`user_id`)
+		REFERENCES users(`id`)
+		ON DELETE CASCADE,
+	PRIMARY KEY (`id`)
+) ENGINE=InnoDB;
+CREATE TABLE IF NOT EXISTS `settings` (
+	`name` VARCHAR(64) NOT NULL,
+	`updated_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+	`value` TEXT NOT NULL,
+	PRIMARY KEY (`name`)
+) ENGINE=InnoDB;
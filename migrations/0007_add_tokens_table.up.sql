@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS `tokens` (
+	`id` INT NOT NULL AUTO_INCREMENT,
+	`created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	`updated_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+	`user_id` INT NOT NULL,
+	`name` VARCHAR(250) NOT NULL,
+	`token_hash` CHAR(64) NOT NULL,
+	`scopes` VARCHAR(250) NOT NULL,
+	`expires_at` DATETIME NULL,
+	`last_used_at` DATETIME NULL,
+	`revoked_at` DATETIME NULL,
+	UNIQUE KEY `token_hash` (`token_hash`),
+	INDEX `user_id` (`user_id`),
+	FOREIGN KEY (`user_id`)
+		REFERENCES users(`id`)
+		ON DELETE CASCADE,
+	PRIMARY KEY (`id`)
+) ENGINE=InnoDB;
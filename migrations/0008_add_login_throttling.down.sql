@@ -0,0 +1,7 @@
+DROP TABLE `lockout_events`;
+DROP TABLE `login_throttles`;
+ALTER TABLE `users`
+DROP COLUMN `failed_logins`,
+DROP COLUMN `last_failed_login_at`,
+DROP COLUMN `lockouts`,
+DROP COLUMN `locked_until`;
@@ -0,0 +1,31 @@
+ALTER TABLE `users`
+ADD COLUMN `failed_logins` INT NOT NULL DEFAULT 0 AFTER `totp_last_step`,
+ADD COLUMN `last_failed_login_at` DATETIME NULL AFTER `failed_logins`,
+ADD COLUMN `lockouts` INT NOT NULL DEFAULT 0 AFTER `last_failed_login_at`,
+ADD COLUMN `locked_until` DATETIME NULL AFTER `lockouts`;
+CREATE TABLE IF NOT EXISTS `login_throttles` (
+	`client_ip` VARCHAR(45) NOT NULL,
+	`failures` INT NOT NULL DEFAULT 0,
+	`last_failed_at` DATETIME NULL,
+	`lockouts` INT NOT NULL DEFAULT 0,
+	`locked_until` DATETIME NULL,
+	PRIMARY KEY (`client_ip`)
+) ENGINE=InnoDB;
+CREATE TABLE IF NOT EXISTS `lockout_events` (
+	`id` INT NOT NULL AUTO_INCREMENT,
+	`created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	`event` ENUM('locked', 'unlocked') NOT NULL,
+	`user_id` INT NULL,
+	`client_ip` VARCHAR(45) NULL,
+	`locked_until` DATETIME NULL,
+	`actor_id` INT NULL,
+	INDEX `user_id` (`user_id`),
+	INDEX `client_ip` (`client_ip`),
+	FOREIGN KEY (`user_id`)
+		REFERENCES users(`id`)
+		ON DELETE SET NULL,
+	FOREIGN KEY (`actor_id`)
+		REFERENCES users(`id`)
+		ON DELETE SET NULL,
+	PRIMARY KEY (`id`)
+) ENGINE=InnoDB;
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS `audit_events` (
+	`id` INT NOT NULL AUTO_INCREMENT,
+	`created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	`actor_id` INT NULL,
+	`action` ENUM('create', 'update', 'delete') NOT NULL,
+	`resource_type` VARCHAR(64) NOT NULL,
+	`resource_id` VARCHAR(64) NOT NULL,
+	`client_ip` VARCHAR(45) NOT NULL,
+	`changes` TEXT NOT NULL,
+	INDEX `actor_id` (`actor_id`),
+	INDEX `resource` (`resource_type`, `resource_id`),
+	PRIMARY KEY (`id`)
+) ENGINE=InnoDB;
+CREATE TRIGGER `audit_events_no_update` BEFORE UPDATE ON `audit_events`
+	FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_events is append-only';
+CREATE TRIGGER `audit_events_no_delete` BEFORE DELETE ON `audit_events`
+	FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_events is append-only';
@@ -0,0 +1,6 @@
+ALTER TABLE `users`
+ADD COLUMN `display_name` VARCHAR(250) NOT NULL DEFAULT '' AFTER `email`,
+ADD COLUMN `bio` VARCHAR(2000) NOT NULL DEFAULT '' AFTER `display_name`,
+ADD COLUMN `avatar_url` VARCHAR(2048) NOT NULL DEFAULT '' AFTER `bio`,
+ADD COLUMN `website` VARCHAR(2048) NOT NULL DEFAULT '' AFTER `avatar_url`,
+ADD COLUMN `social_links` VARCHAR(4000) NOT NULL DEFAULT '{}' AFTER `website`;
@@ -0,0 +1,4 @@
+ALTER TABLE `posts`
+DROP INDEX `status_published_at`,
+DROP COLUMN `status`,
+DROP COLUMN `published_at`;
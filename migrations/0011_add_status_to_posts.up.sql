@@ -0,0 +1,5 @@
+ALTER TABLE `posts`
+ADD COLUMN `status` ENUM('draft', 'published', 'scheduled', 'archived') NOT NULL DEFAULT 'draft' AFTER `permalink`,
+ADD COLUMN `published_at` DATETIME NULL AFTER `status`,
+ADD INDEX `status_published_at` (`status`, `published_at`);
+UPDATE `posts` SET `status` = 'published', `published_at` = `created_at`;
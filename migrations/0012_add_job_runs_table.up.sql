@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS `job_runs` (
+	`name` VARCHAR(64) NOT NULL,
+	`started_at` DATETIME NULL,
+	`finished_at` DATETIME NULL,
+	`succeeded_at` DATETIME NULL,
+	`runs` INT NOT NULL DEFAULT 0,
+	`items` INT NOT NULL DEFAULT 0,
+	`last_error` VARCHAR(2000) NOT NULL DEFAULT '',
+	PRIMARY KEY (`name`)
+) ENGINE=InnoDB;
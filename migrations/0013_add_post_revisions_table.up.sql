@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS `post_revisions` (
+	`id` INT NOT NULL AUTO_INCREMENT,
+	`created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	`post_id` INT NOT NULL,
+	`editor_id` INT NULL,
+	`title` VARCHAR(250) NOT NULL,
+	`excerpt` TEXT NOT NULL,
+	`content` LONGTEXT NOT NULL,
+	`permalink` VARCHAR(250) NOT NULL,
+	INDEX `post_id` (`post_id`),
+	INDEX `editor_id` (`editor_id`),
+	FOREIGN KEY (`post_id`)
+		REFERENCES posts(`id`)
+		ON DELETE CASCADE,
+	FOREIGN KEY (`editor_id`)
+		REFERENCES users(`id`)
+		ON DELETE SET NULL,
+	PRIMARY KEY (`id`)
+) ENGINE=InnoDB;
@@ -0,0 +1,4 @@
+ALTER TABLE `posts`
+ADD COLUMN `version` INT NOT NULL DEFAULT 1 AFTER `updated_at`;
+ALTER TABLE `users`
+ADD COLUMN `version` INT NOT NULL DEFAULT 1 AFTER `updated_at`;
@@ -0,0 +1,5 @@
+DROP TABLE `post_permalinks`;
+ALTER TABLE `posts`
+DROP INDEX `permalink`,
+MODIFY COLUMN `permalink` VARCHAR(250),
+ADD INDEX `permalink` (`permalink`);
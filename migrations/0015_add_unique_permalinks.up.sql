@@ -0,0 +1,19 @@
+UPDATE `posts` SET `permalink` = CONCAT('post-', `id`) WHERE `permalink` IS NULL OR `permalink` = '';
+UPDATE `posts` `p`
+JOIN (SELECT `permalink`, MIN(`id`) AS `keep` FROM `posts` GROUP BY `permalink` HAVING COUNT(*) > 1) `d`
+	ON `p`.`permalink` = `d`.`permalink` AND `p`.`id` <> `d`.`keep`
+SET `p`.`permalink` = CONCAT(`p`.`permalink`, '-', `p`.`id`);
+ALTER TABLE `posts`
+DROP INDEX `permalink`,
+MODIFY COLUMN `permalink` VARCHAR(250) NOT NULL,
+ADD UNIQUE KEY `permalink` (`permalink`);
+CREATE TABLE IF NOT EXISTS `post_permalinks` (
+	`permalink` VARCHAR(250) NOT NULL,
+	`created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	`post_id` INT NOT NULL,
+	INDEX `post_id` (`post_id`),
+	FOREIGN KEY (`post_id`)
+		REFERENCES posts(`id`)
+		ON DELETE CASCADE,
+	PRIMARY KEY (`permalink`)
+) ENGINE=InnoDB;
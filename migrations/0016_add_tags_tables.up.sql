@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS `tags` (
+	`id` INT NOT NULL AUTO_INCREMENT,
+	`created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	`updated_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+	`name` VARCHAR(100) NOT NULL,
+	`slug` VARCHAR(250) NOT NULL,
+	UNIQUE KEY `slug` (`slug`),
+	PRIMARY KEY (`id`)
+) ENGINE=InnoDB;
+CREATE TABLE IF NOT EXISTS `posts_tags` (
+	`post_id` INT NOT NULL,
+	`tag_id` INT NOT NULL,
+	INDEX `tag_id` (`tag_id`),
+	FOREIGN KEY (`post_id`)
+		REFERENCES posts(`id`)
+		ON DELETE CASCADE,
+	FOREIGN KEY (`tag_id`)
+		REFERENCES tags(`id`)
+		ON DELETE CASCADE,
+	PRIMARY KEY (`post_id`, `tag_id`)
+) ENGINE=InnoDB;
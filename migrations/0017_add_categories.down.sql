@@ -0,0 +1,3 @@
+ALTER TABLE `posts` DROP FOREIGN KEY `posts_category_id`;
+ALTER TABLE `posts` DROP COLUMN `category_id`;
+DROP TABLE IF EXISTS `categories`;
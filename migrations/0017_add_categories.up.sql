@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS `categories` (
+	`id` INT NOT NULL AUTO_INCREMENT,
+	`created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	`updated_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+	`name` VARCHAR(100) NOT NULL,
+	`slug` VARCHAR(250) NOT NULL,
+	`parent_id` INT NULL DEFAULT NULL,
+	`path` VARCHAR(255) CHARACTER SET ascii NOT NULL DEFAULT '/',
+	UNIQUE KEY `slug` (`slug`),
+	INDEX `path` (`path`),
+	FOREIGN KEY (`parent_id`)
+		REFERENCES categories(`id`)
+		ON DELETE RESTRICT,
+	PRIMARY KEY (`id`)
+) ENGINE=InnoDB;
+ALTER TABLE `posts`
+ADD COLUMN `category_id` INT NULL DEFAULT NULL,
+ADD CONSTRAINT `posts_category_id` FOREIGN KEY (`category_id`)
+	REFERENCES categories(`id`)
+	ON DELETE SET NULL;
@@ -0,0 +1,2 @@
+DROP TABLE `series_posts`;
+DROP TABLE `series`;
\ No newline at end of file
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS `series` (
+	`id` INT NOT NULL AUTO_INCREMENT,
+	`created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	`updated_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+	`title` VARCHAR(250) NOT NULL,
+	`slug` VARCHAR(250) NOT NULL,
+	`description` TEXT NOT NULL,
+	UNIQUE KEY `slug` (`slug`),
+	PRIMARY KEY (`id`)
+) ENGINE=InnoDB;
+CREATE TABLE IF NOT EXISTS `series_posts` (
+	`post_id` INT NOT NULL,
+	`series_id` INT NOT NULL,
+	`position` INT NOT NULL,
+	UNIQUE KEY `series_position` (`series_id`, `position`),
+	FOREIGN KEY (`post_id`)
+		REFERENCES posts(`id`)
+		ON DELETE CASCADE,
+	FOREIGN KEY (`series_id`)
+		REFERENCES series(`id`)
+		ON DELETE CASCADE,
+	PRIMARY KEY (`post_id`)
+) ENGINE=InnoDB;
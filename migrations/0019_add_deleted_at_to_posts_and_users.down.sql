@@ -0,0 +1,6 @@
+ALTER TABLE `posts`
+DROP INDEX `deleted_at`,
+DROP COLUMN `deleted_at`;
+ALTER TABLE `users`
+DROP INDEX `deleted_at`,
+DROP COLUMN `deleted_at`;
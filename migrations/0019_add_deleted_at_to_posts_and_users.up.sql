@@ -0,0 +1,8 @@
+ALTER TABLE `posts`
+ADD COLUMN `deleted_at` DATETIME NULL DEFAULT NULL,
+ADD INDEX `deleted_at` (`deleted_at`);
+ALTER TABLE `users`
+ADD COLUMN `deleted_at` DATETIME NULL DEFAULT NULL,
+ADD INDEX `deleted_at` (`deleted_at`);
+ALTER TABLE `audit_events`
+MODIFY COLUMN `action` ENUM('create', 'update', 'delete', 'restore', 'purge') NOT NULL;
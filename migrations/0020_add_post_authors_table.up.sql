@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS `post_authors` (
+	`post_id` INT NOT NULL,
+	`user_id` INT NOT NULL,
+	`position` INT NOT NULL,
+	INDEX `user_id` (`user_id`),
+	FOREIGN KEY (`post_id`)
+		REFERENCES posts(`id`)
+		ON DELETE CASCADE,
+	FOREIGN KEY (`user_id`)
+		REFERENCES users(`id`)
+		ON DELETE CASCADE,
+	PRIMARY KEY (`post_id`, `user_id`)
+) ENGINE=InnoDB;
+INSERT INTO `post_authors` (`post_id`, `user_id`, `position`)
+	SELECT `id`, `user_id`, 1 FROM `posts`;
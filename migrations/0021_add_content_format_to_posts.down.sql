@@ -0,0 +1,3 @@
+ALTER TABLE `posts`
+DROP COLUMN `content_html`,
+DROP COLUMN `content_format`;
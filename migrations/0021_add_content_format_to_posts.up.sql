@@ -0,0 +1,3 @@
+ALTER TABLE `posts`
+ADD COLUMN `content_format` ENUM('html', 'markdown') NOT NULL DEFAULT 'html' AFTER `content`,
+ADD COLUMN `content_html` LONGTEXT AFTER `content_format`;
@@ -0,0 +1,6 @@
+ALTER TABLE `posts`
+DROP INDEX `reading_time`,
+DROP INDEX `word_count`,
+DROP COLUMN `reading_time`,
+DROP COLUMN `word_count`,
+DROP COLUMN `excerpt_generated`;
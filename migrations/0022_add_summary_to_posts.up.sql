@@ -0,0 +1,8 @@
+ALTER TABLE `posts`
+ADD COLUMN `excerpt_generated` TINYINT(1) NOT NULL DEFAULT 0 AFTER `excerpt`,
+ADD COLUMN `word_count` INT UNSIGNED NOT NULL DEFAULT 0 AFTER `content_html`,
+ADD COLUMN `reading_time` INT UNSIGNED NOT NULL DEFAULT 0 AFTER `word_count`,
+ADD INDEX `word_count` (`word_count`),
+ADD INDEX `reading_time` (`reading_time`);
+
+UPDATE `posts` SET `content_html` = NULL;
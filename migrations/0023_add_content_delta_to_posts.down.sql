@@ -0,0 +1,6 @@
+ALTER TABLE `post_revisions`
+DROP COLUMN `content_delta`;
+UPDATE `posts` SET `content_format` = 'html' WHERE `content_format` = 'delta';
+ALTER TABLE `posts`
+DROP COLUMN `content_delta`,
+MODIFY COLUMN `content_format` ENUM('html', 'markdown') NOT NULL DEFAULT 'html';
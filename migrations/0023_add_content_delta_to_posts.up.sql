@@ -0,0 +1,5 @@
+ALTER TABLE `posts`
+MODIFY COLUMN `content_format` ENUM('html', 'markdown', 'delta') NOT NULL DEFAULT 'html',
+ADD COLUMN `content_delta` LONGTEXT AFTER `content_format`;
+ALTER TABLE `post_revisions`
+ADD COLUMN `content_delta` LONGTEXT AFTER `content`;
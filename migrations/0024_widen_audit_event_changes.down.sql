@@ -0,0 +1,2 @@
+ALTER TABLE `audit_events`
+MODIFY COLUMN `changes` TEXT NOT NULL;
@@ -0,0 +1,2 @@
+ALTER TABLE `post_revisions`
+DROP COLUMN `content_format`;
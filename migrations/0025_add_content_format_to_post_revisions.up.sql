@@ -0,0 +1,2 @@
+ALTER TABLE `post_revisions`
+ADD COLUMN `content_format` ENUM('html', 'markdown', 'delta') AFTER `content`;
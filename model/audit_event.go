@@ -0,0 +1,249 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"github.com/manyminds/api2go/jsonapi"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// AuditActionCreate records a resource being created
+	AuditActionCreate = "create"
+
+	// AuditActionUpdate records a resource being changed
+	AuditActionUpdate = "update"
+
+	// AuditActionDelete records a resource being deleted, which moves posts
+	// and users to the trash
+	AuditActionDelete = "delete"
+
+	// AuditActionRestore records a resource being taken out of the trash
+	AuditActionRestore = "restore"
+
+	// AuditActionPurge records a resource in the trash being deleted for good
+	AuditActionPurge = "purge"
+)
+
+// auditRedacted replaces the values of fields too sensitive to store, such as
+// password hashes
+const auditRedacted = "[redacted]"
+
+// AuditChange is the value of a single field before and after a write
+type AuditChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditChanges maps field names to how they changed, stored as JSON
+type AuditChanges map[string]AuditChange
+
+// Scan satisfies the sql.Scanner interface
+func (c *AuditChanges) Scan(src interface{}) error {
+	var value []byte
+
+	switch v := src.(type) {
+	case []byte:
+		value = v
+	case string:
+		value = []byte(v)
+	case nil:
+		*c = AuditChanges{}
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into AuditChanges", src)
+	}
+
+	return json.Unmarshal(value, c)
+}
+
+// Value satisfies the driver.Valuer interface
+func (c AuditChanges) Value() (driver.Value, error) {
+	if c == nil {
+		return "{}", nil
+	}
+
+	value, err := json.Marshal(c)
+	return string(value), err
+}
+
+// AuditEvent records a single write made through the API: who made it, from
+// where, and what changed. Audit events are never changed or deleted.
+type AuditEvent struct {
+	ID int64 `json:"-"`
+
+	CreatedAt    time.Time    `json:"created-at" db:"created_at"`
+	Action       string       `json:"action" db:"action"`
+	ResourceType string       `json:"resource-type" db:"resource_type"`
+	ResourceId   string       `json:"resource-id" db:"resource_id"`
+	ClientIP     string       `json:"client-ip" db:"client_ip"`
+	Changes      AuditChanges `json:"changes" db:"changes"`
+	ActorId      *string      `json:"-" db:"actor_id"`
+}
+
+// GetName satisfies the jsonapi.EntityNamer interface, so audit events are
+// served as audit-events rather than auditEvents
+func (m AuditEvent) GetName() string {
+	return "audit-events"
+}
+
+func (m AuditEvent) GetID() string {
+	return strconv.FormatInt(m.ID, 10)
+}
+
+func (m *AuditEvent) SetID(id string) error {
+	var err error
+	m.ID, err = strconv.ParseInt(id, 10, 64)
+	return err
+}
+
+// GetReferences to satisfy the jsonapi.MarshalReferences interface
+func (m AuditEvent) GetReferences() []jsonapi.Reference {
+	return []jsonapi.Reference{
+		{
+			Type:         "users",
+			Name:         "actor",
+			Relationship: jsonapi.ToOneRelationship,
+		},
+	}
+}
+
+// GetReferencedIDs to satisfy the jsonapi.MarshalLinkedRelations interface.
+// Writes made without logging in, such as creating the first user, have no
+// actor.
+func (m AuditEvent) GetReferencedIDs() []jsonapi.ReferenceID {
+	if m.ActorId == nil {
+		return []jsonapi.ReferenceID{}
+	}
+
+	return []jsonapi.ReferenceID{
+		{
+			ID:   *m.ActorId,
+			Type: "users",
+			Name: "actor",
+		},
+	}
+}
+
+// Diff compares two values of the same struct type field by field, returning
+// the fields that differ. Either value may be nil, for resources being created
+// or deleted.
+//
+// Fields are named after their json tags, and fields hidden from json are left
+// out. An audit tag overrides this: `audit:"-"` leaves a field out,
+// `audit:"name"` includes a hidden field under that name, and
+// `audit:"name,redact"` records that the field changed without its values.
+func Diff(before, after interface{}) AuditChanges {
+	changes := AuditChanges{}
+
+	beforeValue := structValue(before)
+	afterValue := structValue(after)
+
+	var structType reflect.Type
+	if beforeValue.IsValid() {
+		structType = beforeValue.Type()
+	} else if afterValue.IsValid() {
+		structType = afterValue.Type()
+	} else {
+		return changes
+	}
+
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+
+		name, redact, ok := auditFieldName(field)
+		if !ok {
+			continue
+		}
+
+		var beforeField, afterField interface{}
+		if beforeValue.IsValid() {
+			beforeField = fieldValue(beforeValue.Field(i))
+		}
+		if afterValue.IsValid() {
+			afterField = fieldValue(afterValue.Field(i))
+		}
+
+		if beforeValue.IsValid() && afterValue.IsValid() && reflect.DeepEqual(beforeField, afterField) {
+			continue
+		}
+
+		if redact {
+			if beforeField != nil {
+				beforeField = auditRedacted
+			}
+			if afterField != nil {
+				afterField = auditRedacted
+			}
+		}
+
+		changes[name] = AuditChange{Before: beforeField, After: afterField}
+	}
+
+	return changes
+}
+
+// structValue dereferences a struct or pointer to a struct, returning the zero
+// Value for nil
+func structValue(value interface{}) reflect.Value {
+	v := reflect.ValueOf(value)
+	for v.IsValid() && v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+
+		v = v.Elem()
+	}
+
+	return v
+}
+
+// fieldValue returns a field's value for comparing and storing, with pointers
+// dereferenced and times in UTC
+func fieldValue(v reflect.Value) interface{} {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+
+		v = v.Elem()
+	}
+
+	if !v.CanInterface() {
+		return nil
+	}
+
+	if t, ok := v.Interface().(time.Time); ok {
+		return t.UTC().Format(time.RFC3339)
+	}
+
+	return v.Interface()
+}
+
+// auditFieldName returns the name a field is audited under, whether its
+// values are redacted, and whether it is audited at all
+func auditFieldName(field reflect.StructField) (string, bool, bool) {
+	if len(field.PkgPath) > 0 {
+		return "", false, false
+	}
+
+	if tag, ok := field.Tag.Lookup("audit"); ok {
+		parts := strings.Split(tag, ",")
+		if parts[0] == "-" {
+			return "", false, false
+		}
+
+		return parts[0], len(parts) > 1 && parts[1] == "redact", true
+	}
+
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "-" || len(name) == 0 {
+		return "", false, false
+	}
+
+	return name, false, true
+}
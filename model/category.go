@@ -0,0 +1,126 @@
+package model
+
+import (
+	"fmt"
+	"github.com/manyminds/api2go/jsonapi"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxCategoryNameLength is the longest category name the categories table
+// holds
+const MaxCategoryNameLength = 100
+
+// Category files posts under a subject, and may be nested under a parent
+// category. Its slug is made from its name and names it in URLs and filters.
+type Category struct {
+	ID int64 `json:"-"`
+
+	CreatedAt time.Time `json:"created-at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated-at" db:"updated_at"`
+	Name      string    `json:"name" db:"name"`
+	Slug      string    `json:"slug" db:"slug"`
+
+	// Path lists the ids of the category's ancestors and then its own, like
+	// "/1/4/" for category 4 under category 1, so that a category's
+	// descendants are the categories whose paths start with its own
+	Path string `json:"path" db:"path"`
+
+	// ParentId is nil for top level categories
+	ParentId *string `json:"-" db:"parent_id" audit:"parent"`
+}
+
+func (m Category) GetID() string {
+	return strconv.FormatInt(m.ID, 10)
+}
+
+func (m *Category) SetID(id string) error {
+	var err error
+	m.ID, err = strconv.ParseInt(id, 10, 64)
+	return err
+}
+
+// GetReferences to satisfy the jsonapi.MarshalReferences interface
+func (m Category) GetReferences() []jsonapi.Reference {
+	return []jsonapi.Reference{
+		{
+			Type:         "categories",
+			Name:         "parent",
+			Relationship: jsonapi.ToOneRelationship,
+		},
+		{
+			Type:         "categories",
+			Name:         "children",
+			Relationship: jsonapi.ToManyRelationship,
+			IsNotLoaded:  true,
+		},
+		{
+			Type:         "posts",
+			Name:         "posts",
+			Relationship: jsonapi.ToManyRelationship,
+			IsNotLoaded:  true,
+		},
+	}
+}
+
+// GetReferencedIDs to satisfy the jsonapi.MarshalLinkedRelations interface
+func (m Category) GetReferencedIDs() []jsonapi.ReferenceID {
+	result := []jsonapi.ReferenceID{}
+
+	if m.ParentId != nil {
+		result = append(result, jsonapi.ReferenceID{
+			ID:   *m.ParentId,
+			Type: "categories",
+			Name: "parent",
+		})
+	}
+
+	return result
+}
+
+// SetToOneReferenceID sets the parent's id and satisfies the
+// jsonapi.UnmarshalToOneRelations interface. An empty id makes the category
+// a top level one.
+func (m *Category) SetToOneReferenceID(name, ID string) error {
+	switch name {
+	case "parent":
+		if len(ID) == 0 {
+			m.ParentId = nil
+		} else {
+			m.ParentId = &ID
+		}
+	default:
+		return fmt.Errorf("Category has no relationship called %s", name)
+	}
+
+	return nil
+}
+
+// IsAncestorOf reports whether a category is above another, at any depth
+func (m Category) IsAncestorOf(other Category) bool {
+	return len(m.Path) > 0 && other.Path != m.Path && strings.HasPrefix(other.Path, m.Path)
+}
+
+// ParentPath returns the path of the category's parent, or "/" for a top
+// level category
+func (m Category) ParentPath() string {
+	trimmed := strings.TrimSuffix(m.Path, "/")
+	return trimmed[:strings.LastIndex(trimmed, "/")+1]
+}
+
+// Validate checks a category's name, returning one ValidationError per
+// problem found
+func (m Category) Validate() []ValidationError {
+	var errs []ValidationError
+
+	name := strings.TrimSpace(m.Name)
+	if len(name) == 0 {
+		errs = append(errs, ValidationError{"name", "is required"})
+	} else if utf8.RuneCountInString(name) > MaxCategoryNameLength {
+		errs = append(errs, ValidationError{"name", fmt.Sprintf("must be at most %d characters", MaxCategoryNameLength)})
+	}
+
+	return errs
+}
@@ -0,0 +1,22 @@
+package model
+
+import (
+	"time"
+)
+
+// JobRun records the last run of a background job, for monitoring
+type JobRun struct {
+	Name        string     `db:"name"`
+	StartedAt   *time.Time `db:"started_at"`
+	FinishedAt  *time.Time `db:"finished_at"`
+	SucceededAt *time.Time `db:"succeeded_at"`
+	Runs        int        `db:"runs"`
+	Items       int        `db:"items"`
+	LastError   string     `db:"last_error"`
+}
+
+// IsRunning reports whether the job started a run that has not finished,
+// either because it is still going or because the server stopped mid-run
+func (m JobRun) IsRunning() bool {
+	return m.StartedAt != nil && (m.FinishedAt == nil || m.FinishedAt.Before(*m.StartedAt))
+}
@@ -0,0 +1,48 @@
+package model
+
+import (
+	"strconv"
+	"time"
+)
+
+const (
+	// LockoutEventLocked records an account or client IP being locked out
+	LockoutEventLocked = "locked"
+
+	// LockoutEventUnlocked records an admin unlocking an account
+	LockoutEventUnlocked = "unlocked"
+)
+
+// LoginThrottle counts failed logins from a single client IP, across every
+// account it tries
+type LoginThrottle struct {
+	ClientIP     string     `db:"client_ip"`
+	Failures     int        `db:"failures"`
+	LastFailedAt *time.Time `db:"last_failed_at"`
+	Lockouts     int        `db:"lockouts"`
+	LockedUntil  *time.Time `db:"locked_until"`
+}
+
+// LockoutEvent records an account or client IP being locked out after too
+// many failed logins, or an admin unlocking an account
+type LockoutEvent struct {
+	ID int64 `db:"id"`
+
+	CreatedAt   time.Time  `db:"created_at"`
+	Event       string     `db:"event"`
+	UserId      *string    `db:"user_id"`
+	ClientIP    *string    `db:"client_ip"`
+	LockedUntil *time.Time `db:"locked_until"`
+
+	// ActorId is the admin who unlocked an account
+	ActorId *string `db:"actor_id"`
+}
+
+func (m LockoutEvent) GetID() string {
+	return strconv.FormatInt(m.ID, 10)
+}
+
+// IsLocked reports whether a lockout is still in force
+func IsLocked(lockedUntil *time.Time, now time.Time) bool {
+	return lockedUntil != nil && lockedUntil.After(now)
+}
@@ -0,0 +1,89 @@
+package model
+
+import (
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+)
+
+const (
+	// MinPasswordLength is the shortest password a user may choose
+	MinPasswordLength = 10
+
+	// MaxPasswordLength is the longest password bcrypt will fully consider
+	MaxPasswordLength = 72
+)
+
+// commonPasswords is a short list of passwords that are long enough to pass
+// the length check but are guessed first by anyone attacking an account
+var commonPasswords = map[string]bool{
+	"1234567890":    true,
+	"0123456789":    true,
+	"password123":   true,
+	"password1234":  true,
+	"qwertyuiop":    true,
+	"iloveyou123":   true,
+	"letmein1234":   true,
+	"administrator": true,
+	"passw0rd123":   true,
+	"1q2w3e4r5t":    true,
+}
+
+// HashPassword returns the bcrypt hash of a password
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
+
+// CheckPassword reports whether password matches the user's stored hash
+func (m User) CheckPassword(password string) bool {
+	err := bcrypt.CompareHashAndPassword([]byte(m.PasswordHash), []byte(password))
+	return err == nil
+}
+
+// ValidatePassword checks a password and its confirmation against the
+// password policy, returning one ValidationError per problem found
+func (m NewUser) ValidatePassword() []ValidationError {
+	var errs []ValidationError
+
+	password := m.Password
+	lowered := strings.ToLower(password)
+
+	switch {
+	case len(password) == 0:
+		errs = append(errs, ValidationError{"password", "is required"})
+	case len(password) < MinPasswordLength:
+		errs = append(errs, ValidationError{"password", "must be at least 10 characters long"})
+	case len(password) > MaxPasswordLength:
+		errs = append(errs, ValidationError{"password", "must be at most 72 bytes long"})
+	case commonPasswords[lowered]:
+		errs = append(errs, ValidationError{"password", "is too common"})
+	case repeatsOneCharacter(password):
+		errs = append(errs, ValidationError{"password", "must not repeat a single character"})
+	case m.Username != "" && strings.Contains(lowered, strings.ToLower(m.Username)):
+		errs = append(errs, ValidationError{"password", "must not contain the username"})
+	}
+
+	if m.PasswordConfirmation != password {
+		errs = append(errs, ValidationError{"password-confirmation", "does not match password"})
+	}
+
+	return errs
+}
+
+// repeatsOneCharacter reports whether a password is a single character over
+// and over, comparing characters rather than bytes so that multibyte
+// characters are caught too
+func repeatsOneCharacter(password string) bool {
+	runes := []rune(password)
+	for _, r := range runes {
+		if r != runes[0] {
+			return false
+		}
+	}
+
+	return len(runes) > 0
+}
@@ -0,0 +1,22 @@
+package model
+
+import (
+	"strconv"
+	"time"
+)
+
+// PasswordReset is a single-use request to reset a user's password. Only the
+// hash of the token sent to the user is stored.
+type PasswordReset struct {
+	ID int64 `db:"id"`
+
+	CreatedAt time.Time  `db:"created_at"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	UsedAt    *time.Time `db:"used_at"`
+	UserId    string     `db:"user_id"`
+	TokenHash string     `db:"token_hash"`
+}
+
+func (m PasswordReset) GetID() string {
+	return strconv.FormatInt(m.ID, 10)
+}
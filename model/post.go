@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/manyminds/api2go/jsonapi"
+	"github.com/timrourke/timrourke.com/delta"
 	"strconv"
 	"time"
 )
@@ -17,8 +18,47 @@ type Post struct {
 	Excerpt   string    `json:"excerpt" db:"excerpt"`
 	Content   string    `json:"content" db:"content"`
 	Permalink string    `json:"permalink" db:"permalink"`
-	User      *User     `json:"-"`
-	UserId    string    `json:"-" db:"user_id"`
+
+	// ContentFormat says how Content is written. ContentHTML is rendered
+	// from it on every save and cannot be written directly. Delta content is
+	// written as the editor's document in ContentDelta, and Content is
+	// rendered from it.
+	ContentFormat string       `json:"content-format" db:"content_format"`
+	ContentDelta  *delta.Delta `json:"content-delta" db:"content_delta" audit:"-"`
+	ContentHTML   *string      `json:"content-html" db:"content_html" audit:"-"`
+
+	// ExcerptGenerated is set while Excerpt was generated from Content
+	// rather than written by an author. WordCount and ReadingTime, in
+	// minutes, are counted from Content on every save. None of them can be
+	// written directly.
+	ExcerptGenerated bool `json:"excerpt-generated" db:"excerpt_generated" audit:"-"`
+	WordCount        int  `json:"word-count" db:"word_count" audit:"-"`
+	ReadingTime      int  `json:"reading-time" db:"reading_time" audit:"-"`
+
+	// Version goes up with every update, which must send back the version it
+	// read so that one editor cannot silently overwrite another
+	Version int `json:"version" db:"version" audit:"-"`
+
+	// Status changes go through Transition
+	Status      string     `json:"status" db:"status"`
+	PublishedAt *time.Time `json:"published-at" db:"published_at"`
+
+	// DeletedAt is set while the post is in the trash
+	DeletedAt *time.Time `json:"deleted-at,omitempty" db:"deleted_at"`
+
+	// UserId is the post's owner, who is always among its authors
+	User   *User  `json:"-"`
+	UserId string `json:"-" db:"user_id" audit:"user"`
+
+	// CategoryId is nil for uncategorized posts
+	CategoryId *string `json:"-" db:"category_id" audit:"category"`
+
+	// AuthorIDs, TagIDs and SeriesId are loaded and saved separately from
+	// the posts table. AuthorIDs is in byline order. SeriesId is nil for
+	// posts in no series.
+	AuthorIDs []string `json:"-" db:"-" audit:"authors"`
+	TagIDs    []string `json:"-" db:"-" audit:"tags"`
+	SeriesId  *string  `json:"-" db:"-" audit:"series"`
 }
 
 func (m Post) GetID() string {
@@ -39,6 +79,32 @@ func (m Post) GetReferences() []jsonapi.Reference {
 			Name:         "user",
 			Relationship: jsonapi.ToOneRelationship,
 		},
+		{
+			Type:         "users",
+			Name:         "authors",
+			Relationship: jsonapi.ToManyRelationship,
+		},
+		{
+			Type:         "categories",
+			Name:         "category",
+			Relationship: jsonapi.ToOneRelationship,
+		},
+		{
+			Type:         "tags",
+			Name:         "tags",
+			Relationship: jsonapi.ToManyRelationship,
+		},
+		{
+			Type:         "series",
+			Name:         "series",
+			Relationship: jsonapi.ToOneRelationship,
+		},
+		{
+			Type:         "post-revisions",
+			Name:         "revisions",
+			Relationship: jsonapi.ToManyRelationship,
+			IsNotLoaded:  true,
+		},
 	}
 }
 
@@ -52,15 +118,62 @@ func (m Post) GetReferencedIDs() []jsonapi.ReferenceID {
 		Name: "user",
 	})
 
+	for _, authorID := range m.AuthorIDs {
+		result = append(result, jsonapi.ReferenceID{
+			ID:   authorID,
+			Type: "users",
+			Name: "authors",
+		})
+	}
+
+	if m.CategoryId != nil {
+		result = append(result, jsonapi.ReferenceID{
+			ID:   *m.CategoryId,
+			Type: "categories",
+			Name: "category",
+		})
+	}
+
+	for _, tagID := range m.TagIDs {
+		result = append(result, jsonapi.ReferenceID{
+			ID:   tagID,
+			Type: "tags",
+			Name: "tags",
+		})
+	}
+
+	if m.SeriesId != nil {
+		result = append(result, jsonapi.ReferenceID{
+			ID:   *m.SeriesId,
+			Type: "series",
+			Name: "series",
+		})
+	}
+
 	return result
 }
 
-func (m Post) SetToOneReferenceID(name, ID string) error {
+// SetToOneReferenceID sets the author's, category's or series' id and
+// satisfies the jsonapi.UnmarshalToOneRelations interface. An empty category
+// or series id takes the post out of its category or series.
+func (m *Post) SetToOneReferenceID(name, ID string) error {
 	var err error
 
 	switch name {
 	case "user":
 		m.UserId = ID
+	case "category":
+		if len(ID) == 0 {
+			m.CategoryId = nil
+		} else {
+			m.CategoryId = &ID
+		}
+	case "series":
+		if len(ID) == 0 {
+			m.SeriesId = nil
+		} else {
+			m.SeriesId = &ID
+		}
 	default:
 		err = fmt.Errorf("Post has no relationship called %s", name)
 	}
@@ -71,45 +184,103 @@ func (m Post) SetToOneReferenceID(name, ID string) error {
 // GetReferencedStructs to satisfy the jsonapi.MarhsalIncludedRelations interface
 func (m Post) GetReferencedStructs() []jsonapi.MarshalIdentifier {
 	result := []jsonapi.MarshalIdentifier{}
-	// for key := range u.Chocolates {
-	// 	result = append(result, u.Chocolates[key])
-	// }
 
 	return result
 }
 
-// SetToManyReferenceIDs sets the sweets reference IDs and satisfies the jsonapi.UnmarshalToManyRelations interface
+// SetToManyReferenceIDs sets the post's authors or tags and satisfies the
+// jsonapi.UnmarshalToManyRelations interface
 func (m *Post) SetToManyReferenceIDs(name string, IDs []string) error {
-	// if name == "sweets" {
-	// 	u.ChocolatesIDs = IDs
-	// 	return nil
-	// }
+	switch name {
+	case "authors":
+		m.AuthorIDs = []string{}
+	case "tags":
+		m.TagIDs = []string{}
+	default:
+		return errors.New("There is no to-many relationship with the name " + name)
+	}
 
-	return errors.New("There is no to-many relationship with the name " + name)
+	return m.AddToManyIDs(name, IDs)
 }
 
-// AddToManyIDs adds some new sweets that a users loves so much
+// AddToManyIDs adds authors to the end of the byline or tags the post, and
+// satisfies the jsonapi.EditToManyRelations interface. Authors and tags the
+// post already has are ignored.
 func (m *Post) AddToManyIDs(name string, IDs []string) error {
-	// if name == "sweets" {
-	// 	u.ChocolatesIDs = append(u.ChocolatesIDs, IDs...)
-	// 	return nil
-	// }
+	switch name {
+	case "authors":
+		for _, ID := range IDs {
+			if !containsID(m.AuthorIDs, ID) {
+				m.AuthorIDs = append(m.AuthorIDs, ID)
+			}
+		}
+	case "tags":
+		for _, ID := range IDs {
+			if !m.HasTag(ID) {
+				m.TagIDs = append(m.TagIDs, ID)
+			}
+		}
+	default:
+		return errors.New("There is no to-many relationship with the name " + name)
+	}
 
-	return errors.New("There is no to-many relationship with the name " + name)
+	return nil
 }
 
-// DeleteToManyIDs removes some sweets from a users because they made him very sick
+// DeleteToManyIDs removes authors from the post or untags it, and satisfies
+// the jsonapi.EditToManyRelations interface. The owner stays an author.
 func (m *Post) DeleteToManyIDs(name string, IDs []string) error {
-	// if name == "sweets" {
-	// 	for _, ID := range IDs {
-	// 		for pos, oldID := range u.ChocolatesIDs {
-	// 			if ID == oldID {
-	// 				// match, this ID must be removed
-	// 				u.ChocolatesIDs = append(u.ChocolatesIDs[:pos], u.ChocolatesIDs[pos+1:]...)
-	// 			}
-	// 		}
-	// 	}
-	// }
-
-	return errors.New("There is no to-many relationship with the name " + name)
+	switch name {
+	case "authors":
+		m.AuthorIDs = withoutIDs(m.AuthorIDs, IDs)
+	case "tags":
+		m.TagIDs = withoutIDs(m.TagIDs, IDs)
+	default:
+		return errors.New("There is no to-many relationship with the name " + name)
+	}
+
+	return nil
+}
+
+// Byline returns the post's authors in order, with its owner first unless
+// they were put elsewhere
+func (m Post) Byline() []string {
+	if len(m.UserId) == 0 || containsID(m.AuthorIDs, m.UserId) {
+		return m.AuthorIDs
+	}
+
+	return append([]string{m.UserId}, m.AuthorIDs...)
+}
+
+// HasAuthor reports whether a user is the owner or a co-author of the post
+func (m Post) HasAuthor(userID string) bool {
+	return m.UserId == userID || containsID(m.AuthorIDs, userID)
+}
+
+// HasTag reports whether the post is tagged with a tag
+func (m Post) HasTag(tagID string) bool {
+	return containsID(m.TagIDs, tagID)
+}
+
+// withoutIDs returns a list of ids with some removed, keeping their order
+func withoutIDs(IDs []string, removed []string) []string {
+	kept := []string{}
+	for _, ID := range IDs {
+		if !containsID(removed, ID) {
+			kept = append(kept, ID)
+		}
+	}
+
+	return kept
+}
+
+// containsID reports whether a list of ids contains one
+func containsID(IDs []string, ID string) bool {
+	for _, candidate := range IDs {
+		if candidate == ID {
+			return true
+		}
+	}
+
+	return false
 }
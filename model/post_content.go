@@ -0,0 +1,179 @@
+package model
+
+import (
+	"github.com/timrourke/timrourke.com/highlight"
+	"github.com/timrourke/timrourke.com/markdown"
+	"github.com/timrourke/timrourke.com/sanitize"
+	"html"
+	"strings"
+	"unicode"
+)
+
+const (
+	// DefaultExcerptLength is roughly how many characters a generated
+	// excerpt runs to
+	DefaultExcerptLength = 300
+
+	// WordsPerMinute is the reading speed reading times are counted at
+	WordsPerMinute = 200
+)
+
+const (
+	// PostContentFormatHTML content is HTML written in the admin's editor
+	PostContentFormatHTML = "html"
+
+	// PostContentFormatMarkdown content is markdown, rendered to HTML on the
+	// server
+	PostContentFormatMarkdown = "markdown"
+
+	// PostContentFormatDelta content is HTML rendered on the server from the
+	// Quill delta the admin's editor produces
+	PostContentFormatDelta = "delta"
+)
+
+// PostContentFormats is the set of formats a post's content may be written in
+var PostContentFormats = map[string]bool{
+	PostContentFormatHTML:     true,
+	PostContentFormatMarkdown: true,
+	PostContentFormatDelta:    true,
+}
+
+// ValidateContentFormat checks the format a post's content is written in, and
+// that delta content comes with its delta
+func (m Post) ValidateContentFormat() *ValidationError {
+	if !PostContentFormats[m.ContentFormat] {
+		return &ValidationError{"content-format", "must be one of html, markdown or delta"}
+	}
+
+	if m.ContentFormat != PostContentFormatDelta {
+		return nil
+	}
+
+	if m.ContentDelta == nil {
+		return &ValidationError{"content-delta", "is required for delta content"}
+	} else if err := m.ContentDelta.Validate(); err != nil {
+		return &ValidationError{"content-delta", err.Error()}
+	}
+
+	return nil
+}
+
+// DeriveContent renders delta content to the HTML kept in Content, so that
+// it always follows from the delta. Posts in other formats keep no delta.
+func (m *Post) DeriveContent() {
+	if m.ContentFormat != PostContentFormatDelta {
+		m.ContentDelta = nil
+	} else if m.ContentDelta != nil {
+		m.Content = m.ContentDelta.HTML()
+	}
+}
+
+// Sanitize strips everything the policy does not allow from a post's excerpt,
+// and from its content when that is HTML. Markdown is left as written, since
+// it renders to safe HTML on its own.
+func (m *Post) Sanitize(policy *sanitize.Policy) {
+	m.Excerpt = policy.HTML(m.Excerpt)
+	if m.ContentFormat != PostContentFormatMarkdown {
+		m.Content = policy.HTML(m.Content)
+	}
+}
+
+// RenderContent caches the HTML a post's content renders to, with its code
+// blocks highlighted, and counts the words in it
+func (m *Post) RenderContent() {
+	rendered := m.Content
+	if m.ContentFormat == PostContentFormatMarkdown {
+		rendered = markdown.Render(m.Content)
+	}
+	rendered = highlight.HTML(rendered)
+
+	m.ContentHTML = &rendered
+	m.WordCount = len(strings.Fields(sanitize.Text(rendered)))
+	m.ReadingTime = (m.WordCount + WordsPerMinute - 1) / WordsPerMinute
+}
+
+// GenerateExcerpt generates a post's excerpt from its content, of around
+// length characters, when the author left the excerpt blank. An excerpt
+// generated before is generated again unless it was edited since, so
+// previous is the post as it was before this change, or nil for new posts.
+func (m *Post) GenerateExcerpt(length int, previous *Post) {
+	if length <= 0 {
+		length = DefaultExcerptLength
+	}
+
+	blank := len(sanitize.Text(m.Excerpt)) == 0
+	unedited := previous != nil && previous.ExcerptGenerated && m.Excerpt == previous.Excerpt
+
+	m.ExcerptGenerated = blank || unedited
+	if !m.ExcerptGenerated {
+		return
+	}
+
+	m.RenderContent()
+	m.Excerpt = html.EscapeString(excerptOf(sanitize.Text(*m.ContentHTML), length))
+}
+
+// HTML returns the HTML a post's content renders to, rendering it now for
+// posts saved before it was cached. Those posts may also predate sanitizing,
+// so their content is sanitized by policy first.
+func (m Post) HTML(policy *sanitize.Policy) string {
+	if m.ContentHTML == nil {
+		m.Sanitize(policy)
+		m.RenderContent()
+	}
+
+	return *m.ContentHTML
+}
+
+// excerptOf cuts text at the end of the sentence nearest length characters.
+// Text with no sentence ending near there is cut between words instead.
+func excerptOf(text string, length int) string {
+	runes := []rune(text)
+	if len(runes) <= length {
+		return text
+	}
+
+	// Prefer the last sentence to end before length, unless that would leave
+	// less than half of it, then the first to end soon after
+	end := -1
+	for i := 0; i < len(runes) && i < length+length/2; i++ {
+		if !isSentenceEnd(runes, i) {
+			continue
+		}
+
+		if i < length {
+			end = i
+		} else {
+			if end < length/2 {
+				end = i
+			}
+			break
+		}
+	}
+
+	if end >= length/2 {
+		return string(runes[:end+1])
+	}
+
+	cut := length
+	for cut > 0 && !unicode.IsSpace(runes[cut]) {
+		cut--
+	}
+	if cut == 0 {
+		cut = length
+	}
+
+	return strings.TrimRightFunc(string(runes[:cut]), func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r)
+	}) + "…"
+}
+
+// isSentenceEnd reports whether the rune at i ends a sentence
+func isSentenceEnd(runes []rune, i int) bool {
+	switch runes[i] {
+	case '.', '!', '?':
+		return i+1 == len(runes) || unicode.IsSpace(runes[i+1])
+	}
+
+	return false
+}
@@ -0,0 +1,119 @@
+package model
+
+import (
+	"github.com/manyminds/api2go/jsonapi"
+	"github.com/timrourke/timrourke.com/delta"
+	"strconv"
+	"time"
+)
+
+// PostRevision is a copy of a post's title, excerpt, content and permalink as
+// they were before a change, so a bad save can be undone
+type PostRevision struct {
+	ID int64 `json:"-"`
+
+	CreatedAt time.Time `json:"created-at" db:"created_at"`
+	Title     string    `json:"title" db:"title"`
+	Excerpt   string    `json:"excerpt" db:"excerpt"`
+	Content   string    `json:"content" db:"content"`
+	Permalink string    `json:"permalink" db:"permalink"`
+
+	// ContentFormat says how Content is written. Revisions saved before it
+	// was kept have none.
+	ContentFormat *string `json:"content-format" db:"content_format"`
+
+	// ContentDelta is the delta Content was rendered from, if it was
+	ContentDelta *delta.Delta `json:"content-delta" db:"content_delta"`
+
+	PostId   string  `json:"-" db:"post_id"`
+	EditorId *string `json:"-" db:"editor_id"`
+}
+
+// GetName satisfies the jsonapi.EntityNamer interface, so revisions are served
+// as post-revisions
+func (m PostRevision) GetName() string {
+	return "post-revisions"
+}
+
+func (m PostRevision) GetID() string {
+	return strconv.FormatInt(m.ID, 10)
+}
+
+func (m *PostRevision) SetID(id string) error {
+	var err error
+	m.ID, err = strconv.ParseInt(id, 10, 64)
+	return err
+}
+
+// GetReferences to satisfy the jsonapi.MarshalReferences interface
+func (m PostRevision) GetReferences() []jsonapi.Reference {
+	return []jsonapi.Reference{
+		{
+			Type:         "posts",
+			Name:         "post",
+			Relationship: jsonapi.ToOneRelationship,
+		},
+		{
+			Type:         "users",
+			Name:         "editor",
+			Relationship: jsonapi.ToOneRelationship,
+		},
+	}
+}
+
+// GetReferencedIDs to satisfy the jsonapi.MarshalLinkedRelations interface.
+// Revisions made by the scheduler, or by users since deleted, have no editor.
+func (m PostRevision) GetReferencedIDs() []jsonapi.ReferenceID {
+	result := []jsonapi.ReferenceID{
+		{
+			ID:   m.PostId,
+			Type: "posts",
+			Name: "post",
+		},
+	}
+
+	if m.EditorId != nil {
+		result = append(result, jsonapi.ReferenceID{
+			ID:   *m.EditorId,
+			Type: "users",
+			Name: "editor",
+		})
+	}
+
+	return result
+}
+
+// Restore copies the revision's fields back onto a post
+func (m PostRevision) Restore(post *Post) {
+	post.Title = m.Title
+	post.Excerpt = m.Excerpt
+	post.Content = m.Content
+	post.ContentDelta = m.ContentDelta
+	post.Permalink = m.Permalink
+
+	// Revisions saved before their format was kept are HTML, unless they
+	// were written in the editor
+	switch {
+	case m.ContentFormat != nil:
+		post.ContentFormat = *m.ContentFormat
+	case m.ContentDelta != nil:
+		post.ContentFormat = PostContentFormatDelta
+	default:
+		post.ContentFormat = PostContentFormatHTML
+	}
+}
+
+// RevisionOf returns a revision holding a post's current fields, for comparing
+// revisions against the live post
+func RevisionOf(post Post) PostRevision {
+	return PostRevision{
+		CreatedAt:     post.UpdatedAt,
+		Title:         post.Title,
+		Excerpt:       post.Excerpt,
+		Content:       post.Content,
+		ContentFormat: &post.ContentFormat,
+		ContentDelta:  post.ContentDelta,
+		Permalink:     post.Permalink,
+		PostId:        post.GetID(),
+	}
+}
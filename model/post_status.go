@@ -0,0 +1,90 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// PostStatusDraft posts are only visible to logged in users
+	PostStatusDraft = "draft"
+
+	// PostStatusPublished posts are public once their published-at time has
+	// passed
+	PostStatusPublished = "published"
+
+	// PostStatusScheduled posts are published automatically at their
+	// published-at time
+	PostStatusScheduled = "scheduled"
+
+	// PostStatusArchived posts were published, but have been taken down
+	PostStatusArchived = "archived"
+)
+
+// PostStatuses is the set of statuses a post may have
+var PostStatuses = map[string]bool{
+	PostStatusDraft:     true,
+	PostStatusPublished: true,
+	PostStatusScheduled: true,
+	PostStatusArchived:  true,
+}
+
+// postTransitions lists the statuses a post may move to from each status. The
+// empty status is a post that is being created.
+var postTransitions = map[string][]string{
+	"":                  {PostStatusDraft, PostStatusPublished, PostStatusScheduled},
+	PostStatusDraft:     {PostStatusPublished, PostStatusScheduled, PostStatusArchived},
+	PostStatusScheduled: {PostStatusDraft, PostStatusPublished, PostStatusArchived},
+	PostStatusPublished: {PostStatusDraft, PostStatusArchived},
+	PostStatusArchived:  {PostStatusDraft},
+}
+
+// CanTransition reports whether a post may move from one status to another
+func CanTransition(from, to string) bool {
+	for _, allowed := range postTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Transition moves a post from the status it had, which is empty for new
+// posts, to a new status. Publishing a post without a published-at time, or
+// with one in the future, publishes it now; scheduling a post needs a
+// published-at time in the future; and returning a post to draft clears its
+// published-at time. Posts may also stay in the status they had, which checks
+// a new published-at time.
+func (m *Post) Transition(from, to string, now time.Time) *ValidationError {
+	if !PostStatuses[to] {
+		return &ValidationError{"status", "must be one of draft, published, scheduled or archived"}
+	}
+
+	if from != to && !CanTransition(from, to) {
+		return &ValidationError{"status", fmt.Sprintf("cannot change from %s to %s", from, to)}
+	}
+
+	m.Status = to
+
+	switch to {
+	case PostStatusDraft:
+		m.PublishedAt = nil
+	case PostStatusPublished:
+		if m.PublishedAt == nil || m.PublishedAt.After(now) {
+			publishedAt := now.UTC().Truncate(time.Second)
+			m.PublishedAt = &publishedAt
+		}
+	case PostStatusScheduled:
+		if m.PublishedAt == nil || !m.PublishedAt.After(now) {
+			return &ValidationError{"published-at", "must be in the future to schedule a post"}
+		}
+	}
+
+	return nil
+}
+
+// IsPublic reports whether anyone may read a post
+func (m Post) IsPublic(now time.Time) bool {
+	return m.Status == PostStatusPublished && m.PublishedAt != nil && !m.PublishedAt.After(now)
+}
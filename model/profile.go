@@ -0,0 +1,141 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// MaxDisplayNameLength is the longest display name a user may have
+	MaxDisplayNameLength = 250
+
+	// MaxBioLength is the longest bio a user may have
+	MaxBioLength = 2000
+
+	// MaxURLLength is the longest avatar, website or social link URL
+	MaxURLLength = 2048
+)
+
+// SocialNetworks is the set of networks a user may link to from their profile
+var SocialNetworks = map[string]bool{
+	"github":    true,
+	"instagram": true,
+	"linkedin":  true,
+	"mastodon":  true,
+	"twitter":   true,
+}
+
+// SocialLinks maps social networks to the URL of a user's profile on them,
+// stored as JSON
+type SocialLinks map[string]string
+
+// Scan satisfies the sql.Scanner interface
+func (l *SocialLinks) Scan(src interface{}) error {
+	var value []byte
+
+	switch v := src.(type) {
+	case []byte:
+		value = v
+	case string:
+		value = []byte(v)
+	case nil:
+		*l = SocialLinks{}
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into SocialLinks", src)
+	}
+
+	return json.Unmarshal(value, l)
+}
+
+// Value satisfies the driver.Valuer interface
+func (l SocialLinks) Value() (driver.Value, error) {
+	if l == nil {
+		return "{}", nil
+	}
+
+	value, err := json.Marshal(l)
+	return string(value), err
+}
+
+// MarshalJSON renders users without social links as an empty object rather
+// than null
+func (l SocialLinks) MarshalJSON() ([]byte, error) {
+	if l == nil {
+		return []byte("{}"), nil
+	}
+
+	return json.Marshal(map[string]string(l))
+}
+
+// Networks returns the networks linked to, in alphabetical order
+func (l SocialLinks) Networks() []string {
+	networks := make([]string, 0, len(l))
+	for network := range l {
+		networks = append(networks, network)
+	}
+
+	sort.Strings(networks)
+	return networks
+}
+
+// Name returns the name to show for a user, which is their display name when
+// they have set one and their username otherwise
+func (m User) Name() string {
+	if len(strings.TrimSpace(m.DisplayName)) > 0 {
+		return m.DisplayName
+	}
+
+	return m.Username
+}
+
+// ValidateProfile checks the public profile attributes, returning one
+// ValidationError per problem found
+func (m User) ValidateProfile() []ValidationError {
+	var errs []ValidationError
+
+	if utf8.RuneCountInString(m.DisplayName) > MaxDisplayNameLength {
+		errs = append(errs, ValidationError{"display-name", fmt.Sprintf("must be at most %d characters", MaxDisplayNameLength)})
+	}
+
+	if utf8.RuneCountInString(m.Bio) > MaxBioLength {
+		errs = append(errs, ValidationError{"bio", fmt.Sprintf("must be at most %d characters", MaxBioLength)})
+	}
+
+	if len(m.AvatarURL) > 0 && !isWebURL(m.AvatarURL) {
+		errs = append(errs, ValidationError{"avatar-url", "must be an http or https URL"})
+	}
+
+	if len(m.Website) > 0 && !isWebURL(m.Website) {
+		errs = append(errs, ValidationError{"website", "must be an http or https URL"})
+	}
+
+	for _, network := range m.SocialLinks.Networks() {
+		if !SocialNetworks[network] {
+			errs = append(errs, ValidationError{"social-links", fmt.Sprintf("contains an unknown network: %s", network)})
+		} else if !isWebURL(m.SocialLinks[network]) {
+			errs = append(errs, ValidationError{"social-links", fmt.Sprintf("must only contain http or https URLs, but %s does not", network)})
+		}
+	}
+
+	return errs
+}
+
+// isWebURL reports whether a string is an absolute http or https URL
+func isWebURL(value string) bool {
+	if len(value) > MaxURLLength {
+		return false
+	}
+
+	u, err := url.Parse(value)
+	if err != nil {
+		return false
+	}
+
+	return (u.Scheme == "http" || u.Scheme == "https") && len(u.Host) > 0
+}
@@ -0,0 +1,21 @@
+package model
+
+import (
+	"strconv"
+	"time"
+)
+
+// RecoveryCode is a single-use code that stands in for a TOTP code when a user
+// has lost their device. Only its hash is stored.
+type RecoveryCode struct {
+	ID int64 `db:"id"`
+
+	CreatedAt time.Time  `db:"created_at"`
+	UsedAt    *time.Time `db:"used_at"`
+	UserId    string     `db:"user_id"`
+	CodeHash  string     `db:"code_hash"`
+}
+
+func (m RecoveryCode) GetID() string {
+	return strconv.FormatInt(m.ID, 10)
+}
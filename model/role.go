@@ -0,0 +1,56 @@
+package model
+
+const (
+	// RoleAdmin may do anything, including managing users
+	RoleAdmin = "admin"
+
+	// RoleEditor may create posts and change or delete anyone's posts
+	RoleEditor = "editor"
+
+	// RoleAuthor may create posts and change or delete their own posts
+	RoleAuthor = "author"
+
+	// RoleContributor may create posts and change their own posts, but may not
+	// delete them
+	RoleContributor = "contributor"
+)
+
+// Roles is the set of roles a user may have
+var Roles = map[string]bool{
+	RoleAdmin:       true,
+	RoleEditor:      true,
+	RoleAuthor:      true,
+	RoleContributor: true,
+}
+
+// IsAdmin reports whether the user may manage other users
+func (m User) IsAdmin() bool {
+	return m.Role == RoleAdmin
+}
+
+// CanEditOthersPosts reports whether the user may change posts they do not own
+func (m User) CanEditOthersPosts() bool {
+	return m.Role == RoleAdmin || m.Role == RoleEditor
+}
+
+// Owns reports whether the user is the owner or a co-author of a post, which
+// gives them the same rights over it
+func (m User) Owns(post Post) bool {
+	return post.HasAuthor(m.GetID())
+}
+
+// CanUpdatePost reports whether the user may change a post
+func (m User) CanUpdatePost(post Post) bool {
+	return m.CanEditOthersPosts() || m.Owns(post)
+}
+
+// CanDeletePost reports whether the user may delete a post
+func (m User) CanDeletePost(post Post) bool {
+	return m.CanEditOthersPosts() || (m.Owns(post) && m.Role == RoleAuthor)
+}
+
+// CanPublishPost reports whether the user may move a post they can change to
+// a status. Contributors may only write drafts for others to publish.
+func (m User) CanPublishPost(status string) bool {
+	return status == PostStatusDraft || m.Role != RoleContributor
+}
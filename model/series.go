@@ -0,0 +1,133 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"github.com/manyminds/api2go/jsonapi"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxSeriesTitleLength is the longest series title the series table holds
+const MaxSeriesTitleLength = 250
+
+// Series is an ordered list of posts meant to be read one after another, like
+// the parts of a tutorial. A post belongs to at most one series.
+type Series struct {
+	ID int64 `json:"-"`
+
+	CreatedAt   time.Time `json:"created-at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated-at" db:"updated_at"`
+	Title       string    `json:"title" db:"title"`
+	Slug        string    `json:"slug" db:"slug"`
+	Description string    `json:"description" db:"description"`
+
+	// PostIDs is in reading order, and is loaded and saved separately from
+	// the series table
+	PostIDs []string `json:"-" db:"-" audit:"posts"`
+}
+
+// GetName satisfies the jsonapi.EntityNamer interface, as series is its own
+// plural
+func (m Series) GetName() string {
+	return "series"
+}
+
+func (m Series) GetID() string {
+	return strconv.FormatInt(m.ID, 10)
+}
+
+func (m *Series) SetID(id string) error {
+	var err error
+	m.ID, err = strconv.ParseInt(id, 10, 64)
+	return err
+}
+
+// GetReferences to satisfy the jsonapi.MarshalReferences interface
+func (m Series) GetReferences() []jsonapi.Reference {
+	return []jsonapi.Reference{
+		{
+			Type:         "posts",
+			Name:         "posts",
+			Relationship: jsonapi.ToManyRelationship,
+		},
+	}
+}
+
+// GetReferencedIDs to satisfy the jsonapi.MarshalLinkedRelations interface
+func (m Series) GetReferencedIDs() []jsonapi.ReferenceID {
+	result := []jsonapi.ReferenceID{}
+
+	for _, postID := range m.PostIDs {
+		result = append(result, jsonapi.ReferenceID{
+			ID:   postID,
+			Type: "posts",
+			Name: "posts",
+		})
+	}
+
+	return result
+}
+
+// SetToManyReferenceIDs sets the series' posts in reading order and satisfies
+// the jsonapi.UnmarshalToManyRelations interface
+func (m *Series) SetToManyReferenceIDs(name string, IDs []string) error {
+	if name == "posts" {
+		m.PostIDs = []string{}
+		return m.AddToManyIDs(name, IDs)
+	}
+
+	return errors.New("There is no to-many relationship with the name " + name)
+}
+
+// AddToManyIDs appends posts to the end of the series and satisfies the
+// jsonapi.EditToManyRelations interface. Posts already in the series keep
+// their place.
+func (m *Series) AddToManyIDs(name string, IDs []string) error {
+	if name == "posts" {
+		for _, ID := range IDs {
+			if !containsID(m.PostIDs, ID) {
+				m.PostIDs = append(m.PostIDs, ID)
+			}
+		}
+
+		return nil
+	}
+
+	return errors.New("There is no to-many relationship with the name " + name)
+}
+
+// DeleteToManyIDs removes posts from the series and satisfies the
+// jsonapi.EditToManyRelations interface
+func (m *Series) DeleteToManyIDs(name string, IDs []string) error {
+	if name == "posts" {
+		postIDs := []string{}
+		for _, postID := range m.PostIDs {
+			if !containsID(IDs, postID) {
+				postIDs = append(postIDs, postID)
+			}
+		}
+
+		m.PostIDs = postIDs
+		return nil
+	}
+
+	return errors.New("There is no to-many relationship with the name " + name)
+}
+
+// Validate checks a series' title, returning one ValidationError per problem
+// found
+func (m Series) Validate() []ValidationError {
+	var errs []ValidationError
+
+	title := strings.TrimSpace(m.Title)
+	if len(title) == 0 {
+		errs = append(errs, ValidationError{"title", "is required"})
+	} else if utf8.RuneCountInString(title) > MaxSeriesTitleLength {
+		errs = append(errs, ValidationError{"title", fmt.Sprintf("must be at most %d characters", MaxSeriesTitleLength)})
+	}
+
+	return errs
+}
@@ -0,0 +1,23 @@
+package model
+
+import (
+	"strings"
+)
+
+// SettingTwoFactorRoles names the setting holding the comma separated roles
+// that must use two-factor authentication
+const SettingTwoFactorRoles = "two_factor_roles"
+
+// ParseList splits a comma separated list, dropping blanks
+func ParseList(value string) []string {
+	items := []string{}
+
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) > 0 {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
@@ -0,0 +1,66 @@
+package model
+
+import (
+	"fmt"
+	"github.com/manyminds/api2go/jsonapi"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxTagNameLength is the longest tag name the tags table holds
+const MaxTagNameLength = 100
+
+// Tag labels posts on the same subject. Its slug is made from its name and
+// names it in URLs and filters.
+type Tag struct {
+	ID int64 `json:"-"`
+
+	CreatedAt time.Time `json:"created-at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated-at" db:"updated_at"`
+	Name      string    `json:"name" db:"name"`
+	Slug      string    `json:"slug" db:"slug"`
+}
+
+func (m Tag) GetID() string {
+	return strconv.FormatInt(m.ID, 10)
+}
+
+func (m *Tag) SetID(id string) error {
+	var err error
+	m.ID, err = strconv.ParseInt(id, 10, 64)
+	return err
+}
+
+// GetReferences to satisfy the jsonapi.MarshalReferences interface
+func (m Tag) GetReferences() []jsonapi.Reference {
+	return []jsonapi.Reference{
+		{
+			Type:         "posts",
+			Name:         "posts",
+			Relationship: jsonapi.ToManyRelationship,
+			IsNotLoaded:  true,
+		},
+	}
+}
+
+// GetReferencedIDs to satisfy the jsonapi.MarshalLinkedRelations interface
+func (m Tag) GetReferencedIDs() []jsonapi.ReferenceID {
+	return []jsonapi.ReferenceID{}
+}
+
+// Validate checks a tag's name, returning one ValidationError per problem
+// found
+func (m Tag) Validate() []ValidationError {
+	var errs []ValidationError
+
+	name := strings.TrimSpace(m.Name)
+	if len(name) == 0 {
+		errs = append(errs, ValidationError{"name", "is required"})
+	} else if utf8.RuneCountInString(name) > MaxTagNameLength {
+		errs = append(errs, ValidationError{"name", fmt.Sprintf("must be at most %d characters", MaxTagNameLength)})
+	}
+
+	return errs
+}
@@ -0,0 +1,160 @@
+package model
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"github.com/manyminds/api2go/jsonapi"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// ScopePostsRead allows reading posts that are not public
+	ScopePostsRead = "posts:read"
+
+	// ScopePostsWrite allows creating, changing and deleting posts
+	ScopePostsWrite = "posts:write"
+
+	// ScopeUsersAdmin allows managing users, for admins only
+	ScopeUsersAdmin = "users:admin"
+)
+
+// TokenScopes is the set of scopes a personal access token may have
+var TokenScopes = map[string]bool{
+	ScopePostsRead:  true,
+	ScopePostsWrite: true,
+	ScopeUsersAdmin: true,
+}
+
+// Scopes is a list of token scopes, stored as a comma separated string
+type Scopes []string
+
+// Has reports whether the list contains a scope
+func (s Scopes) Has(scope string) bool {
+	for _, candidate := range s {
+		if candidate == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Scan satisfies the sql.Scanner interface
+func (s *Scopes) Scan(src interface{}) error {
+	var value string
+
+	switch v := src.(type) {
+	case []byte:
+		value = string(v)
+	case string:
+		value = v
+	case nil:
+		value = ""
+	default:
+		return fmt.Errorf("cannot scan %T into Scopes", src)
+	}
+
+	*s = Scopes(ParseList(value))
+	return nil
+}
+
+// Value satisfies the driver.Valuer interface
+func (s Scopes) Value() (driver.Value, error) {
+	return strings.Join(s, ","), nil
+}
+
+// Token is a personal access token that lets scripts use the API as a user,
+// limited to its scopes. Only the hash of the token is stored; the token
+// itself is only rendered once, when it is created.
+type Token struct {
+	ID int64 `json:"-"`
+
+	CreatedAt  time.Time  `json:"created-at" db:"created_at"`
+	UpdatedAt  time.Time  `json:"updated-at" db:"updated_at"`
+	Name       string     `json:"name" db:"name"`
+	Scopes     Scopes     `json:"scopes" db:"scopes"`
+	ExpiresAt  *time.Time `json:"expires-at" db:"expires_at"`
+	LastUsedAt *time.Time `json:"last-used-at" db:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked-at" db:"revoked_at"`
+	TokenHash  string     `json:"-" db:"token_hash"`
+	UserId     string     `json:"-" db:"user_id"`
+
+	// Token is the secret itself, only set in the response to creating it
+	Token string `json:"token,omitempty" db:"-"`
+}
+
+// IsUsable reports whether the token is neither revoked nor expired
+func (m Token) IsUsable(now time.Time) bool {
+	return m.RevokedAt == nil && (m.ExpiresAt == nil || m.ExpiresAt.After(now))
+}
+
+// Validate checks the submitted attributes, returning one ValidationError per
+// problem found
+func (m Token) Validate(now time.Time) []ValidationError {
+	var errs []ValidationError
+
+	if len(strings.TrimSpace(m.Name)) == 0 {
+		errs = append(errs, ValidationError{"name", "is required"})
+	}
+
+	if len(m.Scopes) == 0 {
+		errs = append(errs, ValidationError{"scopes", "must contain at least one scope"})
+	}
+
+	for _, scope := range m.Scopes {
+		if !TokenScopes[scope] {
+			errs = append(errs, ValidationError{"scopes", fmt.Sprintf("contains an unknown scope: %s", scope)})
+		}
+	}
+
+	if m.ExpiresAt != nil && !m.ExpiresAt.After(now) {
+		errs = append(errs, ValidationError{"expires-at", "must be in the future"})
+	}
+
+	return errs
+}
+
+func (m Token) GetID() string {
+	return strconv.FormatInt(m.ID, 10)
+}
+
+func (m *Token) SetID(id string) error {
+	var err error
+	m.ID, err = strconv.ParseInt(id, 10, 64)
+	return err
+}
+
+// GetReferences to satisfy the jsonapi.MarshalReferences interface
+func (m Token) GetReferences() []jsonapi.Reference {
+	return []jsonapi.Reference{
+		{
+			Type:         "users",
+			Name:         "user",
+			Relationship: jsonapi.ToOneRelationship,
+		},
+	}
+}
+
+// GetReferencedIDs to satisfy the jsonapi.MarshalLinkedRelations interface
+func (m Token) GetReferencedIDs() []jsonapi.ReferenceID {
+	return []jsonapi.ReferenceID{
+		{
+			ID:   m.UserId,
+			Type: "users",
+			Name: "user",
+		},
+	}
+}
+
+// SetToOneReferenceID satisfies the jsonapi.UnmarshalToOneRelations interface.
+// A token always belongs to the user who creates it, so the relationship
+// cannot be set by clients.
+func (m *Token) SetToOneReferenceID(name, ID string) error {
+	if name != "user" {
+		return fmt.Errorf("Token has no relationship called %s", name)
+	}
+
+	return nil
+}
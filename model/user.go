@@ -3,6 +3,7 @@ package model
 import (
 	"github.com/manyminds/api2go/jsonapi"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -11,18 +12,93 @@ type User struct {
 
 	CreatedAt    time.Time `json:"created-at" db:"created_at"`
 	UpdatedAt    time.Time `json:"updated-at" db:"updated_at"`
+	Version      int       `json:"version" db:"version" audit:"-"`
 	Username     string    `json:"username"`
-	Email        string    `json:"email"`
-	PasswordHash string    `json:"-" db:"password_hash"`
+	Email        string    `json:"email,omitempty"`
+	PasswordHash string    `json:"-" db:"password_hash" audit:"password,redact"`
+	Role         string    `json:"role" db:"role"`
+
+	// The public profile shown on the user's author page
+	DisplayName string      `json:"display-name" db:"display_name"`
+	Bio         string      `json:"bio" db:"bio"`
+	AvatarURL   string      `json:"avatar-url" db:"avatar_url"`
+	Website     string      `json:"website" db:"website"`
+	SocialLinks SocialLinks `json:"social-links" db:"social_links"`
+
+	// TOTPSecret is set once a user starts enrolling in two-factor
+	// authentication, and TOTPEnabledAt once they have confirmed a code
+	TOTPSecret    *string    `json:"-" db:"totp_secret" audit:"two-factor-secret,redact"`
+	TOTPEnabledAt *time.Time `json:"two-factor-enabled-at,omitempty" db:"totp_enabled_at"`
+	TOTPLastStep  int64      `json:"-" db:"totp_last_step"`
+
+	// FailedLogins counts failures since the last lockout or successful login,
+	// and Lockouts counts the lockouts since the last successful login.
+	// Clearing LockedUntil unlocks the account.
+	FailedLogins      int        `json:"-" db:"failed_logins"`
+	LastFailedLoginAt *time.Time `json:"-" db:"last_failed_login_at"`
+	Lockouts          int        `json:"-" db:"lockouts"`
+	LockedUntil       *time.Time `json:"locked-until,omitempty" db:"locked_until"`
+
+	// DeletedAt is set while the user is in the trash
+	DeletedAt *time.Time `json:"deleted-at,omitempty" db:"deleted_at"`
+
+	// Password and PasswordConfirmation are only ever accepted from clients;
+	// they are never persisted or rendered
+	Password             string `json:"password,omitempty" db:"-" audit:"-"`
+	PasswordConfirmation string `json:"password-confirmation,omitempty" db:"-" audit:"-"`
 }
 
+// NewUser holds the attributes submitted when creating a user or changing a
+// user's password, before the password has been hashed
 type NewUser struct {
 	Username             string `json:"username"`
 	Email                string `json:"email"`
+	Role                 string `json:"role"`
 	Password             string `json:"password"`
 	PasswordConfirmation string `json:"password-confirmation"`
 }
 
+// Validate checks the submitted attributes, returning one ValidationError per
+// problem found
+func (m NewUser) Validate() []ValidationError {
+	return append(m.ValidateAccount(), m.ValidatePassword()...)
+}
+
+// ValidateAccount checks the submitted username and email
+func (m NewUser) ValidateAccount() []ValidationError {
+	var errs []ValidationError
+
+	if len(strings.TrimSpace(m.Username)) == 0 {
+		errs = append(errs, ValidationError{"username", "is required"})
+	}
+
+	if !strings.Contains(m.Email, "@") {
+		errs = append(errs, ValidationError{"email", "must be a valid email address"})
+	}
+
+	if len(m.Role) > 0 && !Roles[m.Role] {
+		errs = append(errs, ValidationError{"role", "must be one of admin, editor, author or contributor"})
+	}
+
+	return errs
+}
+
+// NewUserFromUser collects the attributes a client submitted for a user
+func NewUserFromUser(user User) NewUser {
+	return NewUser{
+		Username:             user.Username,
+		Email:                user.Email,
+		Role:                 user.Role,
+		Password:             user.Password,
+		PasswordConfirmation: user.PasswordConfirmation,
+	}
+}
+
+// HasTwoFactor reports whether the user must give a TOTP code to log in
+func (m User) HasTwoFactor() bool {
+	return m.TOTPEnabledAt != nil && m.TOTPSecret != nil
+}
+
 func (m User) GetID() string {
 	return strconv.FormatInt(m.ID, 10)
 }
@@ -0,0 +1,16 @@
+package model
+
+import (
+	"fmt"
+)
+
+// ValidationError describes why a single attribute of a model is invalid. The
+// Attribute is the jsonapi field name so it can be used as an error source.
+type ValidationError struct {
+	Attribute string
+	Message   string
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("%s %s", e.Attribute, e.Message)
+}
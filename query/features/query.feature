@@ -35,3 +35,17 @@ Feature: build simple query
 		And I add the join "INNER JOIN state s2" on "s2.capitol = s2.largest_city"
 		And I compile the Query
 		Then the SQL should match "SELECT s.rivers, c.county_name FROM state s LEFT JOIN county c ON (c.state_name = s.name), INNER JOIN state s2 ON (s2.capitol = s2.largest_city) WHERE 1  ORDER BY id ASC LIMIT :limit,:offset"
+
+	Scenario: Build a query counting every matching row
+		When I create a new Query
+		And I select "d.cookies" from "d.desserts"
+		And I add the WHERE clause "d.nuts = :nuts"
+		And I compile the count Query
+		Then the SQL should match "SELECT COUNT(*) FROM d.desserts  WHERE 1 AND d.nuts = :nuts"
+
+	Scenario: Build a query selecting rows anywhere beneath a tree node
+		When I create a new Query
+		And I select "p.*" from "posts p"
+		And I limit "p.category_id" to the subtree of "categories" where "ancestors.id = :id"
+		And I compile the count Query
+		Then the SQL should match "SELECT COUNT(*) FROM posts p  WHERE 1 AND p.category_id IN (SELECT descendants.id FROM categories descendants JOIN categories ancestors ON descendants.path LIKE CONCAT(ancestors.path, '%') WHERE ancestors.id = :id)"
@@ -25,6 +25,39 @@ func (q *Query) Compile() (string, map[string]interface{}) {
 		selects = "*"
 	}
 
+	froms, joins, conds := q.compileConditions()
+
+	// Order by
+	orders := strings.Join(q.OrderBys, ", ")
+	if len(orders) == 0 {
+		orders = "id ASC"
+	}
+
+	// Output
+	sql := "SELECT %s FROM %s %s WHERE 1 %s ORDER BY %s LIMIT :offset, :limit"
+	return fmt.Sprintf(sql,
+		selects,
+		froms,
+		joins,
+		conds,
+		orders), q.Values
+}
+
+// CompileCount builds a query counting every row the compiled query would
+// select, ignoring the limit, for paginating
+func (q *Query) CompileCount() (string, map[string]interface{}) {
+	froms, joins, conds := q.compileConditions()
+
+	sql := "SELECT COUNT(*) FROM %s %s WHERE 1 %s"
+	return fmt.Sprintf(sql,
+		froms,
+		joins,
+		conds), q.Values
+}
+
+// compileConditions builds the FROM, JOIN and WHERE clauses shared by Compile
+// and CompileCount
+func (q *Query) compileConditions() (string, string, string) {
 	// From
 	froms := strings.Join(q.Froms, ", ")
 
@@ -41,20 +74,7 @@ func (q *Query) Compile() (string, map[string]interface{}) {
 		conds = fmt.Sprintf("AND %s", conds)
 	}
 
-	// Order by
-	orders := strings.Join(q.OrderBys, ", ")
-	if len(orders) == 0 {
-		orders = "id ASC"
-	}
-
-	// Output
-	sql := "SELECT %s FROM %s %s WHERE 1 %s ORDER BY %s LIMIT :offset, :limit"
-	return fmt.Sprintf(sql,
-		selects,
-		froms,
-		joins,
-		conds,
-		orders), q.Values
+	return froms, joins, conds
 }
 
 func (q *Query) Select(selection string) *Query {
@@ -72,6 +92,18 @@ func (q *Query) Where(where string) *Query {
 	return q
 }
 
+// WhereInSubtree limits a query to rows whose column holds the id of a node
+// in a tree table at or beneath any node matching cond, where cond refers to
+// those nodes as "ancestors". Nodes must store their materialized path, like
+// "/1/4/" for node 4 under node 1, in a path column.
+func (q *Query) WhereInSubtree(column, table, cond string) *Query {
+	return q.Where(fmt.Sprintf("%s IN (SELECT descendants.id FROM %s descendants JOIN %s ancestors ON descendants.path LIKE CONCAT(ancestors.path, '%%') WHERE %s)",
+		column,
+		table,
+		table,
+		cond))
+}
+
 func (q *Query) Join(join, on string) *Query {
 	if q.Joins == nil {
 		q.Joins = make(map[string]string)
@@ -37,6 +37,11 @@ func iCompileTheQuery() error {
 	return nil
 }
 
+func iCompileTheCountQuery() error {
+	sql, values = q.CompileCount()
+	return nil
+}
+
 func theSQLShouldMatch(expectedSql string) error {
 	if expectedSql == sql {
 		return nil
@@ -51,6 +56,11 @@ func iAddTheWHEREClause(where string) error {
 	return nil
 }
 
+func iAddTheSubtreeClause(column, table, cond string) error {
+	q.WhereInSubtree(column, table, cond)
+	return nil
+}
+
 func iAddTheJoinOn(join, on string) error {
 	q.Join(join, on)
 	return nil
@@ -61,8 +71,10 @@ func FeatureContext(s *godog.Suite) {
 	s.Step(`^I select "([^"]*)" from "([^"]*)"$`, iSelectFrom)
 	s.Step(`^I add the WHERE clause "([^"]*)"$`, iAddTheWHEREClause)
 	s.Step(`^I compile the Query$`, iCompileTheQuery)
+	s.Step(`^I compile the count Query$`, iCompileTheCountQuery)
 	s.Step(`^the SQL should match "([^"]*)"$`, theSQLShouldMatch)
 	s.Step(`^I select "([^"]*)"$`, iSelect)
 	s.Step(`^I add the FROM clause "([^"]*)"$`, iAddTheFROMClause)
 	s.Step(`^I add the join "([^"]*)" on "([^"]*)"$`, iAddTheJoinOn)
+	s.Step(`^I limit "([^"]*)" to the subtree of "([^"]*)" where "([^"]*)"$`, iAddTheSubtreeClause)
 }
@@ -0,0 +1,28 @@
+package resource
+
+import (
+	"github.com/manyminds/api2go"
+	"github.com/timrourke/timrourke.com/clientip"
+	"github.com/timrourke/timrourke.com/model"
+	"github.com/timrourke/timrourke.com/storage"
+)
+
+// newAudit returns the audit for a write, which records it inside the write's
+// own transaction so that a write that cannot be recorded is not made at all.
+// before is the resource as it was, or nil when it is being created, and
+// resourceID may be left empty until then too.
+func newAudit(auditEventStorage *storage.AuditEventStorage, r api2go.Request, actor *model.User, action, resourceType, resourceID string, before interface{}) storage.Audit {
+	event := model.AuditEvent{
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceId:   resourceID,
+		ClientIP:     clientip.FromRequest(r.PlainRequest),
+	}
+
+	if actor != nil {
+		actorID := actor.GetID()
+		event.ActorId = &actorID
+	}
+
+	return auditEventStorage.Record(event, before)
+}
@@ -0,0 +1,89 @@
+package resource
+
+import (
+	"errors"
+	"fmt"
+	"github.com/manyminds/api2go"
+	"github.com/timrourke/timrourke.com/auth"
+	"github.com/timrourke/timrourke.com/model"
+	"net/http"
+)
+
+// requireUser returns the authenticated user for a request, or a 401 error
+// when the request carries no valid access token. Users who must first enroll
+// in two-factor authentication get a 403 error.
+func requireUser(r api2go.Request) (*model.User, error) {
+	user, err := auth.RequireUser(r.Context)
+	if err == auth.ErrTwoFactorRequired {
+		return nil, newForbiddenError(err.Error())
+	} else if err != nil {
+		return nil, api2go.NewHTTPError(
+			err,
+			err.Error(),
+			http.StatusUnauthorized)
+	}
+
+	return user, nil
+}
+
+// requireScope returns the authenticated user for a request like requireUser,
+// and also a 403 error when they authenticated with a personal access token
+// that lacks the given scope
+func requireScope(r api2go.Request, scope string) (*model.User, error) {
+	user, err := requireUser(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if session, ok := auth.SessionFromContext(r.Context); ok && !session.Allows(scope) {
+		return nil, newForbiddenError(fmt.Sprintf("This token does not have the %s scope", scope))
+	}
+
+	return user, nil
+}
+
+// requireLogin returns the authenticated user for a request like requireUser,
+// and also a 403 error when they authenticated with a personal access token
+func requireLogin(r api2go.Request) (*model.User, error) {
+	user, err := requireUser(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if session, ok := auth.SessionFromContext(r.Context); ok && session.IsPersonalToken() {
+		return nil, newForbiddenError(auth.ErrLoginRequired.Error())
+	}
+
+	return user, nil
+}
+
+// requireAdmin returns a 401 or 403 error unless the request comes from an
+// admin. Personal access tokens also need the users:admin scope.
+func requireAdmin(r api2go.Request) error {
+	user, err := requireScope(r, model.ScopeUsersAdmin)
+	if err != nil {
+		return err
+	}
+
+	if !user.IsAdmin() {
+		return newForbiddenError("Only admins may do this")
+	}
+
+	return nil
+}
+
+// canReadUnpublished reports whether a request may read posts that are not
+// public, which takes logging in or a token with the posts:read scope
+func canReadUnpublished(r api2go.Request) bool {
+	session, ok := auth.SessionFromContext(r.Context)
+	return ok && session.Allows(model.ScopePostsRead)
+}
+
+// newForbiddenError builds a 403 error for an authenticated user who lacks
+// permission for an action
+func newForbiddenError(message string) api2go.HTTPError {
+	return api2go.NewHTTPError(
+		errors.New(message),
+		message,
+		http.StatusForbidden)
+}
@@ -0,0 +1,53 @@
+package resource
+
+import (
+	"errors"
+	"fmt"
+	"github.com/manyminds/api2go"
+	"github.com/timrourke/timrourke.com/model"
+	"net/http"
+	"strconv"
+)
+
+// newValidationError builds a 422 error holding one jsonapi error object per
+// invalid attribute
+func newValidationError(errs []model.ValidationError) api2go.HTTPError {
+	httpErr := api2go.NewHTTPError(
+		errors.New("Validation failed"),
+		"Unprocessable Entity",
+		http.StatusUnprocessableEntity)
+
+	for _, validationErr := range errs {
+		httpErr.Errors = append(httpErr.Errors, api2go.Error{
+			Status: strconv.Itoa(http.StatusUnprocessableEntity),
+			Title:  "Invalid Attribute",
+			Detail: validationErr.Error(),
+			Source: &api2go.ErrorSource{
+				Pointer: fmt.Sprintf("/data/attributes/%s", validationErr.Attribute),
+			},
+		})
+	}
+
+	return httpErr
+}
+
+// newRelationshipError builds a 422 error for an invalid relationship
+func newRelationshipError(relationship, detail string) api2go.HTTPError {
+	httpErr := api2go.NewHTTPError(
+		errors.New(detail),
+		"Unprocessable Entity",
+		http.StatusUnprocessableEntity)
+
+	httpErr.Errors = []api2go.Error{
+		{
+			Status: strconv.Itoa(http.StatusUnprocessableEntity),
+			Title:  "Invalid Relationship",
+			Detail: detail,
+			Source: &api2go.ErrorSource{
+				Pointer: fmt.Sprintf("/data/relationships/%s", relationship),
+			},
+		},
+	}
+
+	return httpErr
+}
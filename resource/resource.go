@@ -102,13 +102,16 @@ func handleFilters(params map[string][]string, filterableFields map[string]bool,
 			continue
 		}
 
+		// Convert dashes to underscores
+		columnName := strings.Replace(filterColumn, "-", "_", -1)
+
 		if strictEquals {
-			q.Where(fmt.Sprintf("%s = :%s", filterColumn, filterColumn))
+			q.Where(fmt.Sprintf("%s = :%s", columnName, columnName))
 		} else {
-			q.Where(fmt.Sprintf("%s LIKE \"%:%s%\"", filterColumn, filterColumn))
+			q.Where(fmt.Sprintf("%s LIKE CONCAT('%%', :%s, '%%')", columnName, columnName))
 		}
 
-		q.Bind(fmt.Sprintf("%s", filterColumn), filterValue[0])
+		q.Bind(columnName, filterValue[0])
 	}
 }
 
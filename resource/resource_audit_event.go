@@ -0,0 +1,122 @@
+package resource
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/manyminds/api2go"
+	"github.com/timrourke/timrourke.com/model"
+	"github.com/timrourke/timrourke.com/storage"
+	"net/http"
+	"strconv"
+)
+
+// AuditEventResource defines interface to storage layer. The audit log is
+// read-only, so it only implements finding audit events.
+type AuditEventResource struct {
+	AuditEventStorage *storage.AuditEventStorage
+}
+
+// AuditEventFilterableFields is a map of fields a user can sort or filter by,
+// where the key is the jsonapi field name and the value is whether a filter
+// should be performed using strict equality (true), or using a LIKE statement
+// (false), in the SQL generated for the query
+var AuditEventFilterableFields = map[string]bool{
+	"id":            true,
+	"created-at":    false,
+	"actor-id":      true,
+	"action":        true,
+	"resource-type": true,
+	"resource-id":   true,
+	"client-ip":     true,
+}
+
+// FindAll to satisfy api2go data source interface
+func (s AuditEventResource) FindAll(r api2go.Request) (api2go.Responder, error) {
+	_, result, err := s.findAll(r)
+	if err != nil {
+		return &Response{}, err
+	}
+
+	return &Response{Res: result}, nil
+}
+
+// PaginatedFindAll can be used to load audit events in chunks
+func (s AuditEventResource) PaginatedFindAll(r api2go.Request) (uint, api2go.Responder, error) {
+	count, result, err := s.findAll(r)
+	if err != nil {
+		return 0, &Response{}, err
+	}
+
+	return count, &Response{Res: result}, nil
+}
+
+// findAll selects audit events for admins
+func (s AuditEventResource) findAll(r api2go.Request) (uint, []model.AuditEvent, error) {
+	// 401, 403
+	if err := requireAdmin(r); err != nil {
+		return 0, nil, err
+	}
+
+	// 400
+	params, err := ParseQueryParams(r, AuditEventFilterableFields, map[string]RelationshipFunc{})
+	if err != nil {
+		return 0, nil, api2go.NewHTTPError(
+			err,
+			err.Error(),
+			http.StatusBadRequest,
+		)
+	}
+
+	// 500
+	count, result, err := s.AuditEventStorage.GetAll(params)
+	if err != nil {
+		return 0, nil, api2go.NewHTTPError(
+			err,
+			"Internal Server Error",
+			http.StatusInternalServerError)
+	}
+
+	return count, result, nil
+}
+
+// FindOne to satisfy `api2go.DataSource` interface
+// this method should return the audit event with the given ID, otherwise an
+// error
+func (s AuditEventResource) FindOne(id string, r api2go.Request) (api2go.Responder, error) {
+	// 401, 403
+	if err := requireAdmin(r); err != nil {
+		return &Response{}, err
+	}
+
+	// 400
+	_, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		errMessage := fmt.Sprintf("Audit event id must be integer: %s", id)
+
+		return &Response{}, api2go.NewHTTPError(
+			err,
+			errMessage,
+			http.StatusBadRequest)
+	}
+
+	// 404
+	event, err := s.AuditEventStorage.GetOne(id)
+	if err == sql.ErrNoRows {
+		errMessage := fmt.Sprintf("No audit event found with the id: %s", id)
+
+		return &Response{}, api2go.NewHTTPError(
+			err,
+			errMessage,
+			http.StatusNotFound,
+		)
+
+		// 500
+	} else if err != nil {
+		return &Response{}, api2go.NewHTTPError(
+			err,
+			"Internal Server Error",
+			http.StatusInternalServerError)
+	}
+
+	return &Response{Res: event}, nil
+}
@@ -0,0 +1,352 @@
+package resource
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/manyminds/api2go"
+	"github.com/timrourke/timrourke.com/model"
+	"github.com/timrourke/timrourke.com/query"
+	"github.com/timrourke/timrourke.com/slug"
+	"github.com/timrourke/timrourke.com/storage"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// CategoryResource defines interface to storage layer
+type CategoryResource struct {
+	CategoryStorage   *storage.CategoryStorage
+	AuditEventStorage *storage.AuditEventStorage
+}
+
+// CategoryFilterableFields is a map of fields a category can sort or filter
+// by, where the key is the jsonapi field name and the value is whether a
+// filter should be performed using strict equality (true), or using a LIKE
+// statement (false), in the SQL generated for the query. Sorting by path
+// lists categories in tree order.
+var CategoryFilterableFields = map[string]bool{
+	"id":         true,
+	"created-at": false,
+	"updated-at": false,
+	"name":       false,
+	"slug":       true,
+	"path":       true,
+}
+
+// Get a category's parent or children by the categoriesID query param, with
+// categoriesName saying which. Generally provided by api2go.
+func getCategoriesByCategoriesID(request api2go.Request, q *query.Query) *query.Query {
+	categoriesID, ok := request.QueryParams["categoriesID"]
+	if !ok {
+		return q
+	}
+
+	if name, ok := request.QueryParams["categoriesName"]; ok && name[0] == "parent" {
+		q.Where("categories.id IN (SELECT children.parent_id FROM categories children WHERE children.id = :categoriesID)")
+	} else {
+		q.Where("categories.parent_id = :categoriesID")
+	}
+
+	q.Bind("categoriesID", categoriesID[0])
+	return q
+}
+
+// Get the category of a post by the postsID query param. Generally provided by
+// api2go.
+func getCategoriesByPostsID(request api2go.Request, q *query.Query) *query.Query {
+	postsID, ok := request.QueryParams["postsID"]
+
+	if ok {
+		q.Where("categories.id IN (SELECT posts.category_id FROM posts WHERE posts.id = :postsID)")
+		q.Bind("postsID", postsID[0])
+	}
+
+	return q
+}
+
+// CategoryRelationships defines the functions for modifying a Query to select
+// the categories related to a category or post
+var CategoryRelationships = map[string]RelationshipFunc{
+	"categoriesID": getCategoriesByCategoriesID,
+	"postsID":      getCategoriesByPostsID,
+}
+
+// FindAll to satisfy api2go data source interface
+func (s CategoryResource) FindAll(r api2go.Request) (api2go.Responder, error) {
+	_, result, err := s.findAll(r)
+	if err != nil {
+		return &Response{}, err
+	}
+
+	return &Response{Res: result}, nil
+}
+
+// PaginatedFindAll can be used to load categories in chunks
+func (s CategoryResource) PaginatedFindAll(r api2go.Request) (uint, api2go.Responder, error) {
+	count, result, err := s.findAll(r)
+	if err != nil {
+		return 0, &Response{}, err
+	}
+
+	return count, &Response{Res: result}, nil
+}
+
+// findAll selects categories, which anyone may read
+func (s CategoryResource) findAll(r api2go.Request) (uint, []model.Category, error) {
+	// 400
+	params, err := ParseQueryParams(r, CategoryFilterableFields, CategoryRelationships)
+	if err != nil {
+		return 0, nil, api2go.NewHTTPError(
+			err,
+			err.Error(),
+			http.StatusBadRequest,
+		)
+	}
+
+	// 500
+	count, result, err := s.CategoryStorage.GetAll(params)
+	if err != nil {
+		return 0, nil, api2go.NewHTTPError(
+			err,
+			"Internal Server Error",
+			http.StatusInternalServerError)
+	}
+
+	return count, result, nil
+}
+
+// FindOne to satisfy `api2go.DataSource` interface
+// this method should return the category with the given ID, otherwise an error
+func (s CategoryResource) FindOne(id string, r api2go.Request) (api2go.Responder, error) {
+	category, err := s.findOne(id)
+	if err != nil {
+		return &Response{}, err
+	}
+
+	return &Response{Res: category}, nil
+}
+
+// Create method to satisfy `api2go.DataSource` interface. Categories shape the
+// whole site, so only those who may change anyone's posts may add them.
+func (s CategoryResource) Create(obj interface{}, r api2go.Request) (api2go.Responder, error) {
+	// 401, 403
+	currentUser, err := s.requireCategoryEditor(r)
+	if err != nil {
+		return &Response{}, err
+	}
+
+	// 400
+	category, ok := obj.(model.Category)
+	if !ok {
+		return &Response{}, api2go.NewHTTPError(
+			errors.New("Invalid instance given"),
+			"Invalid instance given",
+			http.StatusBadRequest)
+	}
+
+	// 422
+	if errs := category.Validate(); len(errs) > 0 {
+		return &Response{}, newValidationError(errs)
+	}
+
+	// 422
+	if err := s.checkParent(category.ParentId); err != nil {
+		return &Response{}, err
+	}
+
+	category.Name = strings.TrimSpace(category.Name)
+	category.Slug = slug.Make(category.Name)
+
+	// 422, 500
+	audit := newAudit(s.AuditEventStorage, r, currentUser, model.AuditActionCreate, "categories", "", nil)
+	newCategory, err := s.CategoryStorage.Insert(category, audit)
+	if err != nil {
+		return &Response{}, newCategoryStorageError(err)
+	}
+
+	return &Response{Res: newCategory, Code: http.StatusCreated}, nil
+}
+
+// Delete to satisfy `api2go.DataSource` interface. The category's posts and
+// children move up to its parent.
+func (s CategoryResource) Delete(id string, r api2go.Request) (api2go.Responder, error) {
+	// 401, 403
+	currentUser, err := s.requireCategoryEditor(r)
+	if err != nil {
+		return &Response{}, err
+	}
+
+	// 400, 404, 500
+	foundCategory, err := s.findOne(id)
+	if err != nil {
+		return &Response{}, err
+	}
+
+	audit := newAudit(s.AuditEventStorage, r, currentUser, model.AuditActionDelete, "categories", id, foundCategory)
+
+	err = s.CategoryStorage.Delete(id, audit)
+	if err != nil {
+		return &Response{Code: http.StatusInternalServerError}, err
+	}
+
+	return &Response{Code: http.StatusNoContent}, nil
+}
+
+// Update stores all changes on the category, moving it and its descendants
+// when it is given a new parent
+func (s CategoryResource) Update(obj interface{}, r api2go.Request) (api2go.Responder, error) {
+	// 401, 403
+	currentUser, err := s.requireCategoryEditor(r)
+	if err != nil {
+		return &Response{}, err
+	}
+
+	category, ok := obj.(*model.Category)
+
+	// 400
+	if !ok {
+		return &Response{}, api2go.NewHTTPError(
+			errors.New("Invalid instance given"),
+			"Invalid instance given",
+			http.StatusBadRequest)
+	}
+
+	// 400, 404, 500
+	id := category.GetID()
+	foundCategory, err := s.findOne(id)
+	if err != nil {
+		return &Response{}, err
+	}
+
+	// 422
+	if errs := category.Validate(); len(errs) > 0 {
+		return &Response{}, newValidationError(errs)
+	}
+
+	// 422
+	if err := s.checkParent(category.ParentId); err != nil {
+		return &Response{}, err
+	}
+
+	before := *foundCategory
+
+	// Update fields in category
+	foundCategory.Name = strings.TrimSpace(category.Name)
+	foundCategory.Slug = slug.Make(foundCategory.Name)
+	foundCategory.ParentId = category.ParentId
+
+	// 422, 500
+	audit := newAudit(s.AuditEventStorage, r, currentUser, model.AuditActionUpdate, "categories", id, before)
+	err = s.CategoryStorage.Update(foundCategory, audit)
+	if err != nil {
+		return &Response{}, newCategoryStorageError(err)
+	}
+
+	return &Response{Res: foundCategory, Code: http.StatusNoContent}, nil
+}
+
+// findOne loads a category, returning a 400, 404 or 500 error when it cannot
+func (s CategoryResource) findOne(id string) (*model.Category, error) {
+	// 400
+	_, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		errMessage := fmt.Sprintf("Category id must be integer: %s", id)
+
+		return nil, api2go.NewHTTPError(
+			err,
+			errMessage,
+			http.StatusBadRequest)
+	}
+
+	// 404
+	category, err := s.CategoryStorage.GetOne(id)
+	if err == sql.ErrNoRows {
+		errMessage := fmt.Sprintf("No category found with the id: %s", id)
+
+		return nil, api2go.NewHTTPError(
+			err,
+			errMessage,
+			http.StatusNotFound,
+		)
+
+		// 500
+	} else if err != nil {
+		return nil, api2go.NewHTTPError(
+			err,
+			"Internal Server Error",
+			http.StatusInternalServerError)
+	}
+
+	return category, nil
+}
+
+// checkParent returns a 422 error unless a parent id is nil or belongs to a
+// category
+func (s CategoryResource) checkParent(parentID *string) error {
+	return checkCategory(s.CategoryStorage, "parent", parentID)
+}
+
+// requireCategoryEditor returns the current user, or a 401 or 403 error unless
+// they may change anyone's posts
+func (s CategoryResource) requireCategoryEditor(r api2go.Request) (*model.User, error) {
+	currentUser, err := requireScope(r, model.ScopePostsWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	if !currentUser.CanEditOthersPosts() {
+		return nil, newForbiddenError("Only editors may change categories")
+	}
+
+	return currentUser, nil
+}
+
+// checkCategory returns a 422 error for the named relationship unless a
+// category id is nil or belongs to a category
+func checkCategory(categoryStorage *storage.CategoryStorage, relationship string, categoryID *string) error {
+	if categoryID == nil {
+		return nil
+	}
+
+	// 422
+	if _, err := strconv.ParseInt(*categoryID, 10, 64); err != nil {
+		return newRelationshipError(relationship, fmt.Sprintf("No category found with the id: %s", *categoryID))
+	}
+
+	_, err := categoryStorage.GetOne(*categoryID)
+	if err == sql.ErrNoRows {
+		return newRelationshipError(relationship, fmt.Sprintf("No category found with the id: %s", *categoryID))
+
+		// 500
+	} else if err != nil {
+		return api2go.NewHTTPError(
+			err,
+			"Internal Server Error",
+			http.StatusInternalServerError)
+	}
+
+	return nil
+}
+
+// newCategoryStorageError turns an error saving a category into a 422 error
+// for the problems a client can fix, or a 500 error
+func newCategoryStorageError(err error) error {
+	switch err {
+	case storage.ErrCategoryTaken:
+		return newValidationError([]model.ValidationError{
+			{Attribute: "name", Message: "is already taken"},
+		})
+	case storage.ErrCategoryCycle:
+		return newRelationshipError("parent", "A category cannot be moved beneath itself or its own descendants")
+	case storage.ErrCategoryTooDeep:
+		return newRelationshipError("parent", "Categories cannot be nested that deeply")
+	case sql.ErrNoRows:
+		return newRelationshipError("parent", "The parent category no longer exists")
+	}
+
+	return api2go.NewHTTPError(
+		err,
+		"Internal Server Error",
+		http.StatusInternalServerError)
+}
@@ -7,48 +7,184 @@ import (
 	"github.com/manyminds/api2go"
 	"github.com/timrourke/timrourke.com/model"
 	"github.com/timrourke/timrourke.com/query"
+	"github.com/timrourke/timrourke.com/sanitize"
 	"github.com/timrourke/timrourke.com/storage"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // PostResource defines interface to storage layer
 type PostResource struct {
-	PostStorage *storage.PostStorage
+	PostStorage       *storage.PostStorage
+	UserStorage       *storage.UserStorage
+	TagStorage        *storage.TagStorage
+	CategoryStorage   *storage.CategoryStorage
+	SeriesStorage     *storage.SeriesStorage
+	AuditEventStorage *storage.AuditEventStorage
+	Sanitizer         *sanitize.Policy
+	ExcerptLength     int
 }
 
+// maxTagFilters caps how many tags filter[tags] may name
+const maxTagFilters = 20
+
 // PostFilterableFields is a map of fields a post can sort or filter by, where
 // the key is the jsonapi field name and the value is whether a filter should
 // be performed using strict equality (true), or using a LIKE statement (false),
 // in the SQL generated for the query
 var PostFilterableFields = map[string]bool{
-	"id":         true,
-	"created-at": false,
-	"updated-at": false,
-	"permalink":  true,
+	"id":           true,
+	"created-at":   false,
+	"updated-at":   false,
+	"permalink":    true,
+	"status":       true,
+	"published-at": false,
+	"word-count":   true,
+	"reading-time": true,
 }
 
-// Get all posts by the usersID query param. Generally provided by api2go.
+// Get all posts a user wrote or co-wrote by the usersID query param. Generally
+// provided by api2go.
 func getPostsByUsersID(request api2go.Request, q *query.Query) *query.Query {
 	usersID, ok := request.QueryParams["usersID"]
 
 	if ok {
-		q.Where("posts.user_id = :usersID")
+		q.Where("(posts.user_id = :usersID OR posts.id IN (SELECT post_authors.post_id FROM post_authors WHERE post_authors.user_id = :usersID))")
 		q.Bind("usersID", usersID[0])
 	}
 
 	return q
 }
 
+// Get the post a revision belongs to by the post-revisionsID query param.
+// Generally provided by api2go.
+func getPostsByPostRevisionsID(request api2go.Request, q *query.Query) *query.Query {
+	postRevisionsID, ok := request.QueryParams["post-revisionsID"]
+
+	if ok {
+		q.Where("posts.id IN (SELECT post_revisions.post_id FROM post_revisions WHERE post_revisions.id = :postRevisionsID)")
+		q.Bind("postRevisionsID", postRevisionsID[0])
+	}
+
+	return q
+}
+
+// Get all posts with a tag by the tagsID query param. Generally provided by
+// api2go.
+func getPostsByTagsID(request api2go.Request, q *query.Query) *query.Query {
+	tagsID, ok := request.QueryParams["tagsID"]
+
+	if ok {
+		q.Where("posts.id IN (SELECT posts_tags.post_id FROM posts_tags WHERE posts_tags.tag_id = :tagsID)")
+		q.Bind("tagsID", tagsID[0])
+	}
+
+	return q
+}
+
+// Get all posts in a category or any of its descendants by the categoriesID
+// query param. Generally provided by api2go.
+func getPostsByCategoriesID(request api2go.Request, q *query.Query) *query.Query {
+	categoriesID, ok := request.QueryParams["categoriesID"]
+
+	if ok {
+		q.WhereInSubtree("posts.category_id", "categories", "ancestors.id = :categoriesID")
+		q.Bind("categoriesID", categoriesID[0])
+	}
+
+	return q
+}
+
+// Get all posts in a series, in reading order, by the seriesID query param.
+// Generally provided by api2go.
+func getPostsBySeriesID(request api2go.Request, q *query.Query) *query.Query {
+	seriesID, ok := request.QueryParams["seriesID"]
+
+	if ok {
+		q.Join("JOIN series_posts", "series_posts.post_id = posts.id")
+		q.Where("series_posts.series_id = :seriesID")
+		q.OrderBy("series_posts.position ASC")
+		q.Bind("seriesID", seriesID[0])
+	}
+
+	return q
+}
+
+// filterPostsByCategory limits a query to posts in the category named by the
+// filter[category] query param, a slug or id, or in any of its descendants
+func filterPostsByCategory(request api2go.Request, q *query.Query) *query.Query {
+	filter, ok := request.QueryParams["filter[category]"]
+	if !ok || len(strings.TrimSpace(filter[0])) == 0 {
+		return q
+	}
+
+	q.WhereInSubtree("posts.category_id", "categories", "(ancestors.slug = :categoryFilter OR CAST(ancestors.id AS CHAR) = :categoryFilter)")
+	q.Bind("categoryFilter", strings.TrimSpace(filter[0]))
+
+	return q
+}
+
+// filterPostsByAuthor limits a query to posts written or co-written by the
+// user named by the filter[author] query param, a username or id
+func filterPostsByAuthor(request api2go.Request, q *query.Query) *query.Query {
+	filter, ok := request.QueryParams["filter[author]"]
+	if !ok || len(strings.TrimSpace(filter[0])) == 0 {
+		return q
+	}
+
+	q.Where(`posts.id IN (SELECT posts_by_author.id FROM posts posts_by_author
+		LEFT JOIN post_authors ON post_authors.post_id = posts_by_author.id
+		JOIN users ON users.id = posts_by_author.user_id OR users.id = post_authors.user_id
+		WHERE users.username = :authorFilter OR CAST(users.id AS CHAR) = :authorFilter)`)
+	q.Bind("authorFilter", strings.TrimSpace(filter[0]))
+
+	return q
+}
+
+// filterPostsByTags limits a query to posts with any of the tags in the
+// filter[tags] query param, a comma separated list of tag slugs or ids
+func filterPostsByTags(request api2go.Request, q *query.Query) *query.Query {
+	filter, ok := request.QueryParams["filter[tags]"]
+	if !ok {
+		return q
+	}
+
+	tags := model.ParseList(filter[0])
+	if len(tags) == 0 {
+		return q
+	} else if len(tags) > maxTagFilters {
+		tags = tags[:maxTagFilters]
+	}
+
+	marks := make([]string, len(tags))
+	for i, tag := range tags {
+		marks[i] = fmt.Sprintf(":tagFilter%d", i)
+		q.Bind(fmt.Sprintf("tagFilter%d", i), tag)
+	}
+
+	in := strings.Join(marks, ", ")
+	q.Where(fmt.Sprintf(`posts.id IN (SELECT posts_tags.post_id FROM posts_tags
+		JOIN tags ON tags.id = posts_tags.tag_id
+		WHERE tags.slug IN (%s) OR CAST(tags.id AS CHAR) IN (%s))`, in, in))
+
+	return q
+}
+
 // PostRelationships defines the functions for modifying a Query to select...
 var PostRelationships = map[string]RelationshipFunc{
-	"usersID": getPostsByUsersID,
+	"usersID":          getPostsByUsersID,
+	"post-revisionsID": getPostsByPostRevisionsID,
+	"tagsID":           getPostsByTagsID,
+	"categoriesID":     getPostsByCategoriesID,
+	"seriesID":         getPostsBySeriesID,
 }
 
 // FindAll to satisfy api2go data source interface
 func (s PostResource) FindAll(r api2go.Request) (api2go.Responder, error) {
 	// 400
-	params, err := ParseQueryParams(r, PostFilterableFields, PostRelationships)
+	params, err := s.parseQueryParams(r)
 	if err != nil {
 		return &Response{}, api2go.NewHTTPError(
 			err,
@@ -72,7 +208,7 @@ func (s PostResource) FindAll(r api2go.Request) (api2go.Responder, error) {
 // PaginatedFindAll can be used to load posts in chunks
 func (s PostResource) PaginatedFindAll(r api2go.Request) (uint, api2go.Responder, error) {
 	// 400
-	params, err := ParseQueryParams(r, PostFilterableFields, PostRelationships)
+	params, err := s.parseQueryParams(r)
 	if err != nil {
 		return 0, &Response{}, api2go.NewHTTPError(
 			err,
@@ -93,6 +229,27 @@ func (s PostResource) PaginatedFindAll(r api2go.Request) (uint, api2go.Responder
 	return count, &Response{Res: result}, nil
 }
 
+// parseQueryParams parses the request for query params like ParseQueryParams
+// and filter[author], filter[category] and filter[tags], limiting the query
+// to published posts unless the request may read the rest
+func (s PostResource) parseQueryParams(r api2go.Request) (*query.Query, error) {
+	params, err := ParseQueryParams(r, PostFilterableFields, PostRelationships)
+	if err != nil {
+		return params, err
+	}
+
+	filterPostsByAuthor(r, params)
+	filterPostsByCategory(r, params)
+	filterPostsByTags(r, params)
+
+	if !canReadUnpublished(r) {
+		params.Where("posts.status = :publishedStatus AND posts.published_at <= UTC_TIMESTAMP()")
+		params.Bind("publishedStatus", model.PostStatusPublished)
+	}
+
+	return params, nil
+}
+
 // FindOne to satisfy `api2go.DataSource` interface
 // this method should return the post with the given ID, otherwise an error
 func (s PostResource) FindOne(id string, r api2go.Request) (api2go.Responder, error) {
@@ -109,7 +266,7 @@ func (s PostResource) FindOne(id string, r api2go.Request) (api2go.Responder, er
 
 	// 404
 	post, err := s.PostStorage.GetOne(id)
-	if err == sql.ErrNoRows {
+	if err == sql.ErrNoRows || (err == nil && !post.IsPublic(time.Now()) && !canReadUnpublished(r)) {
 		errMessage := fmt.Sprintf("No post found with the id: %s", id)
 
 		return &Response{}, api2go.NewHTTPError(
@@ -119,11 +276,37 @@ func (s PostResource) FindOne(id string, r api2go.Request) (api2go.Responder, er
 		)
 	}
 
-	return &Response{Res: post}, err
+	// 500
+	if err != nil {
+		return &Response{}, api2go.NewHTTPError(
+			err,
+			"Internal Server Error",
+			http.StatusInternalServerError)
+	}
+
+	// Updates must send back the version they read
+	if isUpdate(r) {
+		post.Version = 0
+		return &Response{Res: post}, nil
+	}
+
+	// 500
+	meta, err := seriesNavigation(s.SeriesStorage, post, r)
+	if err != nil {
+		return &Response{}, err
+	}
+
+	return &Response{Res: post, Meta: meta}, nil
 }
 
 // Create method to satisfy `api2go.DataSource` interface
 func (s PostResource) Create(obj interface{}, r api2go.Request) (api2go.Responder, error) {
+	// 401
+	currentUser, err := requireScope(r, model.ScopePostsWrite)
+	if err != nil {
+		return &Response{}, err
+	}
+
 	// 400
 	post, ok := obj.(model.Post)
 	if !ok {
@@ -133,22 +316,95 @@ func (s PostResource) Create(obj interface{}, r api2go.Request) (api2go.Responde
 			http.StatusBadRequest)
 	}
 
+	// Posts without a user relationship belong to whoever created them
+	if len(post.UserId) == 0 {
+		post.UserId = currentUser.GetID()
+	}
+
+	// 403, 422
+	if err := s.authorizeAuthor(currentUser, post.UserId); err != nil {
+		return &Response{}, err
+	}
+
+	// 422
+	if err := s.checkAuthors(post.AuthorIDs, model.Post{}); err != nil {
+		return &Response{}, err
+	}
+
+	// 422
+	if err := checkCategory(s.CategoryStorage, "category", post.CategoryId); err != nil {
+		return &Response{}, err
+	}
+
+	// 422
+	if err := s.checkTags(post.TagIDs); err != nil {
+		return &Response{}, err
+	}
+
+	// 422
+	if err := checkSeries(s.SeriesStorage, post.SeriesId); err != nil {
+		return &Response{}, err
+	}
+
+	// New posts are HTML unless they say otherwise
+	if len(post.ContentFormat) == 0 {
+		post.ContentFormat = model.PostContentFormatHTML
+	}
+
+	// 422
+	if validationErr := post.ValidateContentFormat(); validationErr != nil {
+		return &Response{}, newValidationError([]model.ValidationError{*validationErr})
+	}
+
+	post.DeriveContent()
+	post.Sanitize(s.Sanitizer)
+	post.GenerateExcerpt(s.ExcerptLength, nil)
+
+	// New posts are drafts unless they say otherwise
+	status := post.Status
+	if len(status) == 0 {
+		status = model.PostStatusDraft
+	}
+
+	// 403, 422
+	if err := transitionPost(currentUser, &post, "", status); err != nil {
+		return &Response{}, err
+	}
+
 	// 500
-	newPost, err := s.PostStorage.Insert(post)
-	if err != nil {
+	if err := s.assignPermalink(&post, post.Permalink); err != nil {
+		return &Response{}, err
+	}
+
+	audit := newAudit(s.AuditEventStorage, r, currentUser, model.AuditActionCreate, "posts", "", nil)
+
+	// 422
+	newPost, err := s.PostStorage.Insert(post, audit)
+	if err == storage.ErrPermalinkTaken {
+		return &Response{}, newPermalinkTakenError()
+
+		// 500
+	} else if err != nil {
 		return &Response{}, api2go.NewHTTPError(
 			errors.New("Internal Server Error"),
 			"Internal Server Error",
 			http.StatusInternalServerError)
 	}
 
-	return &Response{Res: newPost, Code: http.StatusCreated}, err
+	return &Response{Res: newPost, Code: http.StatusCreated}, nil
 }
 
-// Delete to satisfy `api2go.DataSource` interface
+// Delete to satisfy `api2go.DataSource` interface. The post goes to the trash,
+// from which it can be restored until the trash is purged.
 func (s PostResource) Delete(id string, r api2go.Request) (api2go.Responder, error) {
+	// 401
+	currentUser, err := requireScope(r, model.ScopePostsWrite)
+	if err != nil {
+		return &Response{}, err
+	}
+
 	// 400
-	_, err := strconv.ParseInt(id, 10, 64)
+	_, err = strconv.ParseInt(id, 10, 64)
 	if err != nil {
 		errMessage := fmt.Sprintf("Post id must be integer: %s", id)
 
@@ -158,15 +414,59 @@ func (s PostResource) Delete(id string, r api2go.Request) (api2go.Responder, err
 			http.StatusBadRequest)
 	}
 
-	err = s.PostStorage.Delete(id)
-	if err != nil {
+	// 404
+	foundPost, err := s.PostStorage.GetOne(id)
+	if err == sql.ErrNoRows {
+		errMessage := fmt.Sprintf("No post found with the id: %s", id)
+
+		return &Response{}, api2go.NewHTTPError(
+			err,
+			errMessage,
+			http.StatusNotFound,
+		)
+
+		// 500
+	} else if err != nil {
+		return &Response{}, api2go.NewHTTPError(
+			err,
+			"Internal Server Error",
+			http.StatusInternalServerError)
+	}
+
+	// 403
+	if !currentUser.CanDeletePost(*foundPost) {
+		return &Response{}, newForbiddenError("You may not delete this post")
+	}
+
+	audit := newAudit(s.AuditEventStorage, r, currentUser, model.AuditActionDelete, "posts", id, foundPost)
+
+	// 404 when another request moved the post to the trash first
+	err = s.PostStorage.Delete(id, audit)
+	if err == sql.ErrNoRows {
+		errMessage := fmt.Sprintf("No post found with the id: %s", id)
+
+		return &Response{}, api2go.NewHTTPError(
+			err,
+			errMessage,
+			http.StatusNotFound,
+		)
+
+		// 500
+	} else if err != nil {
 		return &Response{Code: http.StatusInternalServerError}, err
 	}
+
 	return &Response{Code: http.StatusNoContent}, nil
 }
 
 // Update stores all changes on the post
 func (s PostResource) Update(obj interface{}, r api2go.Request) (api2go.Responder, error) {
+	// 401
+	currentUser, err := requireScope(r, model.ScopePostsWrite)
+	if err != nil {
+		return &Response{}, err
+	}
+
 	post, ok := obj.(*model.Post)
 
 	// 400
@@ -198,21 +498,220 @@ func (s PostResource) Update(obj interface{}, r api2go.Request) (api2go.Responde
 			http.StatusInternalServerError)
 	}
 
+	// 403
+	if !currentUser.CanUpdatePost(*foundPost) {
+		return &Response{}, newForbiddenError("You may only change your own posts")
+	}
+
+	// 409, 412, 428
+	if err := checkVersion(r, "post", post.Version, foundPost.Version); err != nil {
+		return &Response{}, err
+	}
+
+	// 403, 422
+	if post.UserId != foundPost.UserId {
+		if err := s.authorizeAuthor(currentUser, post.UserId); err != nil {
+			return &Response{}, err
+		}
+	}
+
+	// 422
+	if validationErr := post.ValidateContentFormat(); validationErr != nil {
+		return &Response{}, newValidationError([]model.ValidationError{*validationErr})
+	}
+
+	// 422
+	if err := s.checkAuthors(post.AuthorIDs, *foundPost); err != nil {
+		return &Response{}, err
+	}
+
+	// 422
+	if err := checkCategory(s.CategoryStorage, "category", post.CategoryId); err != nil {
+		return &Response{}, err
+	}
+
+	// 422
+	if err := s.checkTags(post.TagIDs); err != nil {
+		return &Response{}, err
+	}
+
+	// 422
+	if err := checkSeries(s.SeriesStorage, post.SeriesId); err != nil {
+		return &Response{}, err
+	}
+
+	before := *foundPost
+
 	// Update fields in post
+	foundPost.UserId = post.UserId
+	foundPost.AuthorIDs = post.AuthorIDs
+	foundPost.CategoryId = post.CategoryId
+	foundPost.TagIDs = post.TagIDs
+	foundPost.SeriesId = post.SeriesId
 	foundPost.Title = post.Title
 	foundPost.Excerpt = post.Excerpt
 	foundPost.Content = post.Content
-	foundPost.Permalink = post.Permalink
-	// TODO: implement santization and validation
+	foundPost.ContentFormat = post.ContentFormat
+	foundPost.ContentDelta = post.ContentDelta
 
 	// 500
-	err = s.PostStorage.Update(foundPost)
-	if err != nil {
+	if post.Permalink != before.Permalink || len(post.Permalink) == 0 {
+		if err := s.assignPermalink(foundPost, post.Permalink); err != nil {
+			return &Response{}, err
+		}
+	}
+
+	// 403, 422
+	if post.Status != before.Status || !sameTime(post.PublishedAt, before.PublishedAt) {
+		foundPost.PublishedAt = post.PublishedAt
+		if err := transitionPost(currentUser, foundPost, before.Status, post.Status); err != nil {
+			return &Response{}, err
+		}
+	}
+
+	foundPost.DeriveContent()
+	foundPost.Sanitize(s.Sanitizer)
+	foundPost.GenerateExcerpt(s.ExcerptLength, &before)
+
+	audit := newAudit(s.AuditEventStorage, r, currentUser, model.AuditActionUpdate, "posts", id, before)
+
+	// 409
+	err = s.PostStorage.Update(foundPost, currentUser.GetID(), audit)
+	if err == storage.ErrStaleVersion {
+		latest, _ := s.PostStorage.GetOne(id)
+		return &Response{}, newVersionError(http.StatusConflict, "post", before.Version, latest.Version)
+
+		// 422
+	} else if err == storage.ErrPermalinkTaken {
+		return &Response{}, newPermalinkTakenError()
+
+		// 500
+	} else if err != nil {
 		return &Response{}, api2go.NewHTTPError(
 			err,
 			"Internal Server Error",
 			http.StatusInternalServerError)
 	}
 
-	return &Response{Res: foundPost, Code: http.StatusNoContent}, err
+	return &Response{Res: foundPost, Code: http.StatusNoContent}, nil
+}
+
+// authorizeAuthor checks that the current user may make authorID the author
+// of a post, and that authorID belongs to an existing user
+func (s PostResource) authorizeAuthor(currentUser *model.User, authorID string) error {
+	// 403
+	if authorID != currentUser.GetID() && !currentUser.CanEditOthersPosts() {
+		return newForbiddenError("You may only assign posts to yourself")
+	}
+
+	// 422
+	if _, err := strconv.ParseInt(authorID, 10, 64); err != nil {
+		return newRelationshipError("user", fmt.Sprintf("No user found with the id: %s", authorID))
+	}
+
+	_, err := s.UserStorage.GetOne(authorID)
+	if err == sql.ErrNoRows {
+		return newRelationshipError("user", fmt.Sprintf("No user found with the id: %s", authorID))
+
+		// 500
+	} else if err != nil {
+		return api2go.NewHTTPError(
+			err,
+			"Internal Server Error",
+			http.StatusInternalServerError)
+	}
+
+	return nil
+}
+
+// checkAuthors returns a 422 error unless every author id belongs to a user
+// who is not in the trash. Authors the post already had are not checked
+// again, since an owner in the trash stays on the byline.
+func (s PostResource) checkAuthors(authorIDs []string, previous model.Post) error {
+	added := []string{}
+	for _, authorID := range authorIDs {
+		if !previous.HasAuthor(authorID) {
+			added = append(added, authorID)
+		}
+	}
+
+	missing, err := s.UserStorage.Missing(added)
+
+	// 500
+	if err != nil {
+		return api2go.NewHTTPError(
+			err,
+			"Internal Server Error",
+			http.StatusInternalServerError)
+	}
+
+	// 422
+	if len(missing) > 0 {
+		return newRelationshipError("authors", fmt.Sprintf("No user found with the id: %s", missing[0]))
+	}
+
+	return nil
+}
+
+// checkTags returns a 422 error unless every tag id belongs to a tag
+func (s PostResource) checkTags(tagIDs []string) error {
+	missing, err := s.TagStorage.Missing(tagIDs)
+
+	// 500
+	if err != nil {
+		return api2go.NewHTTPError(
+			err,
+			"Internal Server Error",
+			http.StatusInternalServerError)
+	}
+
+	// 422
+	if len(missing) > 0 {
+		return newRelationshipError("tags", fmt.Sprintf("No tag found with the id: %s", missing[0]))
+	}
+
+	return nil
+}
+
+// assignPermalink gives a post a unique permalink made from the one requested,
+// or from its title when none was
+func (s PostResource) assignPermalink(post *model.Post, requested string) error {
+	if len(requested) == 0 {
+		requested = post.Title
+	}
+
+	permalink, err := s.PostStorage.UniquePermalink(requested, post.ID)
+	if err != nil {
+		return api2go.NewHTTPError(
+			err,
+			"Internal Server Error",
+			http.StatusInternalServerError)
+	}
+
+	post.Permalink = permalink
+	return nil
+}
+
+// newPermalinkTakenError builds a 422 error for a post saved with a permalink
+// another post took at the same time
+func newPermalinkTakenError() api2go.HTTPError {
+	return newValidationError([]model.ValidationError{
+		{Attribute: "permalink", Message: "is already taken"},
+	})
+}
+
+// transitionPost moves a post to a new status, checking that the current user
+// may do so
+func transitionPost(currentUser *model.User, post *model.Post, from, to string) error {
+	// 403
+	if to != from && !currentUser.CanPublishPost(to) {
+		return newForbiddenError(fmt.Sprintf("You may not change posts to %s", to))
+	}
+
+	// 422
+	if validationErr := post.Transition(from, to, time.Now()); validationErr != nil {
+		return newValidationError([]model.ValidationError{*validationErr})
+	}
+
+	return nil
 }
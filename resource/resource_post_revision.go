@@ -0,0 +1,139 @@
+package resource
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/manyminds/api2go"
+	"github.com/timrourke/timrourke.com/model"
+	"github.com/timrourke/timrourke.com/query"
+	"github.com/timrourke/timrourke.com/storage"
+	"net/http"
+	"strconv"
+)
+
+// PostRevisionResource defines interface to storage layer. Revisions are saved
+// whenever a post changes, so they can only be found here.
+type PostRevisionResource struct {
+	PostRevisionStorage *storage.PostRevisionStorage
+}
+
+// PostRevisionFilterableFields is a map of fields a post revision can sort or
+// filter by, where the key is the jsonapi field name and the value is whether
+// a filter should be performed using strict equality (true), or using a LIKE
+// statement (false), in the SQL generated for the query
+var PostRevisionFilterableFields = map[string]bool{
+	"id":         true,
+	"created-at": false,
+	"post-id":    true,
+	"editor-id":  true,
+}
+
+// Get all revisions of a post by the postsID query param. Generally provided
+// by api2go.
+func getPostRevisionsByPostsID(request api2go.Request, q *query.Query) *query.Query {
+	postsID, ok := request.QueryParams["postsID"]
+
+	if ok {
+		q.Where("post_revisions.post_id = :postsID")
+		q.Bind("postsID", postsID[0])
+	}
+
+	return q
+}
+
+// PostRevisionRelationships defines the functions for modifying a Query to
+// select the revisions of a post
+var PostRevisionRelationships = map[string]RelationshipFunc{
+	"postsID": getPostRevisionsByPostsID,
+}
+
+// FindAll to satisfy api2go data source interface
+func (s PostRevisionResource) FindAll(r api2go.Request) (api2go.Responder, error) {
+	_, result, err := s.findAll(r)
+	if err != nil {
+		return &Response{}, err
+	}
+
+	return &Response{Res: result}, nil
+}
+
+// PaginatedFindAll can be used to load post revisions in chunks
+func (s PostRevisionResource) PaginatedFindAll(r api2go.Request) (uint, api2go.Responder, error) {
+	count, result, err := s.findAll(r)
+	if err != nil {
+		return 0, &Response{}, err
+	}
+
+	return count, &Response{Res: result}, nil
+}
+
+// findAll selects post revisions for users who may read unpublished posts
+func (s PostRevisionResource) findAll(r api2go.Request) (uint, []model.PostRevision, error) {
+	// 401, 403
+	if _, err := requireScope(r, model.ScopePostsRead); err != nil {
+		return 0, nil, err
+	}
+
+	// 400
+	params, err := ParseQueryParams(r, PostRevisionFilterableFields, PostRevisionRelationships)
+	if err != nil {
+		return 0, nil, api2go.NewHTTPError(
+			err,
+			err.Error(),
+			http.StatusBadRequest,
+		)
+	}
+
+	// 500
+	count, result, err := s.PostRevisionStorage.GetAll(params)
+	if err != nil {
+		return 0, nil, api2go.NewHTTPError(
+			err,
+			"Internal Server Error",
+			http.StatusInternalServerError)
+	}
+
+	return count, result, nil
+}
+
+// FindOne to satisfy `api2go.DataSource` interface
+// this method should return the post revision with the given ID, otherwise an
+// error
+func (s PostRevisionResource) FindOne(id string, r api2go.Request) (api2go.Responder, error) {
+	// 401, 403
+	if _, err := requireScope(r, model.ScopePostsRead); err != nil {
+		return &Response{}, err
+	}
+
+	// 400
+	_, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		errMessage := fmt.Sprintf("Post revision id must be integer: %s", id)
+
+		return &Response{}, api2go.NewHTTPError(
+			err,
+			errMessage,
+			http.StatusBadRequest)
+	}
+
+	// 404
+	revision, err := s.PostRevisionStorage.GetOne(id)
+	if err == sql.ErrNoRows {
+		errMessage := fmt.Sprintf("No post revision found with the id: %s", id)
+
+		return &Response{}, api2go.NewHTTPError(
+			err,
+			errMessage,
+			http.StatusNotFound,
+		)
+
+		// 500
+	} else if err != nil {
+		return &Response{}, api2go.NewHTTPError(
+			err,
+			"Internal Server Error",
+			http.StatusInternalServerError)
+	}
+
+	return &Response{Res: revision}, nil
+}
@@ -0,0 +1,392 @@
+package resource
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/manyminds/api2go"
+	"github.com/manyminds/api2go/jsonapi"
+	"github.com/timrourke/timrourke.com/model"
+	"github.com/timrourke/timrourke.com/query"
+	"github.com/timrourke/timrourke.com/slug"
+	"github.com/timrourke/timrourke.com/storage"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// SeriesResource defines interface to storage layer
+type SeriesResource struct {
+	SeriesStorage     *storage.SeriesStorage
+	PostStorage       *storage.PostStorage
+	AuditEventStorage *storage.AuditEventStorage
+}
+
+// SeriesFilterableFields is a map of fields a series can sort or filter by,
+// where the key is the jsonapi field name and the value is whether a filter
+// should be performed using strict equality (true), or using a LIKE statement
+// (false), in the SQL generated for the query
+var SeriesFilterableFields = map[string]bool{
+	"id":         true,
+	"created-at": false,
+	"updated-at": false,
+	"title":      false,
+	"slug":       true,
+}
+
+// Get the series a post is in by the postsID query param. Generally provided
+// by api2go.
+func getSeriesByPostsID(request api2go.Request, q *query.Query) *query.Query {
+	postsID, ok := request.QueryParams["postsID"]
+
+	if ok {
+		q.Where("series.id IN (SELECT series_posts.series_id FROM series_posts WHERE series_posts.post_id = :postsID)")
+		q.Bind("postsID", postsID[0])
+	}
+
+	return q
+}
+
+// SeriesRelationships defines the functions for modifying a Query to select
+// the series of a post
+var SeriesRelationships = map[string]RelationshipFunc{
+	"postsID": getSeriesByPostsID,
+}
+
+// FindAll to satisfy api2go data source interface
+func (s SeriesResource) FindAll(r api2go.Request) (api2go.Responder, error) {
+	_, result, err := s.findAll(r)
+	if err != nil {
+		return &Response{}, err
+	}
+
+	return &Response{Res: result}, nil
+}
+
+// PaginatedFindAll can be used to load series in chunks
+func (s SeriesResource) PaginatedFindAll(r api2go.Request) (uint, api2go.Responder, error) {
+	count, result, err := s.findAll(r)
+	if err != nil {
+		return 0, &Response{}, err
+	}
+
+	return count, &Response{Res: result}, nil
+}
+
+// findAll selects series, which anyone may read, listing only the posts the
+// request may read
+func (s SeriesResource) findAll(r api2go.Request) (uint, []model.Series, error) {
+	// 400
+	params, err := ParseQueryParams(r, SeriesFilterableFields, SeriesRelationships)
+	if err != nil {
+		return 0, nil, api2go.NewHTTPError(
+			err,
+			err.Error(),
+			http.StatusBadRequest,
+		)
+	}
+
+	// 500
+	count, result, err := s.SeriesStorage.GetAll(params, !canReadUnpublished(r))
+	if err != nil {
+		return 0, nil, api2go.NewHTTPError(
+			err,
+			"Internal Server Error",
+			http.StatusInternalServerError)
+	}
+
+	return count, result, nil
+}
+
+// FindOne to satisfy `api2go.DataSource` interface
+// this method should return the series with the given ID, otherwise an error
+func (s SeriesResource) FindOne(id string, r api2go.Request) (api2go.Responder, error) {
+	series, err := s.findOne(id, !canReadUnpublished(r))
+	if err != nil {
+		return &Response{}, err
+	}
+
+	return &Response{Res: series}, nil
+}
+
+// Create method to satisfy `api2go.DataSource` interface. Series put posts by
+// different authors in order, so only those who may change anyone's posts may
+// add them.
+func (s SeriesResource) Create(obj interface{}, r api2go.Request) (api2go.Responder, error) {
+	// 401, 403
+	currentUser, err := s.requireSeriesEditor(r)
+	if err != nil {
+		return &Response{}, err
+	}
+
+	// 400
+	series, ok := obj.(model.Series)
+	if !ok {
+		return &Response{}, api2go.NewHTTPError(
+			errors.New("Invalid instance given"),
+			"Invalid instance given",
+			http.StatusBadRequest)
+	}
+
+	// 422
+	if errs := series.Validate(); len(errs) > 0 {
+		return &Response{}, newValidationError(errs)
+	}
+
+	// 422
+	if err := s.checkPosts(series.PostIDs); err != nil {
+		return &Response{}, err
+	}
+
+	series.Title = strings.TrimSpace(series.Title)
+	series.Slug = slug.Make(series.Title)
+
+	audit := newAudit(s.AuditEventStorage, r, currentUser, model.AuditActionCreate, "series", "", nil)
+
+	// 422
+	newSeries, err := s.SeriesStorage.Insert(series, audit)
+	if err == storage.ErrSeriesTaken {
+		return &Response{}, newSeriesTakenError()
+
+		// 500
+	} else if err != nil {
+		return &Response{}, api2go.NewHTTPError(
+			err,
+			"Internal Server Error",
+			http.StatusInternalServerError)
+	}
+
+	return &Response{Res: newSeries, Code: http.StatusCreated}, nil
+}
+
+// Delete to satisfy `api2go.DataSource` interface. The series' posts are kept.
+func (s SeriesResource) Delete(id string, r api2go.Request) (api2go.Responder, error) {
+	// 401, 403
+	currentUser, err := s.requireSeriesEditor(r)
+	if err != nil {
+		return &Response{}, err
+	}
+
+	// 400, 404, 500
+	foundSeries, err := s.findOne(id, false)
+	if err != nil {
+		return &Response{}, err
+	}
+
+	audit := newAudit(s.AuditEventStorage, r, currentUser, model.AuditActionDelete, "series", id, foundSeries)
+
+	err = s.SeriesStorage.Delete(id, audit)
+	if err != nil {
+		return &Response{Code: http.StatusInternalServerError}, err
+	}
+
+	return &Response{Code: http.StatusNoContent}, nil
+}
+
+// Update stores all changes on the series, replacing the order of its posts
+// with the one sent
+func (s SeriesResource) Update(obj interface{}, r api2go.Request) (api2go.Responder, error) {
+	// 401, 403
+	currentUser, err := s.requireSeriesEditor(r)
+	if err != nil {
+		return &Response{}, err
+	}
+
+	series, ok := obj.(*model.Series)
+
+	// 400
+	if !ok {
+		return &Response{}, api2go.NewHTTPError(
+			errors.New("Invalid instance given"),
+			"Invalid instance given",
+			http.StatusBadRequest)
+	}
+
+	// 400, 404, 500
+	id := series.GetID()
+	foundSeries, err := s.findOne(id, false)
+	if err != nil {
+		return &Response{}, err
+	}
+
+	// 422
+	if errs := series.Validate(); len(errs) > 0 {
+		return &Response{}, newValidationError(errs)
+	}
+
+	// 422
+	if err := s.checkPosts(series.PostIDs); err != nil {
+		return &Response{}, err
+	}
+
+	before := *foundSeries
+
+	// Update fields in series
+	foundSeries.Title = strings.TrimSpace(series.Title)
+	foundSeries.Slug = slug.Make(foundSeries.Title)
+	foundSeries.Description = series.Description
+	foundSeries.PostIDs = series.PostIDs
+
+	audit := newAudit(s.AuditEventStorage, r, currentUser, model.AuditActionUpdate, "series", id, before)
+
+	// 422
+	err = s.SeriesStorage.Update(foundSeries, audit)
+	if err == storage.ErrSeriesTaken {
+		return &Response{}, newSeriesTakenError()
+
+		// 500
+	} else if err != nil {
+		return &Response{}, api2go.NewHTTPError(
+			err,
+			"Internal Server Error",
+			http.StatusInternalServerError)
+	}
+
+	return &Response{Res: foundSeries, Code: http.StatusNoContent}, nil
+}
+
+// findOne loads a series, returning a 400, 404 or 500 error when it cannot
+func (s SeriesResource) findOne(id string, publicOnly bool) (*model.Series, error) {
+	// 400
+	_, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		errMessage := fmt.Sprintf("Series id must be integer: %s", id)
+
+		return nil, api2go.NewHTTPError(
+			err,
+			errMessage,
+			http.StatusBadRequest)
+	}
+
+	// 404
+	series, err := s.SeriesStorage.GetOne(id, publicOnly)
+	if err == sql.ErrNoRows {
+		errMessage := fmt.Sprintf("No series found with the id: %s", id)
+
+		return nil, api2go.NewHTTPError(
+			err,
+			errMessage,
+			http.StatusNotFound,
+		)
+
+		// 500
+	} else if err != nil {
+		return nil, api2go.NewHTTPError(
+			err,
+			"Internal Server Error",
+			http.StatusInternalServerError)
+	}
+
+	return series, nil
+}
+
+// checkPosts returns a 422 error unless every post id belongs to a post
+func (s SeriesResource) checkPosts(postIDs []string) error {
+	missing, err := s.PostStorage.Missing(postIDs)
+
+	// 500
+	if err != nil {
+		return api2go.NewHTTPError(
+			err,
+			"Internal Server Error",
+			http.StatusInternalServerError)
+	}
+
+	// 422
+	if len(missing) > 0 {
+		return newRelationshipError("posts", fmt.Sprintf("No post found with the id: %s", missing[0]))
+	}
+
+	return nil
+}
+
+// requireSeriesEditor returns the current user, or a 401 or 403 error unless
+// they may change anyone's posts
+func (s SeriesResource) requireSeriesEditor(r api2go.Request) (*model.User, error) {
+	currentUser, err := requireScope(r, model.ScopePostsWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	if !currentUser.CanEditOthersPosts() {
+		return nil, newForbiddenError("Only editors may change series")
+	}
+
+	return currentUser, nil
+}
+
+// checkSeries returns a 422 error unless a series id is nil or belongs to a
+// series
+func checkSeries(seriesStorage *storage.SeriesStorage, seriesID *string) error {
+	if seriesID == nil {
+		return nil
+	}
+
+	// 422
+	if _, err := strconv.ParseInt(*seriesID, 10, 64); err != nil {
+		return newRelationshipError("series", fmt.Sprintf("No series found with the id: %s", *seriesID))
+	}
+
+	_, err := seriesStorage.GetOne(*seriesID, false)
+	if err == sql.ErrNoRows {
+		return newRelationshipError("series", fmt.Sprintf("No series found with the id: %s", *seriesID))
+
+		// 500
+	} else if err != nil {
+		return api2go.NewHTTPError(
+			err,
+			"Internal Server Error",
+			http.StatusInternalServerError)
+	}
+
+	return nil
+}
+
+// seriesNavigation builds the meta linking a post to the posts before and
+// after it in its series, skipping posts the request may not read. Posts in
+// no series have none.
+func seriesNavigation(seriesStorage *storage.SeriesStorage, post *model.Post, r api2go.Request) (map[string]interface{}, error) {
+	if post.SeriesId == nil {
+		return nil, nil
+	}
+
+	// 500
+	previous, next, err := seriesStorage.GetNeighbours(post.GetID(), !canReadUnpublished(r))
+	if err != nil {
+		return nil, api2go.NewHTTPError(
+			err,
+			"Internal Server Error",
+			http.StatusInternalServerError)
+	}
+
+	return map[string]interface{}{
+		"series": map[string]interface{}{
+			"id":       *post.SeriesId,
+			"previous": seriesLink(previous),
+			"next":     seriesLink(next),
+		},
+	}, nil
+}
+
+// seriesLink links to a post in a series, or is nil when there is no post
+func seriesLink(post *model.Post) interface{} {
+	if post == nil {
+		return nil
+	}
+
+	return jsonapi.Link{
+		Href: "/api/posts/" + post.GetID(),
+		Meta: jsonapi.Meta{
+			"title":     post.Title,
+			"permalink": post.Permalink,
+		},
+	}
+}
+
+// newSeriesTakenError builds a 422 error for a series titled like another
+// series
+func newSeriesTakenError() api2go.HTTPError {
+	return newValidationError([]model.ValidationError{
+		{Attribute: "title", Message: "is already taken"},
+	})
+}
@@ -0,0 +1,288 @@
+package resource
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/manyminds/api2go"
+	"github.com/timrourke/timrourke.com/model"
+	"github.com/timrourke/timrourke.com/query"
+	"github.com/timrourke/timrourke.com/slug"
+	"github.com/timrourke/timrourke.com/storage"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// TagResource defines interface to storage layer
+type TagResource struct {
+	TagStorage        *storage.TagStorage
+	AuditEventStorage *storage.AuditEventStorage
+}
+
+// TagFilterableFields is a map of fields a tag can sort or filter by, where
+// the key is the jsonapi field name and the value is whether a filter should
+// be performed using strict equality (true), or using a LIKE statement (false),
+// in the SQL generated for the query
+var TagFilterableFields = map[string]bool{
+	"id":         true,
+	"created-at": false,
+	"updated-at": false,
+	"name":       false,
+	"slug":       true,
+}
+
+// Get all tags on a post by the postsID query param. Generally provided by
+// api2go.
+func getTagsByPostsID(request api2go.Request, q *query.Query) *query.Query {
+	postsID, ok := request.QueryParams["postsID"]
+
+	if ok {
+		q.Where("tags.id IN (SELECT posts_tags.tag_id FROM posts_tags WHERE posts_tags.post_id = :postsID)")
+		q.Bind("postsID", postsID[0])
+	}
+
+	return q
+}
+
+// TagRelationships defines the functions for modifying a Query to select the
+// tags of a post
+var TagRelationships = map[string]RelationshipFunc{
+	"postsID": getTagsByPostsID,
+}
+
+// FindAll to satisfy api2go data source interface
+func (s TagResource) FindAll(r api2go.Request) (api2go.Responder, error) {
+	_, result, err := s.findAll(r)
+	if err != nil {
+		return &Response{}, err
+	}
+
+	return &Response{Res: result}, nil
+}
+
+// PaginatedFindAll can be used to load tags in chunks
+func (s TagResource) PaginatedFindAll(r api2go.Request) (uint, api2go.Responder, error) {
+	count, result, err := s.findAll(r)
+	if err != nil {
+		return 0, &Response{}, err
+	}
+
+	return count, &Response{Res: result}, nil
+}
+
+// findAll selects tags, which anyone may read
+func (s TagResource) findAll(r api2go.Request) (uint, []model.Tag, error) {
+	// 400
+	params, err := ParseQueryParams(r, TagFilterableFields, TagRelationships)
+	if err != nil {
+		return 0, nil, api2go.NewHTTPError(
+			err,
+			err.Error(),
+			http.StatusBadRequest,
+		)
+	}
+
+	// 500
+	count, result, err := s.TagStorage.GetAll(params)
+	if err != nil {
+		return 0, nil, api2go.NewHTTPError(
+			err,
+			"Internal Server Error",
+			http.StatusInternalServerError)
+	}
+
+	return count, result, nil
+}
+
+// FindOne to satisfy `api2go.DataSource` interface
+// this method should return the tag with the given ID, otherwise an error
+func (s TagResource) FindOne(id string, r api2go.Request) (api2go.Responder, error) {
+	tag, err := s.findOne(id)
+	if err != nil {
+		return &Response{}, err
+	}
+
+	return &Response{Res: tag}, nil
+}
+
+// Create method to satisfy `api2go.DataSource` interface. Anyone who may write
+// posts may add tags to choose from.
+func (s TagResource) Create(obj interface{}, r api2go.Request) (api2go.Responder, error) {
+	// 401, 403
+	currentUser, err := requireScope(r, model.ScopePostsWrite)
+	if err != nil {
+		return &Response{}, err
+	}
+
+	// 400
+	tag, ok := obj.(model.Tag)
+	if !ok {
+		return &Response{}, api2go.NewHTTPError(
+			errors.New("Invalid instance given"),
+			"Invalid instance given",
+			http.StatusBadRequest)
+	}
+
+	// 422
+	if errs := tag.Validate(); len(errs) > 0 {
+		return &Response{}, newValidationError(errs)
+	}
+
+	tag.Name = strings.TrimSpace(tag.Name)
+	tag.Slug = slug.Make(tag.Name)
+
+	audit := newAudit(s.AuditEventStorage, r, currentUser, model.AuditActionCreate, "tags", "", nil)
+
+	// 422
+	newTag, err := s.TagStorage.Insert(tag, audit)
+	if err == storage.ErrTagTaken {
+		return &Response{}, newTagTakenError()
+
+		// 500
+	} else if err != nil {
+		return &Response{}, api2go.NewHTTPError(
+			err,
+			"Internal Server Error",
+			http.StatusInternalServerError)
+	}
+
+	return &Response{Res: newTag, Code: http.StatusCreated}, nil
+}
+
+// Delete to satisfy `api2go.DataSource` interface. Deleting a tag removes it
+// from every post, so only those who may change anyone's posts may do it.
+func (s TagResource) Delete(id string, r api2go.Request) (api2go.Responder, error) {
+	// 401, 403
+	currentUser, err := s.requireTagEditor(r)
+	if err != nil {
+		return &Response{}, err
+	}
+
+	// 400, 404, 500
+	foundTag, err := s.findOne(id)
+	if err != nil {
+		return &Response{}, err
+	}
+
+	audit := newAudit(s.AuditEventStorage, r, currentUser, model.AuditActionDelete, "tags", id, foundTag)
+
+	err = s.TagStorage.Delete(id, audit)
+	if err != nil {
+		return &Response{Code: http.StatusInternalServerError}, err
+	}
+
+	return &Response{Code: http.StatusNoContent}, nil
+}
+
+// Update stores all changes on the tag. Renaming a tag renames it on every
+// post, so only those who may change anyone's posts may do it.
+func (s TagResource) Update(obj interface{}, r api2go.Request) (api2go.Responder, error) {
+	// 401, 403
+	currentUser, err := s.requireTagEditor(r)
+	if err != nil {
+		return &Response{}, err
+	}
+
+	tag, ok := obj.(*model.Tag)
+
+	// 400
+	if !ok {
+		return &Response{}, api2go.NewHTTPError(
+			errors.New("Invalid instance given"),
+			"Invalid instance given",
+			http.StatusBadRequest)
+	}
+
+	// 400, 404, 500
+	id := tag.GetID()
+	foundTag, err := s.findOne(id)
+	if err != nil {
+		return &Response{}, err
+	}
+
+	// 422
+	if errs := tag.Validate(); len(errs) > 0 {
+		return &Response{}, newValidationError(errs)
+	}
+
+	before := *foundTag
+
+	// Update fields in tag
+	foundTag.Name = strings.TrimSpace(tag.Name)
+	foundTag.Slug = slug.Make(foundTag.Name)
+
+	audit := newAudit(s.AuditEventStorage, r, currentUser, model.AuditActionUpdate, "tags", id, before)
+
+	// 422
+	err = s.TagStorage.Update(foundTag, audit)
+	if err == storage.ErrTagTaken {
+		return &Response{}, newTagTakenError()
+
+		// 500
+	} else if err != nil {
+		return &Response{}, api2go.NewHTTPError(
+			err,
+			"Internal Server Error",
+			http.StatusInternalServerError)
+	}
+
+	return &Response{Res: foundTag, Code: http.StatusNoContent}, nil
+}
+
+// findOne loads a tag, returning a 400, 404 or 500 error when it cannot
+func (s TagResource) findOne(id string) (*model.Tag, error) {
+	// 400
+	_, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		errMessage := fmt.Sprintf("Tag id must be integer: %s", id)
+
+		return nil, api2go.NewHTTPError(
+			err,
+			errMessage,
+			http.StatusBadRequest)
+	}
+
+	// 404
+	tag, err := s.TagStorage.GetOne(id)
+	if err == sql.ErrNoRows {
+		errMessage := fmt.Sprintf("No tag found with the id: %s", id)
+
+		return nil, api2go.NewHTTPError(
+			err,
+			errMessage,
+			http.StatusNotFound,
+		)
+
+		// 500
+	} else if err != nil {
+		return nil, api2go.NewHTTPError(
+			err,
+			"Internal Server Error",
+			http.StatusInternalServerError)
+	}
+
+	return tag, nil
+}
+
+// requireTagEditor returns the current user, or a 401 or 403 error unless they
+// may change anyone's posts
+func (s TagResource) requireTagEditor(r api2go.Request) (*model.User, error) {
+	currentUser, err := requireScope(r, model.ScopePostsWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	if !currentUser.CanEditOthersPosts() {
+		return nil, newForbiddenError("Only editors may change or delete tags")
+	}
+
+	return currentUser, nil
+}
+
+// newTagTakenError builds a 422 error for a tag named like another tag
+func newTagTakenError() api2go.HTTPError {
+	return newValidationError([]model.ValidationError{
+		{Attribute: "name", Message: "is already taken"},
+	})
+}
@@ -0,0 +1,219 @@
+package resource
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/manyminds/api2go"
+	"github.com/timrourke/timrourke.com/auth"
+	"github.com/timrourke/timrourke.com/model"
+	"github.com/timrourke/timrourke.com/storage"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// TokenResource defines interface to storage layer
+type TokenResource struct {
+	TokenStorage *storage.TokenStorage
+}
+
+// TokenFilterableFields is a map of fields a user can sort or filter by, where
+// the key is the jsonapi field name and the value is whether a filter should
+// be performed using strict equality (true), or using a LIKE statement (false),
+// in the SQL generated for the query
+var TokenFilterableFields = map[string]bool{
+	"id":           true,
+	"created-at":   false,
+	"updated-at":   false,
+	"name":         false,
+	"expires-at":   false,
+	"last-used-at": false,
+	"revoked-at":   false,
+}
+
+// FindAll to satisfy api2go data source interface. Users only ever see their
+// own tokens.
+func (s TokenResource) FindAll(r api2go.Request) (api2go.Responder, error) {
+	_, result, err := s.findAll(r)
+	if err != nil {
+		return &Response{}, err
+	}
+
+	return &Response{Res: result}, nil
+}
+
+// PaginatedFindAll can be used to load tokens in chunks
+func (s TokenResource) PaginatedFindAll(r api2go.Request) (uint, api2go.Responder, error) {
+	count, result, err := s.findAll(r)
+	if err != nil {
+		return 0, &Response{}, err
+	}
+
+	return count, &Response{Res: result}, nil
+}
+
+// findAll selects the current user's tokens
+func (s TokenResource) findAll(r api2go.Request) (uint, []model.Token, error) {
+	// 401, 403
+	currentUser, err := requireLogin(r)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// 400
+	params, err := ParseQueryParams(r, TokenFilterableFields, map[string]RelationshipFunc{})
+	if err != nil {
+		return 0, nil, api2go.NewHTTPError(
+			err,
+			err.Error(),
+			http.StatusBadRequest,
+		)
+	}
+
+	params.Where("tokens.user_id = :currentUserID")
+	params.Bind("currentUserID", currentUser.GetID())
+
+	// 500
+	count, result, err := s.TokenStorage.GetAll(params)
+	if err != nil {
+		return 0, nil, api2go.NewHTTPError(
+			err,
+			"Internal Server Error",
+			http.StatusInternalServerError)
+	}
+
+	return count, result, nil
+}
+
+// FindOne to satisfy `api2go.DataSource` interface
+// this method should return the token with the given ID, otherwise an error
+func (s TokenResource) FindOne(id string, r api2go.Request) (api2go.Responder, error) {
+	// 401, 403
+	currentUser, err := requireLogin(r)
+	if err != nil {
+		return &Response{}, err
+	}
+
+	// 400, 404, 500
+	token, err := s.findOwnToken(id, currentUser)
+	if err != nil {
+		return &Response{}, err
+	}
+
+	return &Response{Res: token}, nil
+}
+
+// Create a new token for the current user. The response is the only time the
+// token itself is ever rendered.
+func (s TokenResource) Create(obj interface{}, r api2go.Request) (api2go.Responder, error) {
+	// 401, 403
+	currentUser, err := requireLogin(r)
+	if err != nil {
+		return &Response{}, err
+	}
+
+	// 400
+	token, ok := obj.(model.Token)
+	if !ok {
+		return &Response{}, api2go.NewHTTPError(
+			errors.New("Invalid instance given"),
+			"Invalid instance given",
+			http.StatusBadRequest)
+	}
+
+	// 422
+	if errs := token.Validate(time.Now()); len(errs) > 0 {
+		return &Response{}, newValidationError(errs)
+	}
+
+	// 403
+	if token.Scopes.Has(model.ScopeUsersAdmin) && !currentUser.IsAdmin() {
+		return &Response{}, newForbiddenError(
+			fmt.Sprintf("Only admins may create tokens with the %s scope", model.ScopeUsersAdmin))
+	}
+
+	// 500
+	secret, err := auth.GeneratePersonalToken()
+	if err != nil {
+		return &Response{}, api2go.NewHTTPError(
+			err,
+			"Internal Server Error",
+			http.StatusInternalServerError)
+	}
+
+	token.UserId = currentUser.GetID()
+	token.TokenHash = auth.HashSecret(secret)
+
+	// 500
+	newToken, err := s.TokenStorage.Insert(token)
+	if err != nil {
+		return &Response{}, api2go.NewHTTPError(
+			errors.New("Internal Server Error"),
+			"Internal Server Error",
+			http.StatusInternalServerError)
+	}
+
+	newToken.Token = secret
+
+	return &Response{Res: newToken, Code: http.StatusCreated}, nil
+}
+
+// Delete revokes the token. Revoked tokens stay in the list so their history
+// can still be seen.
+func (s TokenResource) Delete(id string, r api2go.Request) (api2go.Responder, error) {
+	// 401, 403
+	currentUser, err := requireLogin(r)
+	if err != nil {
+		return &Response{}, err
+	}
+
+	// 400, 404, 500
+	if _, err = s.findOwnToken(id, currentUser); err != nil {
+		return &Response{}, err
+	}
+
+	// 500
+	err = s.TokenStorage.Revoke(id)
+	if err != nil {
+		return &Response{Code: http.StatusInternalServerError}, err
+	}
+
+	return &Response{Code: http.StatusNoContent}, nil
+}
+
+// findOwnToken loads a token belonging to the current user. Other users'
+// tokens are reported as missing.
+func (s TokenResource) findOwnToken(id string, currentUser *model.User) (*model.Token, error) {
+	// 400
+	_, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		errMessage := fmt.Sprintf("Token id must be integer: %s", id)
+
+		return nil, api2go.NewHTTPError(
+			err,
+			errMessage,
+			http.StatusBadRequest)
+	}
+
+	// 404
+	token, err := s.TokenStorage.GetOne(id)
+	if err == sql.ErrNoRows || (err == nil && token.UserId != currentUser.GetID()) {
+		errMessage := fmt.Sprintf("No token found with the id: %s", id)
+
+		return nil, api2go.NewHTTPError(
+			errors.New(errMessage),
+			errMessage,
+			http.StatusNotFound,
+		)
+
+		// 500
+	} else if err != nil {
+		return nil, api2go.NewHTTPError(
+			err,
+			"Internal Server Error",
+			http.StatusInternalServerError)
+	}
+
+	return token, nil
+}
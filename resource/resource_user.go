@@ -5,16 +5,19 @@ import (
 	"errors"
 	"fmt"
 	"github.com/manyminds/api2go"
+	"github.com/timrourke/timrourke.com/auth"
 	"github.com/timrourke/timrourke.com/model"
 	"github.com/timrourke/timrourke.com/query"
 	"github.com/timrourke/timrourke.com/storage"
 	"net/http"
 	"strconv"
+	"time"
 )
 
 // UserResource defines interface to storage layer
 type UserResource struct {
-	UserStorage *storage.UserStorage
+	UserStorage       *storage.UserStorage
+	AuditEventStorage *storage.AuditEventStorage
 }
 
 // UserFilterableFields is a map of fields a user can sort or filter by, where
@@ -22,6 +25,16 @@ type UserResource struct {
 // be performed using strict equality (true), or using a LIKE statement (false),
 // in the SQL generated for the query
 var UserFilterableFields = map[string]bool{
+	"id":         true,
+	"created-at": false,
+	"updated-at": false,
+	"username":   true,
+}
+
+// AdminUserFilterableFields are the fields admins can sort or filter users
+// by. Only admins may see every email address, so only they may search by
+// one; anyone else could use it to find out who has an account.
+var AdminUserFilterableFields = map[string]bool{
 	"id":         true,
 	"created-at": false,
 	"updated-at": false,
@@ -29,13 +42,57 @@ var UserFilterableFields = map[string]bool{
 	"username":   true,
 }
 
+// Get a post's owner or, when postsName is authors, all of its authors in
+// byline order by the postsID query param. Generally provided by api2go.
 func getUsersByPostsID(request api2go.Request, q *query.Query) *query.Query {
 	postsID, ok := request.QueryParams["postsID"]
+	if !ok {
+		return q
+	}
 
-	if ok {
+	if name, ok := request.QueryParams["postsName"]; ok && name[0] == "authors" {
+		// The owner may predate the post_authors table, so they come first
+		// unless the byline says otherwise
+		q.Join("LEFT JOIN post_authors", "post_authors.user_id = users.id AND post_authors.post_id = :postsID")
+		q.Where("(post_authors.post_id IS NOT NULL OR users.id IN (SELECT posts.user_id FROM posts WHERE posts.id = :postsID))")
+		q.OrderBy("COALESCE(post_authors.position, 0) ASC")
+	} else {
 		q.Join("LEFT JOIN posts posts", "posts.user_id = users.id")
 		q.Where("posts.id = :postsID")
-		q.Bind("postsID", postsID[0])
+	}
+
+	q.Bind("postsID", postsID[0])
+	return q
+}
+
+func getUsersByTokensID(request api2go.Request, q *query.Query) *query.Query {
+	tokensID, ok := request.QueryParams["tokensID"]
+
+	if ok {
+		q.Where("users.id IN (SELECT tokens.user_id FROM tokens WHERE tokens.id = :tokensID)")
+		q.Bind("tokensID", tokensID[0])
+	}
+
+	return q
+}
+
+func getUsersByAuditEventsID(request api2go.Request, q *query.Query) *query.Query {
+	auditEventsID, ok := request.QueryParams["audit-eventsID"]
+
+	if ok {
+		q.Where("users.id IN (SELECT audit_events.actor_id FROM audit_events WHERE audit_events.id = :auditEventsID)")
+		q.Bind("auditEventsID", auditEventsID[0])
+	}
+
+	return q
+}
+
+func getUsersByPostRevisionsID(request api2go.Request, q *query.Query) *query.Query {
+	postRevisionsID, ok := request.QueryParams["post-revisionsID"]
+
+	if ok {
+		q.Where("users.id IN (SELECT post_revisions.editor_id FROM post_revisions WHERE post_revisions.id = :postRevisionsID)")
+		q.Bind("postRevisionsID", postRevisionsID[0])
 	}
 
 	return q
@@ -45,13 +102,16 @@ func getUsersByPostsID(request api2go.Request, q *query.Query) *query.Query {
 // the function is the RelationshipFunc for modifying the query to get the given
 // relationship
 var UserRelationshipsByParam = map[string]RelationshipFunc{
-	"postsID": getUsersByPostsID,
+	"postsID":          getUsersByPostsID,
+	"tokensID":         getUsersByTokensID,
+	"audit-eventsID":   getUsersByAuditEventsID,
+	"post-revisionsID": getUsersByPostRevisionsID,
 }
 
 // FindAll to satisfy api2go data source interface
 func (s UserResource) FindAll(r api2go.Request) (api2go.Responder, error) {
 	// 400
-	params, err := ParseQueryParams(r, UserFilterableFields, UserRelationshipsByParam)
+	params, err := ParseQueryParams(r, userFilterableFields(r), UserRelationshipsByParam)
 	if err != nil {
 		return &Response{}, api2go.NewHTTPError(
 			err,
@@ -69,13 +129,17 @@ func (s UserResource) FindAll(r api2go.Request) (api2go.Responder, error) {
 			http.StatusInternalServerError)
 	}
 
+	for i := range result {
+		hideEmail(r, &result[i])
+	}
+
 	return &Response{Res: result}, err
 }
 
 // PaginatedFindAll can be used to load users in chunks
 func (s UserResource) PaginatedFindAll(r api2go.Request) (uint, api2go.Responder, error) {
 	// 400
-	params, err := ParseQueryParams(r, UserFilterableFields, UserRelationshipsByParam)
+	params, err := ParseQueryParams(r, userFilterableFields(r), UserRelationshipsByParam)
 	if err != nil {
 		return 0, &Response{}, api2go.NewHTTPError(
 			err,
@@ -93,6 +157,10 @@ func (s UserResource) PaginatedFindAll(r api2go.Request) (uint, api2go.Responder
 			http.StatusInternalServerError)
 	}
 
+	for i := range result {
+		hideEmail(r, &result[i])
+	}
+
 	return count, &Response{Res: result}, nil
 }
 
@@ -122,11 +190,24 @@ func (s UserResource) FindOne(id string, r api2go.Request) (api2go.Responder, er
 		)
 	}
 
+	hideEmail(r, user)
+
+	// Updates must send back the version they read
+	if isUpdate(r) {
+		user.Version = 0
+	}
+
 	return &Response{Res: user}, err
 }
 
 // Create method to satisfy `api2go.DataSource` interface
 func (s UserResource) Create(obj interface{}, r api2go.Request) (api2go.Responder, error) {
+	// 401, 403
+	currentUser, isFirstUser, err := s.authorizeCreate(r)
+	if err != nil {
+		return &Response{}, err
+	}
+
 	// 400
 	user, ok := obj.(model.User)
 	if !ok {
@@ -136,8 +217,33 @@ func (s UserResource) Create(obj interface{}, r api2go.Request) (api2go.Responde
 			http.StatusBadRequest)
 	}
 
+	// The first account must be able to manage everyone who joins later
+	if isFirstUser {
+		user.Role = model.RoleAdmin
+	} else if len(user.Role) == 0 {
+		user.Role = model.RoleAuthor
+	}
+
+	// 422
+	errs := append(model.NewUserFromUser(user).Validate(), user.ValidateProfile()...)
+	if len(errs) > 0 {
+		return &Response{}, newValidationError(errs)
+	}
+
+	// 500
+	passwordHash, err := model.HashPassword(user.Password)
+	if err != nil {
+		return &Response{}, api2go.NewHTTPError(
+			err,
+			"Internal Server Error",
+			http.StatusInternalServerError)
+	}
+	user.PasswordHash = passwordHash
+
+	audit := newAudit(s.AuditEventStorage, r, currentUser, model.AuditActionCreate, "users", "", nil)
+
 	// 500
-	newUser, err := s.UserStorage.Insert(user)
+	newUser, err := s.UserStorage.Insert(user, audit)
 	if err != nil {
 		return &Response{}, api2go.NewHTTPError(
 			errors.New("Internal Server Error"),
@@ -145,13 +251,25 @@ func (s UserResource) Create(obj interface{}, r api2go.Request) (api2go.Responde
 			http.StatusInternalServerError)
 	}
 
-	return &Response{Res: newUser, Code: http.StatusCreated}, err
+	return &Response{Res: newUser, Code: http.StatusCreated}, nil
 }
 
-// Delete to satisfy `api2go.DataSource` interface
+// Delete to satisfy `api2go.DataSource` interface. The user goes to the trash,
+// from which it can be restored until the trash is purged.
 func (s UserResource) Delete(id string, r api2go.Request) (api2go.Responder, error) {
+	// 401
+	currentUser, err := requireScope(r, model.ScopeUsersAdmin)
+	if err != nil {
+		return &Response{}, err
+	}
+
+	// 403
+	if !currentUser.IsAdmin() {
+		return &Response{}, newForbiddenError("Only admins may delete users")
+	}
+
 	// 400
-	_, err := strconv.ParseInt(id, 10, 64)
+	_, err = strconv.ParseInt(id, 10, 64)
 	if err != nil {
 		errMessage := fmt.Sprintf("User id must be integer: %s", id)
 
@@ -161,15 +279,54 @@ func (s UserResource) Delete(id string, r api2go.Request) (api2go.Responder, err
 			http.StatusBadRequest)
 	}
 
-	err = s.UserStorage.Delete(id)
-	if err != nil {
+	// 404
+	foundUser, err := s.UserStorage.GetOne(id)
+	if err == sql.ErrNoRows {
+		errMessage := fmt.Sprintf("No user found with the id: %s", id)
+
+		return &Response{}, api2go.NewHTTPError(
+			err,
+			errMessage,
+			http.StatusNotFound,
+		)
+
+		// 500
+	} else if err != nil {
+		return &Response{}, api2go.NewHTTPError(
+			err,
+			"Internal Server Error",
+			http.StatusInternalServerError)
+	}
+
+	audit := newAudit(s.AuditEventStorage, r, currentUser, model.AuditActionDelete, "users", id, foundUser)
+
+	// 404 when another request moved the user to the trash first
+	err = s.UserStorage.Delete(id, audit)
+	if err == sql.ErrNoRows {
+		errMessage := fmt.Sprintf("No user found with the id: %s", id)
+
+		return &Response{}, api2go.NewHTTPError(
+			err,
+			errMessage,
+			http.StatusNotFound,
+		)
+
+		// 500
+	} else if err != nil {
 		return &Response{Code: http.StatusInternalServerError}, err
 	}
+
 	return &Response{Code: http.StatusNoContent}, nil
 }
 
 // Update stores all changes on the user
 func (s UserResource) Update(obj interface{}, r api2go.Request) (api2go.Responder, error) {
+	// 401
+	currentUser, err := requireScope(r, model.ScopeUsersAdmin)
+	if err != nil {
+		return &Response{}, err
+	}
+
 	user, ok := obj.(*model.User)
 
 	// 400
@@ -181,6 +338,12 @@ func (s UserResource) Update(obj interface{}, r api2go.Request) (api2go.Responde
 	}
 
 	id := user.GetID()
+
+	// 403
+	if !currentUser.IsAdmin() && currentUser.GetID() != id {
+		return &Response{}, newForbiddenError("Only admins may change other users")
+	}
+
 	foundUser, err := s.UserStorage.GetOne(id)
 
 	// 404
@@ -201,19 +364,144 @@ func (s UserResource) Update(obj interface{}, r api2go.Request) (api2go.Responde
 			http.StatusInternalServerError)
 	}
 
+	// 409, 412, 428
+	if err := checkVersion(r, "user", user.Version, foundUser.Version); err != nil {
+		return &Response{}, err
+	}
+
+	// 403
+	if user.Role != foundUser.Role && !currentUser.IsAdmin() {
+		return &Response{}, newForbiddenError("Only admins may change roles")
+	}
+
+	// 403, 422
+	unlock := !sameTime(user.LockedUntil, foundUser.LockedUntil)
+	if unlock && !currentUser.IsAdmin() {
+		return &Response{}, newForbiddenError("Only admins may unlock users")
+	} else if unlock && user.LockedUntil != nil {
+		return &Response{}, newValidationError([]model.ValidationError{
+			{Attribute: "locked-until", Message: "can only be cleared"},
+		})
+	}
+
+	before := *foundUser
+
 	// Update fields in user
 	foundUser.Email = user.Email
 	foundUser.Username = user.Username
-	// TODO: implement password hashing
+	foundUser.Role = user.Role
+	foundUser.DisplayName = user.DisplayName
+	foundUser.Bio = user.Bio
+	foundUser.AvatarURL = user.AvatarURL
+	foundUser.Website = user.Website
+	foundUser.SocialLinks = user.SocialLinks
+
+	// 422
+	newUser := model.NewUserFromUser(*user)
+	errs := append(newUser.ValidateAccount(), user.ValidateProfile()...)
+	passwordChanged := len(user.Password) > 0 || len(user.PasswordConfirmation) > 0
+	if passwordChanged {
+		errs = append(errs, newUser.ValidatePassword()...)
+	}
+
+	if len(errs) > 0 {
+		return &Response{}, newValidationError(errs)
+	}
 
 	// 500
-	err = s.UserStorage.Update(foundUser)
-	if err != nil {
+	if passwordChanged {
+		foundUser.PasswordHash, err = model.HashPassword(user.Password)
+		if err != nil {
+			return &Response{}, api2go.NewHTTPError(
+				err,
+				"Internal Server Error",
+				http.StatusInternalServerError)
+		}
+	}
+
+	var unlockEvent *model.LockoutEvent
+	if unlock {
+		actorID := currentUser.GetID()
+		unlockEvent = &model.LockoutEvent{
+			Event:   model.LockoutEventUnlocked,
+			UserId:  &id,
+			ActorId: &actorID,
+		}
+	}
+
+	audit := newAudit(s.AuditEventStorage, r, currentUser, model.AuditActionUpdate, "users", id, before)
+
+	// 409
+	err = s.UserStorage.Update(foundUser, unlockEvent, audit)
+	if err == storage.ErrStaleVersion {
+		latest, _ := s.UserStorage.GetOne(id)
+		return &Response{}, newVersionError(http.StatusConflict, "user", before.Version, latest.Version)
+
+		// 500
+	} else if err != nil {
 		return &Response{}, api2go.NewHTTPError(
 			err,
 			"Internal Server Error",
 			http.StatusInternalServerError)
 	}
 
-	return &Response{Res: foundUser, Code: http.StatusNoContent}, err
+	return &Response{Res: foundUser, Code: http.StatusNoContent}, nil
+}
+
+// authorizeCreate requires an admin, except while the users table is empty so
+// that the first account can be created. It returns the current user, if any,
+// and reports whether the user being created is the first one.
+func (s UserResource) authorizeCreate(r api2go.Request) (*model.User, bool, error) {
+	count, err := s.UserStorage.Count()
+	if err != nil {
+		return nil, false, api2go.NewHTTPError(
+			err,
+			"Internal Server Error",
+			http.StatusInternalServerError)
+	}
+
+	if count == 0 {
+		return nil, true, nil
+	}
+
+	currentUser, err := requireScope(r, model.ScopeUsersAdmin)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !currentUser.IsAdmin() {
+		return nil, false, newForbiddenError("Only admins may create users")
+	}
+
+	return currentUser, false, nil
+}
+
+// userFilterableFields returns the fields the current user may sort or filter
+// users by
+func userFilterableFields(r api2go.Request) map[string]bool {
+	if currentUser, ok := auth.UserFromContext(r.Context); ok && currentUser.IsAdmin() {
+		return AdminUserFilterableFields
+	}
+
+	return UserFilterableFields
+}
+
+// hideEmail clears a user's email address unless the current user is them or
+// an admin
+func hideEmail(r api2go.Request, user *model.User) {
+	currentUser, ok := auth.UserFromContext(r.Context)
+	if ok && (currentUser.IsAdmin() || currentUser.GetID() == user.GetID()) {
+		return
+	}
+
+	user.Email = ""
+}
+
+// sameTime reports whether two optional times are both unset or equal
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return a.Equal(*b)
 }
@@ -4,12 +4,21 @@ package resource
 type Response struct {
 	Res  interface{}
 	Code int
+
+	// Meta is added to the meta every response has
+	Meta map[string]interface{}
 }
 
 func (r Response) Metadata() map[string]interface{} {
-	return map[string]interface{}{
+	meta := map[string]interface{}{
 		"version": "0",
 	}
+
+	for key, value := range r.Meta {
+		meta[key] = value
+	}
+
+	return meta
 }
 
 // Result returns the actual payload
@@ -0,0 +1,93 @@
+package resource
+
+import (
+	"errors"
+	"fmt"
+	"github.com/manyminds/api2go"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// isUpdate reports whether a request is a PATCH, for which api2go loads the
+// resource with FindOne before applying the request body to it
+func isUpdate(r api2go.Request) bool {
+	return r.PlainRequest != nil && r.PlainRequest.Method == http.MethodPatch
+}
+
+// checkVersion checks that an update was made against the current version of
+// a resource. The version read may be sent as the version attribute, which
+// FindOne leaves empty for updates, or as an If-Match header. Updates that
+// send neither get a 428 error, a stale If-Match header gets a 412 error and a
+// stale version attribute gets a 409 error.
+func checkVersion(r api2go.Request, noun string, sent, current int) error {
+	if sent != 0 {
+		// 409
+		if sent != current {
+			return newVersionError(http.StatusConflict, noun, sent, current)
+		}
+
+		return nil
+	}
+
+	ifMatch := ""
+	if r.PlainRequest != nil {
+		ifMatch = r.PlainRequest.Header.Get("If-Match")
+	}
+
+	// 428
+	if len(ifMatch) == 0 {
+		message := fmt.Sprintf("Updating a %s requires the version being changed, as the version attribute or an If-Match header", noun)
+
+		return api2go.NewHTTPError(
+			errors.New(message),
+			message,
+			http.StatusPreconditionRequired)
+	}
+
+	// 400
+	sent, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(ifMatch, "W/"), `"`))
+	if err != nil {
+		message := fmt.Sprintf("If-Match must be a version number: %s", ifMatch)
+
+		return api2go.NewHTTPError(
+			err,
+			message,
+			http.StatusBadRequest)
+	}
+
+	// 412
+	if sent != current {
+		return newVersionError(http.StatusPreconditionFailed, noun, sent, current)
+	}
+
+	return nil
+}
+
+// newVersionError builds a 409 or 412 error for an update made against a stale
+// version, telling the client which version it has to reload
+func newVersionError(status int, noun string, sent, current int) api2go.HTTPError {
+	detail := fmt.Sprintf("This %s was changed by someone else since version %d was read. It is now at version %d; reload it and try again.", noun, sent, current)
+
+	httpErr := api2go.NewHTTPError(
+		errors.New(detail),
+		http.StatusText(status),
+		status)
+
+	httpErr.Errors = []api2go.Error{
+		{
+			Status: strconv.Itoa(status),
+			Code:   "stale_version",
+			Title:  "Edit Conflict",
+			Detail: detail,
+			Source: &api2go.ErrorSource{
+				Pointer: "/data/attributes/version",
+			},
+			Meta: map[string]interface{}{
+				"current-version": current,
+			},
+		},
+	}
+
+	return httpErr
+}
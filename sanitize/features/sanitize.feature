@@ -0,0 +1,55 @@
+Feature: sanitize HTML
+	In order to keep readers safe from scripts slipped into a post
+	As an author on timrourke.com
+	I need post HTML cleaned down to the markup the editor produces
+
+	Scenario: Keep the markup the editor produces
+		When I sanitize the HTML:
+			"""
+			<h2 class="ql-align-center">Title</h2><p><strong>Bold</strong> <em>and</em> <u>more</u> <a href="https://example.com" target="_blank">link</a></p><ol><li class="ql-indent-1">one</li></ol><pre class="ql-syntax" spellcheck="false">code</pre>
+			"""
+		Then the HTML should be:
+			"""
+			<h2 class="ql-align-center">Title</h2><p><strong>Bold</strong> <em>and</em> <u>more</u> <a href="https://example.com" target="_blank" rel="noopener noreferrer">link</a></p><ol><li class="ql-indent-1">one</li></ol><pre class="ql-syntax" spellcheck="false">code</pre>
+			"""
+
+	Scenario: Strip scripts, event handlers and javascript URLs
+		When I sanitize the HTML:
+			"""
+			<p onclick="steal()">Hi<script>alert(1)</script></p><a href=" jav&#x09;ascript:alert(1)">x</a><img src="x.png" onerror="alert(1)">
+			"""
+		Then the HTML should be:
+			"""
+			<p>Hi</p><a>x</a><img src="x.png">
+			"""
+
+	Scenario: Unwrap unknown tags and close open ones
+		When I sanitize the HTML:
+			"""
+			<div class="wrapper" style="position:fixed"><marquee>Hello</marquee> <span style="color: #e60000; position: fixed">red</span><p>open
+			"""
+		Then the HTML should be:
+			"""
+			Hello <span style="color: #e60000">red</span><p>open</p>
+			"""
+
+	Scenario: Allow iframes only from trusted embed hosts
+		Given embeds are allowed from "player.vimeo.com"
+		When I sanitize the HTML:
+			"""
+			<iframe class="ql-video" src="https://player.vimeo.com/video/1" frameborder="0" allowfullscreen></iframe><iframe src="https://evil.example.com/">fallback</iframe><iframe src="http://player.vimeo.com/video/2"></iframe>
+			"""
+		Then the HTML should be:
+			"""
+			<iframe class="ql-video" src="https://player.vimeo.com/video/1" frameborder="0" allowfullscreen="true"></iframe>
+			"""
+
+	Scenario: Take the text of HTML for counting words
+		When I take the text of the HTML:
+			"""
+			<h1>Fish &amp; chips</h1><p>With <strong>mushy</strong>peas<script>track()</script></p><ul><li>salt</li><li>vinegar</li></ul>
+			"""
+		Then the text should be:
+			"""
+			Fish & chips With mushypeas salt vinegar
+			"""
@@ -0,0 +1,391 @@
+// Package sanitize cleans HTML written in the admin's editor so that it is
+// safe to serve to readers
+package sanitize
+
+import (
+	"bytes"
+	"golang.org/x/net/html"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// DefaultEmbedHosts are the hosts iframes may load from unless configured
+// otherwise, covering the videos the editor embeds
+var DefaultEmbedHosts = []string{
+	"www.youtube.com",
+	"www.youtube-nocookie.com",
+	"player.vimeo.com",
+}
+
+// allowedAttributes lists the elements that are kept and the attributes each
+// may keep. Every other element is unwrapped, leaving its text.
+var allowedAttributes = map[string][]string{
+	"a":          {"href", "title", "target", "rel"},
+	"b":          {},
+	"blockquote": {"class"},
+	"br":         {},
+	"code":       {"class"},
+	"del":        {},
+	"em":         {},
+	"h1":         {"class"},
+	"h2":         {"class"},
+	"h3":         {"class"},
+	"h4":         {"class"},
+	"h5":         {"class"},
+	"h6":         {"class"},
+	"hr":         {},
+	"i":          {},
+	"iframe":     {"src", "class", "width", "height", "frameborder", "allowfullscreen"},
+	"img":        {"src", "alt", "title", "width", "height", "class"},
+	"li":         {"class"},
+	"ol":         {"class", "start"},
+	"p":          {"class"},
+	"pre":        {"class", "spellcheck"},
+	"s":          {},
+	"span":       {"class", "style"},
+	"strike":     {},
+	"strong":     {},
+	"sub":        {},
+	"sup":        {},
+	"u":          {},
+	"ul":         {"class"},
+}
+
+// droppedElements are removed along with everything inside them
+var droppedElements = map[string]bool{
+	"embed":    true,
+	"head":     true,
+	"iframe":   true,
+	"math":     true,
+	"noscript": true,
+	"object":   true,
+	"script":   true,
+	"select":   true,
+	"style":    true,
+	"svg":      true,
+	"template": true,
+	"textarea": true,
+	"title":    true,
+}
+
+// inlineElements do not separate the words either side of them
+var inlineElements = map[string]bool{
+	"a":      true,
+	"abbr":   true,
+	"b":      true,
+	"code":   true,
+	"del":    true,
+	"em":     true,
+	"i":      true,
+	"mark":   true,
+	"s":      true,
+	"small":  true,
+	"span":   true,
+	"strike": true,
+	"strong": true,
+	"sub":    true,
+	"sup":    true,
+	"u":      true,
+}
+
+var (
+	// safeClass matches the classes the editor and the markdown renderer use
+	safeClass = regexp.MustCompile(`^(?:ql-[a-z0-9-]+|language-[a-zA-Z0-9_+#-]+)$`)
+
+	// safeStyle matches the text colors and highlights the editor sets
+	safeStyle = regexp.MustCompile(`^(?:color|background-color)\s*:\s*(?:#[0-9a-fA-F]{3,8}|rgba?\(\s*[0-9.,\s%]+\)|[a-zA-Z]+)$`)
+
+	// safeNumber matches sizes and list starts
+	safeNumber = regexp.MustCompile(`^[0-9]{1,5}%?$`)
+
+	// dataImage matches images the editor inlines when they are pasted in
+	dataImage = regexp.MustCompile(`^data:image/(?:png|jpeg|gif|webp);base64,[a-zA-Z0-9+/]+=*$`)
+)
+
+// Policy decides which HTML is kept. Iframes are only kept when they load
+// over https from one of EmbedHosts, and a nil Policy keeps none.
+type Policy struct {
+	EmbedHosts []string
+}
+
+// NewPolicy returns a policy allowing iframes from the given hosts
+func NewPolicy(embedHosts []string) *Policy {
+	hosts := make([]string, len(embedHosts))
+	for i, host := range embedHosts {
+		hosts[i] = strings.ToLower(strings.TrimSpace(host))
+	}
+
+	return &Policy{EmbedHosts: hosts}
+}
+
+// HTML returns a fragment of HTML with everything the policy does not allow
+// removed. Scripts, styles and other active content are removed with their
+// contents, unknown elements are unwrapped, event handlers and other unknown
+// attributes are dropped, and links may only use http, https, mailto or
+// relative URLs. Elements left open are closed.
+func (p *Policy) HTML(fragment string) string {
+	var b bytes.Buffer
+	var open []string
+	dropping, dropDepth := "", 0
+
+	tokenizer := html.NewTokenizer(strings.NewReader(fragment))
+	for {
+		if tokenizer.Next() == html.ErrorToken {
+			if tokenizer.Err() != io.EOF {
+				return ""
+			}
+			break
+		}
+
+		token := tokenizer.Token()
+
+		// Skip everything inside a dropped element, minding nested ones
+		if len(dropping) > 0 {
+			if token.Data == dropping && token.Type == html.StartTagToken {
+				dropDepth++
+			} else if token.Data == dropping && token.Type == html.EndTagToken {
+				dropDepth--
+				if dropDepth == 0 {
+					dropping = ""
+				}
+			}
+			continue
+		}
+
+		switch token.Type {
+		case html.TextToken:
+			b.WriteString(html.EscapeString(token.Data))
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			attrs, ok := p.allow(token)
+			if !ok {
+				if droppedElements[token.Data] && token.Type == html.StartTagToken && !isVoid(token.Data) {
+					dropping, dropDepth = token.Data, 1
+				}
+				continue
+			}
+
+			writeStartTag(&b, token.Data, attrs)
+			if !isVoid(token.Data) {
+				open = append(open, token.Data)
+			}
+
+		case html.EndTagToken:
+			// Close the element along with any left open inside it
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] != token.Data {
+					continue
+				}
+
+				for len(open) > i {
+					b.WriteString("</" + open[len(open)-1] + ">")
+					open = open[:len(open)-1]
+				}
+				break
+			}
+		}
+	}
+
+	for len(open) > 0 {
+		b.WriteString("</" + open[len(open)-1] + ">")
+		open = open[:len(open)-1]
+	}
+
+	return b.String()
+}
+
+// Text returns the text of a fragment of HTML with its markup stripped and
+// its whitespace collapsed. The contents of scripts, styles and other dropped
+// elements are left out, and block elements are kept apart by a space.
+func Text(fragment string) string {
+	var b bytes.Buffer
+	dropping, dropDepth := "", 0
+
+	tokenizer := html.NewTokenizer(strings.NewReader(fragment))
+	for tokenizer.Next() != html.ErrorToken {
+		token := tokenizer.Token()
+
+		if len(dropping) > 0 {
+			if token.Data == dropping && token.Type == html.StartTagToken {
+				dropDepth++
+			} else if token.Data == dropping && token.Type == html.EndTagToken {
+				dropDepth--
+				if dropDepth == 0 {
+					dropping = ""
+				}
+			}
+			continue
+		}
+
+		switch token.Type {
+		case html.TextToken:
+			b.WriteString(token.Data)
+		case html.StartTagToken, html.SelfClosingTagToken, html.EndTagToken:
+			if token.Type == html.StartTagToken && droppedElements[token.Data] && !isVoid(token.Data) {
+				dropping, dropDepth = token.Data, 1
+			} else if !inlineElements[token.Data] {
+				b.WriteString(" ")
+			}
+		}
+	}
+
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// allow reports whether an element is kept, returning the attributes it
+// keeps
+func (p *Policy) allow(token html.Token) ([]html.Attribute, bool) {
+	allowed, ok := allowedAttributes[token.Data]
+	if !ok {
+		return nil, false
+	}
+
+	var attrs []html.Attribute
+	opensNewWindow := false
+
+	for _, attr := range token.Attr {
+		name := strings.ToLower(attr.Key)
+		if len(attr.Namespace) > 0 || !contains(allowed, name) {
+			continue
+		}
+
+		value, ok := p.allowValue(token.Data, name, strings.TrimSpace(attr.Val))
+		if !ok {
+			continue
+		}
+
+		// rel is set below for links opening a new window
+		if name == "rel" {
+			continue
+		} else if name == "target" {
+			opensNewWindow = value == "_blank"
+		}
+
+		attrs = append(attrs, html.Attribute{Key: name, Val: value})
+	}
+
+	// Iframes are useless without somewhere trusted to load from
+	if token.Data == "iframe" && !hasAttribute(attrs, "src") {
+		return nil, false
+	}
+
+	// Pages opened in a new window must not be able to reach back to this one
+	if opensNewWindow {
+		attrs = append(attrs, html.Attribute{Key: "rel", Val: "noopener noreferrer"})
+	}
+
+	return attrs, true
+}
+
+// allowValue checks the value of an allowed attribute, returning it cleaned
+func (p *Policy) allowValue(element, name, value string) (string, bool) {
+	switch name {
+	case "href":
+		return value, safeURL(value, "http", "https", "mailto")
+	case "src":
+		if element == "iframe" {
+			return value, p.allowEmbed(value)
+		} else if element == "img" && dataImage.MatchString(value) {
+			return value, true
+		}
+		return value, safeURL(value, "http", "https")
+	case "class":
+		var classes []string
+		for _, class := range strings.Fields(value) {
+			if safeClass.MatchString(class) {
+				classes = append(classes, class)
+			}
+		}
+		return strings.Join(classes, " "), len(classes) > 0
+	case "style":
+		var declarations []string
+		for _, declaration := range strings.Split(value, ";") {
+			declaration = strings.TrimSpace(declaration)
+			if safeStyle.MatchString(declaration) {
+				declarations = append(declarations, declaration)
+			}
+		}
+		return strings.Join(declarations, "; "), len(declarations) > 0
+	case "width", "height", "start", "frameborder":
+		return value, safeNumber.MatchString(value)
+	case "target":
+		return value, value == "_blank" || value == "_self"
+	case "spellcheck":
+		return value, value == "false" || value == "true"
+	case "allowfullscreen":
+		return "true", true
+	}
+
+	return value, true
+}
+
+// allowEmbed reports whether an iframe may load a URL, which must be https
+// on one of the policy's embed hosts
+func (p *Policy) allowEmbed(raw string) bool {
+	parsed, err := url.Parse(raw)
+	if p == nil || err != nil || parsed.Scheme != "https" || len(parsed.User.String()) > 0 {
+		return false
+	}
+
+	return contains(p.EmbedHosts, strings.ToLower(parsed.Hostname()))
+}
+
+// safeURL reports whether a URL is relative or uses one of the given schemes
+func safeURL(raw string, schemes ...string) bool {
+	// Browsers ignore control characters and whitespace inside a scheme
+	cleaned := strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, raw)
+
+	colon := strings.Index(cleaned, ":")
+	if colon < 0 || strings.ContainsAny(cleaned[:colon], "/?#") {
+		return true
+	}
+
+	return contains(schemes, strings.ToLower(cleaned[:colon]))
+}
+
+// writeStartTag writes an element's start tag with its attribute values
+// escaped
+func writeStartTag(b *bytes.Buffer, name string, attrs []html.Attribute) {
+	b.WriteString("<" + name)
+	for _, attr := range attrs {
+		b.WriteString(" " + attr.Key + `="` + html.EscapeString(attr.Val) + `"`)
+	}
+	b.WriteString(">")
+}
+
+// isVoid reports whether an element never has contents or an end tag
+func isVoid(name string) bool {
+	switch name {
+	case "br", "hr", "img", "embed", "input", "meta", "link", "area", "base", "col", "param", "source", "track", "wbr":
+		return true
+	}
+
+	return false
+}
+
+func hasAttribute(attrs []html.Attribute, name string) bool {
+	for _, attr := range attrs {
+		if attr.Key == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,49 @@
+package sanitize
+
+import (
+	"fmt"
+	"github.com/DATA-DOG/godog"
+	"github.com/DATA-DOG/godog/gherkin"
+	"strings"
+)
+
+var (
+	policy    *Policy
+	sanitized string
+)
+
+func embedsAreAllowedFrom(hosts string) error {
+	policy = NewPolicy(strings.Split(hosts, ","))
+	return nil
+}
+
+func iSanitizeTheHTML(fragment *gherkin.DocString) error {
+	sanitized = policy.HTML(fragment.Content)
+	return nil
+}
+
+func iTakeTheTextOfTheHTML(fragment *gherkin.DocString) error {
+	sanitized = Text(fragment.Content)
+	return nil
+}
+
+func theHTMLShouldBe(expected *gherkin.DocString) error {
+	if strings.TrimSpace(sanitized) == strings.TrimSpace(expected.Content) {
+		return nil
+	}
+	return fmt.Errorf("expected HTML '%s', but it was '%s'",
+		expected.Content,
+		sanitized)
+}
+
+func FeatureContext(s *godog.Suite) {
+	s.BeforeScenario(func(interface{}) {
+		policy = NewPolicy(DefaultEmbedHosts)
+	})
+
+	s.Step(`^embeds are allowed from "([^"]*)"$`, embedsAreAllowedFrom)
+	s.Step(`^I sanitize the HTML:$`, iSanitizeTheHTML)
+	s.Step(`^I take the text of the HTML:$`, iTakeTheTextOfTheHTML)
+	s.Step(`^the HTML should be:$`, theHTMLShouldBe)
+	s.Step(`^the text should be:$`, theHTMLShouldBe)
+}
@@ -0,0 +1,49 @@
+package scheduler
+
+import (
+	"github.com/timrourke/timrourke.com/model"
+	"github.com/timrourke/timrourke.com/storage"
+	"time"
+)
+
+// PublishScheduledPostsJob names the job that publishes scheduled posts
+const PublishScheduledPostsJob = "publish_scheduled_posts"
+
+// PublishScheduledPosts returns a job that publishes scheduled posts once
+// their publish time has passed. Each post is published by a single
+// conditional update, so a run cut short by a restart is simply finished by
+// the next one and no post is published twice.
+func PublishScheduledPosts(postStorage *storage.PostStorage, auditEventStorage *storage.AuditEventStorage, interval time.Duration) Job {
+	return Job{
+		Name:     PublishScheduledPostsJob,
+		Interval: interval,
+		Run: func(now time.Time) (int, error) {
+			posts, err := postStorage.GetDueScheduled(now)
+			if err != nil {
+				return 0, err
+			}
+
+			published := 0
+			for i := range posts {
+				before := posts[i]
+
+				audit := auditEventStorage.Record(model.AuditEvent{
+					Action:       model.AuditActionUpdate,
+					ResourceType: "posts",
+					ResourceId:   posts[i].GetID(),
+				}, before)
+
+				ok, err := postStorage.PublishScheduled(&posts[i], now, audit)
+				if err != nil {
+					return published, err
+				} else if !ok {
+					continue
+				}
+
+				published++
+			}
+
+			return published, nil
+		},
+	}
+}
@@ -0,0 +1,77 @@
+package scheduler
+
+import (
+	"github.com/timrourke/timrourke.com/model"
+	"github.com/timrourke/timrourke.com/storage"
+	"time"
+)
+
+// PurgeTrashJob names the job that empties the trash
+const PurgeTrashJob = "purge_trash"
+
+// DefaultTrashRetention is how long posts and users stay in the trash unless
+// configured otherwise
+const DefaultTrashRetention = 30 * 24 * time.Hour
+
+// PurgeTrash returns a job that deletes posts and users for good once they
+// have been in the trash longer than retention. Posts go first, so that users
+// whose posts have all gone can follow in the same run; users who still have
+// posts stay in the trash. Each delete checks the row is still expired, so a
+// post or user restored mid-run is left alone.
+func PurgeTrash(postStorage *storage.PostStorage, userStorage *storage.UserStorage, auditEventStorage *storage.AuditEventStorage, retention, interval time.Duration) Job {
+	if retention <= 0 {
+		retention = DefaultTrashRetention
+	}
+
+	return Job{
+		Name:     PurgeTrashJob,
+		Interval: interval,
+		Run: func(now time.Time) (int, error) {
+			before := now.Add(-retention)
+			purged := 0
+
+			posts, err := postStorage.GetExpiredTrash(before)
+			if err != nil {
+				return purged, err
+			}
+
+			for i := range posts {
+				ok, err := postStorage.Purge(&posts[i], before, purgeAudit(auditEventStorage, "posts", posts[i].GetID(), posts[i]))
+				if err != nil {
+					return purged, err
+				} else if !ok {
+					continue
+				}
+
+				purged++
+			}
+
+			users, err := userStorage.GetExpiredTrash(before)
+			if err != nil {
+				return purged, err
+			}
+
+			for i := range users {
+				ok, err := userStorage.Purge(&users[i], before, purgeAudit(auditEventStorage, "users", users[i].GetID(), users[i]))
+				if err != nil {
+					return purged, err
+				} else if !ok {
+					continue
+				}
+
+				purged++
+			}
+
+			return purged, nil
+		},
+	}
+}
+
+// purgeAudit returns the audit recording a post or user being deleted for good
+func purgeAudit(auditEventStorage *storage.AuditEventStorage, resourceType, id string, before interface{}) storage.Audit {
+	return auditEventStorage.Record(model.AuditEvent{
+		Action:       model.AuditActionPurge,
+		ResourceType: resourceType,
+		ResourceId:   id,
+	}, before)
+}
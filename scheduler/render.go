@@ -0,0 +1,52 @@
+package scheduler
+
+import (
+	"github.com/timrourke/timrourke.com/sanitize"
+	"github.com/timrourke/timrourke.com/storage"
+	"time"
+)
+
+// RenderPostsJob names the job that renders posts saved before their HTML
+// was cached
+const RenderPostsJob = "render_posts"
+
+// RenderPosts returns a job that caches the HTML of posts saved before it
+// was cached, counting their words and working out their reading times, and
+// generating the excerpts their authors left blank. Their content may
+// predate sanitizing, so it is sanitized by policy before it is rendered,
+// though the content itself is left as it was saved.
+func RenderPosts(postStorage *storage.PostStorage, policy *sanitize.Policy, excerptLength int, interval time.Duration) Job {
+	return Job{
+		Name:     RenderPostsJob,
+		Interval: interval,
+		Run: func(now time.Time) (int, error) {
+			posts, err := postStorage.GetUnrendered()
+			if err != nil {
+				return 0, err
+			}
+
+			rendered := 0
+			for i := range posts {
+				excerpt := posts[i].Excerpt
+
+				posts[i].Sanitize(policy)
+				posts[i].RenderContent()
+				posts[i].GenerateExcerpt(excerptLength, nil)
+				if !posts[i].ExcerptGenerated {
+					posts[i].Excerpt = excerpt
+				}
+
+				ok, err := postStorage.SaveRendered(&posts[i])
+				if err != nil {
+					return rendered, err
+				} else if !ok {
+					continue
+				}
+
+				rendered++
+			}
+
+			return rendered, nil
+		},
+	}
+}
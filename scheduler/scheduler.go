@@ -0,0 +1,122 @@
+package scheduler
+
+import (
+	"fmt"
+	"github.com/timrourke/timrourke.com/storage"
+	"log"
+	"sync"
+	"time"
+)
+
+// DefaultInterval is how often jobs run unless they say otherwise
+const DefaultInterval = time.Minute
+
+// Job is a task the scheduler runs over and over. Run returns how many items
+// it handled. Jobs must be safe to run again after being interrupted.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(now time.Time) (int, error)
+}
+
+// Scheduler runs jobs in the background until it is stopped, recording when
+// each one last ran
+type Scheduler struct {
+	JobRunStorage *storage.JobRunStorage
+
+	jobs []Job
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New returns a new instance of Scheduler
+func New(jobRunStorage *storage.JobRunStorage) *Scheduler {
+	return &Scheduler{
+		JobRunStorage: jobRunStorage,
+		stop:          make(chan struct{}),
+	}
+}
+
+// Add adds a job to run once the scheduler starts
+func (s *Scheduler) Add(job Job) {
+	if job.Interval <= 0 {
+		job.Interval = DefaultInterval
+	}
+
+	s.jobs = append(s.jobs, job)
+}
+
+// Start runs every job straight away, to catch up on anything due while the
+// server was down, and then again after each interval
+func (s *Scheduler) Start() {
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go s.loop(job)
+	}
+}
+
+// Stop stops running jobs, waiting for any run in progress to finish
+func (s *Scheduler) Stop() {
+	close(s.stop)
+	s.wg.Wait()
+}
+
+// RunAll runs every job once, returning the first error
+func (s *Scheduler) RunAll() error {
+	var firstErr error
+
+	for _, job := range s.jobs {
+		if err := s.RunOnce(job); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// RunOnce runs a job and records the run
+func (s *Scheduler) RunOnce(job Job) error {
+	if err := s.JobRunStorage.Start(job.Name, time.Now()); err != nil {
+		return err
+	}
+
+	items, runErr := runSafely(job, time.Now())
+
+	if err := s.JobRunStorage.Finish(job.Name, time.Now(), items, runErr); err != nil {
+		return err
+	}
+
+	return runErr
+}
+
+// loop runs a job every interval until the scheduler stops
+func (s *Scheduler) loop(job Job) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.RunOnce(job); err != nil {
+			log.Println(fmt.Sprintf("%v: job %s failed:", time.Now().Format(time.RFC3339Nano), job.Name), err)
+		}
+
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runSafely runs a job, turning a panic into an error so one bad run does not
+// take the server down
+func runSafely(job Job, now time.Time) (items int, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job %s panicked: %v", job.Name, r)
+		}
+	}()
+
+	return job.Run(now)
+}
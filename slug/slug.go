@@ -0,0 +1,90 @@
+// Package slug turns titles into URL-safe permalinks
+package slug
+
+import (
+	"bytes"
+	"golang.org/x/text/unicode/norm"
+	"strings"
+	"unicode"
+)
+
+// Fallback is the slug for text with nothing that can be transliterated
+const Fallback = "post"
+
+// MaxLength is the longest slug Make returns, leaving room in the permalink
+// column for a numbered suffix
+const MaxLength = 200
+
+// transliterations spells out letters that do not decompose into an ASCII
+// letter and combining marks
+var transliterations = map[rune]string{
+	// Latin
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'ø': "o", 'đ': "d", 'ð': "d", 'þ': "th",
+	'ł': "l", 'ı': "i", 'ħ': "h", 'ŋ': "ng", 'ſ': "s",
+
+	// Greek
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i",
+	'θ': "th", 'ι': "i", 'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x",
+	'ο': "o", 'π': "p", 'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t", 'υ': "y",
+	'φ': "f", 'χ': "ch", 'ψ': "ps", 'ω': "o",
+
+	// Cyrillic
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "yo",
+	'ж': "zh", 'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m",
+	'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u",
+	'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+	'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya", 'і': "i",
+	'ї': "yi", 'є': "ye", 'ґ': "g",
+
+	// Apostrophes join words rather than separating them
+	'\'': "", '’': "",
+
+	// Symbols that read as words
+	'&': " and ", '@': " at ", '+': " plus ",
+}
+
+// Make lowercases and transliterates text into words of ASCII letters and
+// digits joined by dashes. It returns Fallback when nothing is left.
+func Make(text string) string {
+	var b bytes.Buffer
+	dash := false
+
+	write := func(s string) {
+		for _, r := range s {
+			if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+				if dash && b.Len() > 0 {
+					b.WriteByte('-')
+				}
+				b.WriteRune(r)
+				dash = false
+			} else {
+				dash = true
+			}
+		}
+	}
+
+	// Decomposing separates accents from their letters, so é becomes e and a
+	// combining mark that is dropped
+	for _, r := range norm.NFKD.String(strings.ToLower(text)) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+
+		if spelled, ok := transliterations[r]; ok {
+			write(spelled)
+		} else {
+			write(string(r))
+		}
+	}
+
+	slug := b.String()
+	if len(slug) > MaxLength {
+		slug = strings.TrimRight(slug[:MaxLength], "-")
+	}
+
+	if len(slug) == 0 {
+		return Fallback
+	}
+
+	return slug
+}
@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/timrourke/timrourke.com/model"
+	"github.com/timrourke/timrourke.com/query"
+)
+
+// NewAuditEventStorage returns a new instance of AuditEventStorage
+func NewAuditEventStorage(DB *sqlx.DB) *AuditEventStorage {
+	return &AuditEventStorage{DB}
+}
+
+// AuditEventStorage forms SQL queries for the audit log. Audit events can only
+// be inserted, never changed or deleted.
+type AuditEventStorage struct {
+	DB *sqlx.DB
+}
+
+// GetAll selects a list of audit events
+func (s *AuditEventStorage) GetAll(q *query.Query) (uint, []model.AuditEvent, error) {
+	var (
+		events []model.AuditEvent
+		count  uint
+	)
+
+	q.Select("audit_events.*").From("audit_events audit_events")
+
+	sql, boundValues := q.Compile()
+
+	rows, err := s.DB.NamedQuery(sql, boundValues)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var m model.AuditEvent
+		err = rows.StructScan(&m)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		events = append(events, m)
+	}
+
+	// Get count of all matching audit events for pagination
+	count, errCount := countAll(s.DB, q)
+
+	if err != nil {
+		return 0, nil, err
+	} else if errCount != nil {
+		return 0, nil, errCount
+	}
+
+	return count, events, nil
+}
+
+// GetOne selects a single audit event
+func (s *AuditEventStorage) GetOne(ID string) (*model.AuditEvent, error) {
+	var event model.AuditEvent
+
+	err := s.DB.Get(&event, "SELECT * FROM audit_events WHERE id=?", ID)
+
+	return &event, err
+}
+
+// Audit records a write in the audit log from inside the write's
+// transaction, given the record as it is after the write, or nil when it is
+// gone. A write that cannot be recorded is rolled back, so that none goes
+// unaudited. A nil Audit records nothing.
+type Audit func(tx *sqlx.Tx, after interface{}) error
+
+// record runs the audit, if there is one
+func (a Audit) record(tx *sqlx.Tx, after interface{}) error {
+	if a == nil {
+		return nil
+	}
+
+	return a(tx, after)
+}
+
+// Record returns an Audit appending event, along with the changes the write
+// made to before. An event without a resource ID takes the ID of the record
+// written, as records being inserted only get one during the write.
+func (s *AuditEventStorage) Record(event model.AuditEvent, before interface{}) Audit {
+	return func(tx *sqlx.Tx, after interface{}) error {
+		if identifier, ok := after.(interface {
+			GetID() string
+		}); ok && len(event.ResourceId) == 0 {
+			event.ResourceId = identifier.GetID()
+		}
+		event.Changes = model.Diff(before, after)
+
+		_, err := tx.NamedExec(`INSERT INTO audit_events (
+			actor_id,
+			action,
+			resource_type,
+			resource_id,
+			client_ip,
+			changes
+		) VALUES (
+			:actor_id,
+			:action,
+			:resource_type,
+			:resource_id,
+			:client_ip,
+			:changes
+		)`, &event)
+
+		return err
+	}
+}
@@ -0,0 +1,299 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/timrourke/timrourke.com/model"
+	"github.com/timrourke/timrourke.com/query"
+	"strconv"
+)
+
+// maxCategoryPathLength is the longest path the categories table holds
+const maxCategoryPathLength = 255
+
+// NewCategoryStorage returns a new instance of CategoryStorage
+func NewCategoryStorage(DB *sqlx.DB) *CategoryStorage {
+	return &CategoryStorage{DB}
+}
+
+// CategoryStorage forms SQL queries for categories. Every change that moves
+// categories around locks the rows involved and rewrites the paths beneath
+// them in one transaction, so paths always agree with parents.
+type CategoryStorage struct {
+	DB *sqlx.DB
+}
+
+// GetAll selects a list of categories
+func (s *CategoryStorage) GetAll(q *query.Query) (uint, []model.Category, error) {
+	var (
+		categories []model.Category
+		count      uint
+	)
+
+	q.Select("categories.*").From("categories categories")
+
+	sql, boundValues := q.Compile()
+
+	rows, err := s.DB.NamedQuery(sql, boundValues)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var m model.Category
+		err = rows.StructScan(&m)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		categories = append(categories, m)
+	}
+
+	// Get count of all matching categories for pagination
+	count, errCount := countAll(s.DB, q)
+
+	if err != nil {
+		return 0, nil, err
+	} else if errCount != nil {
+		return 0, nil, errCount
+	}
+
+	return count, categories, nil
+}
+
+// GetOne selects a single category
+func (s *CategoryStorage) GetOne(ID string) (*model.Category, error) {
+	var category model.Category
+
+	err := s.DB.Get(&category, "SELECT * FROM categories WHERE id=?", ID)
+
+	return &category, err
+}
+
+// Insert inserts a single category beneath its parent
+func (s *CategoryStorage) Insert(c model.Category, audit Audit) (*model.Category, error) {
+	tx, err := s.DB.Beginx()
+	if err != nil {
+		return &model.Category{}, err
+	}
+
+	parentPath := "/"
+	if c.ParentId != nil {
+		var parent model.Category
+		err = tx.Get(&parent, "SELECT * FROM categories WHERE id=? FOR UPDATE", *c.ParentId)
+		if err != nil {
+			tx.Rollback()
+			return &model.Category{}, err
+		}
+
+		parentPath = parent.Path
+	}
+
+	result, err := tx.NamedExec(`INSERT INTO categories (
+		name,
+		slug,
+		parent_id
+	) VALUES (
+		:name,
+		:slug,
+		:parent_id
+	)`, &c)
+
+	if isDuplicateEntry(err) {
+		tx.Rollback()
+		return &model.Category{}, ErrCategoryTaken
+	} else if err != nil {
+		tx.Rollback()
+		return &model.Category{}, err
+	}
+
+	insertID, err := result.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return &model.Category{}, err
+	}
+
+	// Set ID on return struct for rendering to json
+	c.SetID(fmt.Sprintf("%d", insertID))
+
+	path := fmt.Sprintf("%s%d/", parentPath, insertID)
+	if len(path) > maxCategoryPathLength {
+		tx.Rollback()
+		return &model.Category{}, ErrCategoryTooDeep
+	}
+
+	if _, err = tx.Exec("UPDATE categories SET path=? WHERE id=?", path, insertID); err != nil {
+		tx.Rollback()
+		return &model.Category{}, err
+	}
+
+	c.Path = path
+	if err = audit.record(tx, &c); err != nil {
+		tx.Rollback()
+		return &model.Category{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return &model.Category{}, err
+	}
+
+	return s.GetOne(c.GetID())
+}
+
+// Update updates a single category. A category given a new parent is moved
+// there along with all of its descendants, failing with ErrCategoryCycle when
+// the new parent is the category itself or one of its descendants.
+func (s *CategoryStorage) Update(c *model.Category, audit Audit) error {
+	tx, err := s.DB.Beginx()
+	if err != nil {
+		return err
+	}
+
+	// Lock the category and its new parent in id order, so that two
+	// categories swapped at once cannot deadlock or end up beneath each other
+	locked := []model.Category{}
+	parentID := c.GetID()
+	if c.ParentId != nil {
+		parentID = *c.ParentId
+	}
+
+	err = tx.Select(&locked, "SELECT * FROM categories WHERE id=? OR id=? ORDER BY id FOR UPDATE", c.ID, parentID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	var current, parent *model.Category
+	for i := range locked {
+		if locked[i].ID == c.ID {
+			current = &locked[i]
+		}
+		if locked[i].GetID() == parentID {
+			parent = &locked[i]
+		}
+	}
+
+	if current == nil || (c.ParentId != nil && parent == nil) {
+		tx.Rollback()
+		return sql.ErrNoRows
+	}
+
+	path := current.Path
+	if !sameID(current.ParentId, c.ParentId) {
+		parentPath := "/"
+		if c.ParentId != nil {
+			if parent.ID == current.ID || current.IsAncestorOf(*parent) {
+				tx.Rollback()
+				return ErrCategoryCycle
+			}
+
+			parentPath = parent.Path
+		}
+
+		path = fmt.Sprintf("%s%d/", parentPath, c.ID)
+		if err = movePaths(tx, current.Path, path); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	_, err = tx.NamedExec(`UPDATE categories SET
+		name=:name,
+		slug=:slug,
+		parent_id=:parent_id
+		WHERE id=:id`, c)
+
+	if isDuplicateEntry(err) {
+		tx.Rollback()
+		return ErrCategoryTaken
+	} else if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	after := *c
+	after.Path = path
+	if err = audit.record(tx, &after); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	c.Path = path
+	return nil
+}
+
+// Delete deletes a single category. Its posts and child categories move up to
+// its parent, or become uncategorized and top level when it has none.
+func (s *CategoryStorage) Delete(id string, audit Audit) error {
+	_, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return fmt.Errorf("Category id must be integer: %s", id)
+	}
+
+	tx, err := s.DB.Beginx()
+	if err != nil {
+		return err
+	}
+
+	var c model.Category
+	err = tx.Get(&c, "SELECT * FROM categories WHERE id=? FOR UPDATE", id)
+
+	if err == nil {
+		_, err = tx.Exec("UPDATE posts SET category_id=?, version=version + 1 WHERE category_id=?", c.ParentId, c.ID)
+	}
+
+	if err == nil {
+		_, err = tx.Exec("UPDATE categories SET parent_id=? WHERE parent_id=?", c.ParentId, c.ID)
+	}
+
+	if err == nil {
+		err = movePaths(tx, c.Path, c.ParentPath())
+	}
+
+	if err == nil {
+		_, err = tx.Exec("DELETE FROM categories WHERE id=? LIMIT 1", c.ID)
+	}
+
+	if err == nil {
+		err = audit.record(tx, nil)
+	}
+
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// movePaths rewrites the paths of the category at path from and all of its
+// descendants to start with to instead
+func movePaths(tx *sqlx.Tx, from, to string) error {
+	var longest int
+	err := tx.Get(&longest, "SELECT COALESCE(MAX(LENGTH(path)), 0) FROM categories WHERE path LIKE ?", from+"%")
+	if err != nil {
+		return err
+	}
+
+	if longest-len(from)+len(to) > maxCategoryPathLength {
+		return ErrCategoryTooDeep
+	}
+
+	_, err = tx.Exec("UPDATE categories SET path=CONCAT(?, SUBSTRING(path, ?)) WHERE path LIKE ?", to, len(from)+1, from+"%")
+	return err
+}
+
+// sameID reports whether two optional ids are the same
+func sameID(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	return *a == *b
+}
@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/timrourke/timrourke.com/model"
+	"time"
+)
+
+// maxJobErrorLength is the longest error message kept for a job run
+const maxJobErrorLength = 2000
+
+// NewJobRunStorage returns a new instance of JobRunStorage
+func NewJobRunStorage(DB *sqlx.DB) *JobRunStorage {
+	return &JobRunStorage{DB}
+}
+
+// JobRunStorage forms SQL queries for the record of background job runs
+type JobRunStorage struct {
+	DB *sqlx.DB
+}
+
+// GetOne selects the record of a single job
+func (s *JobRunStorage) GetOne(name string) (*model.JobRun, error) {
+	var run model.JobRun
+
+	err := s.DB.Get(&run, "SELECT * FROM job_runs WHERE name=?", name)
+
+	return &run, err
+}
+
+// Start records that a job started a run
+func (s *JobRunStorage) Start(name string, now time.Time) error {
+	_, err := s.DB.Exec(`INSERT INTO job_runs (name, started_at, runs)
+		VALUES (?, ?, 1)
+		ON DUPLICATE KEY UPDATE started_at=VALUES(started_at), runs=runs + 1`, name, now.UTC())
+
+	return err
+}
+
+// Finish records that a job finished a run, how many items it handled, and
+// the error it failed with, if any
+func (s *JobRunStorage) Finish(name string, now time.Time, items int, runErr error) error {
+	if runErr != nil {
+		message := runErr.Error()
+		if len(message) > maxJobErrorLength {
+			message = message[:maxJobErrorLength]
+		}
+
+		_, err := s.DB.Exec(`UPDATE job_runs SET finished_at=?, items=?, last_error=?
+			WHERE name=?`, now.UTC(), items, message, name)
+
+		return err
+	}
+
+	_, err := s.DB.Exec(`UPDATE job_runs SET finished_at=?, succeeded_at=?, items=?, last_error=''
+		WHERE name=?`, now.UTC(), now.UTC(), items, name)
+
+	return err
+}
@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/timrourke/timrourke.com/model"
+)
+
+// NewLockoutEventStorage returns a new instance of LockoutEventStorage
+func NewLockoutEventStorage(DB *sqlx.DB) *LockoutEventStorage {
+	return &LockoutEventStorage{DB}
+}
+
+// LockoutEventStorage forms SQL queries for the history of lockouts
+type LockoutEventStorage struct {
+	DB *sqlx.DB
+}
+
+// Insert records a single lockout event
+func (s *LockoutEventStorage) Insert(c model.LockoutEvent) error {
+	return insertLockoutEvent(s.DB, c)
+}
+
+// insertLockoutEvent records a single lockout event, in a transaction or not
+func insertLockoutEvent(e sqlx.Ext, c model.LockoutEvent) error {
+	_, err := sqlx.NamedExec(e, `INSERT INTO lockout_events (
+		event,
+		user_id,
+		client_ip,
+		locked_until,
+		actor_id
+	) VALUES (
+		:event,
+		:user_id,
+		:client_ip,
+		:locked_until,
+		:actor_id
+	)`, &c)
+
+	return err
+}
@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"database/sql"
+	"github.com/jmoiron/sqlx"
+	"github.com/timrourke/timrourke.com/model"
+	"time"
+)
+
+// NewLoginThrottleStorage returns a new instance of LoginThrottleStorage
+func NewLoginThrottleStorage(DB *sqlx.DB) *LoginThrottleStorage {
+	return &LoginThrottleStorage{DB}
+}
+
+// LoginThrottleStorage forms SQL queries for failed logins per client IP
+type LoginThrottleStorage struct {
+	DB *sqlx.DB
+}
+
+// Get selects the failed logins of a client IP. A client without any failures
+// gets an empty throttle.
+func (s *LoginThrottleStorage) Get(clientIP string) (*model.LoginThrottle, error) {
+	throttle := model.LoginThrottle{ClientIP: clientIP}
+
+	err := s.DB.Get(&throttle, "SELECT * FROM login_throttles WHERE client_ip=?", clientIP)
+	if err == sql.ErrNoRows {
+		return &throttle, nil
+	}
+
+	return &throttle, err
+}
+
+// RecordFailure counts a failed login from a client IP, starting the count
+// again when the last failure was before windowStart. It returns the new
+// number of failures.
+func (s *LoginThrottleStorage) RecordFailure(clientIP string, now, windowStart time.Time) (int, error) {
+	var failures int
+
+	_, err := s.DB.Exec(`INSERT INTO login_throttles (client_ip, failures, last_failed_at)
+		VALUES (?, 1, ?)
+		ON DUPLICATE KEY UPDATE
+		failures=IF(last_failed_at IS NULL OR last_failed_at < ?, 1, failures + 1),
+		last_failed_at=VALUES(last_failed_at)`, clientIP, now, windowStart)
+	if err != nil {
+		return 0, err
+	}
+
+	err = s.DB.Get(&failures, "SELECT failures FROM login_throttles WHERE client_ip=?", clientIP)
+
+	return failures, err
+}
+
+// Lock locks a client IP out until the given time
+func (s *LoginThrottleStorage) Lock(clientIP string, until time.Time) error {
+	_, err := s.DB.Exec(`UPDATE login_throttles SET
+		failures=0,
+		lockouts=lockouts + 1,
+		locked_until=?
+		WHERE client_ip=?`, until, clientIP)
+
+	return err
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"database/sql"
+	"github.com/jmoiron/sqlx"
+	"github.com/timrourke/timrourke.com/model"
+)
+
+// NewPasswordResetStorage returns a new instance of PasswordResetStorage
+func NewPasswordResetStorage(DB *sqlx.DB) *PasswordResetStorage {
+	return &PasswordResetStorage{DB}
+}
+
+// PasswordResetStorage forms SQL queries for password resets
+type PasswordResetStorage struct {
+	DB *sqlx.DB
+}
+
+// GetUsable selects the unused, unexpired password reset with the given token
+// hash
+func (s *PasswordResetStorage) GetUsable(tokenHash string) (*model.PasswordReset, error) {
+	var reset model.PasswordReset
+
+	err := s.DB.Get(&reset, `SELECT * FROM password_resets
+		WHERE token_hash=?
+		AND used_at IS NULL
+		AND expires_at > UTC_TIMESTAMP()`, tokenHash)
+
+	return &reset, err
+}
+
+// Insert inserts a single password reset
+func (s *PasswordResetStorage) Insert(c model.PasswordReset) error {
+	_, err := s.DB.NamedExec(`INSERT INTO password_resets (
+		expires_at,
+		user_id,
+		token_hash
+	) VALUES (
+		:expires_at,
+		:user_id,
+		:token_hash
+	)`, &c)
+
+	return err
+}
+
+// Use marks a password reset as used, and every other outstanding reset for
+// the same user with it. It returns sql.ErrNoRows when the reset had already
+// been used, so a token cannot be redeemed twice by concurrent requests.
+func (s *PasswordResetStorage) Use(c *model.PasswordReset) error {
+	result, err := s.DB.Exec(`UPDATE password_resets SET used_at=UTC_TIMESTAMP()
+		WHERE id=? AND used_at IS NULL`, c.ID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	} else if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	_, err = s.DB.Exec(`UPDATE password_resets SET used_at=UTC_TIMESTAMP()
+		WHERE user_id=? AND used_at IS NULL`, c.UserId)
+
+	return err
+}
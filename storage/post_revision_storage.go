@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/timrourke/timrourke.com/model"
+	"github.com/timrourke/timrourke.com/query"
+)
+
+// NewPostRevisionStorage returns a new instance of PostRevisionStorage
+func NewPostRevisionStorage(DB *sqlx.DB) *PostRevisionStorage {
+	return &PostRevisionStorage{DB}
+}
+
+// PostRevisionStorage forms SQL queries for post revisions. Revisions are
+// saved by PostStorage.Update, so they can only be read here.
+type PostRevisionStorage struct {
+	DB *sqlx.DB
+}
+
+// GetAll selects a list of post revisions
+func (s *PostRevisionStorage) GetAll(q *query.Query) (uint, []model.PostRevision, error) {
+	var (
+		revisions []model.PostRevision
+		count     uint
+	)
+
+	q.Select("post_revisions.*").From("post_revisions post_revisions")
+
+	sql, boundValues := q.Compile()
+
+	rows, err := s.DB.NamedQuery(sql, boundValues)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var m model.PostRevision
+		err = rows.StructScan(&m)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		revisions = append(revisions, m)
+	}
+
+	// Get count of all matching revisions for pagination
+	count, errCount := countAll(s.DB, q)
+
+	if err != nil {
+		return 0, nil, err
+	} else if errCount != nil {
+		return 0, nil, errCount
+	}
+
+	return count, revisions, nil
+}
+
+// GetOne selects a single post revision
+func (s *PostRevisionStorage) GetOne(ID string) (*model.PostRevision, error) {
+	var revision model.PostRevision
+
+	err := s.DB.Get(&revision, "SELECT * FROM post_revisions WHERE id=?", ID)
+
+	return &revision, err
+}
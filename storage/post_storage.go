@@ -5,7 +5,9 @@ import (
 	"github.com/jmoiron/sqlx"
 	"github.com/timrourke/timrourke.com/model"
 	"github.com/timrourke/timrourke.com/query"
+	"github.com/timrourke/timrourke.com/slug"
 	"strconv"
+	"time"
 )
 
 // NewPostStorage returns a new instance of PostStorage
@@ -18,8 +20,20 @@ type PostStorage struct {
 	DB *sqlx.DB
 }
 
-// GetAll selects a list of posts
+// GetAll selects a list of posts, leaving out those in the trash
 func (s *PostStorage) GetAll(q *query.Query) (uint, []model.Post, error) {
+	q.Where("posts.deleted_at IS NULL")
+	return s.getAll(q)
+}
+
+// GetTrash selects a list of the posts in the trash
+func (s *PostStorage) GetTrash(q *query.Query) (uint, []model.Post, error) {
+	q.Where("posts.deleted_at IS NOT NULL")
+	return s.getAll(q)
+}
+
+// getAll selects a list of posts matching a query
+func (s *PostStorage) getAll(q *query.Query) (uint, []model.Post, error) {
 	var (
 		posts []model.Post
 		count uint
@@ -45,8 +59,8 @@ func (s *PostStorage) GetAll(q *query.Query) (uint, []model.Post, error) {
 		posts = append(posts, m)
 	}
 
-	// Get count of all posts for pagination
-	errCount := s.DB.Get(&count, "SELECT COUNT(*) FROM posts")
+	// Get count of all matching posts for pagination
+	count, errCount := countAll(s.DB, q)
 
 	if err != nil {
 		return 0, nil, err
@@ -54,41 +68,413 @@ func (s *PostStorage) GetAll(q *query.Query) (uint, []model.Post, error) {
 		return 0, nil, errCount
 	}
 
+	if err = s.loadRelations(posts); err != nil {
+		return 0, nil, err
+	}
+
 	return count, posts, nil
 }
 
-// GetOne selects a single post
+// GetOne selects a single post, unless it is in the trash
 func (s *PostStorage) GetOne(ID string) (*model.Post, error) {
+	return s.getOne("SELECT * FROM posts WHERE id=? AND deleted_at IS NULL", ID)
+}
+
+// GetTrashed selects a single post in the trash
+func (s *PostStorage) GetTrashed(ID string) (*model.Post, error) {
+	return s.getOne("SELECT * FROM posts WHERE id=? AND deleted_at IS NOT NULL", ID)
+}
+
+// getOne selects a single post by a query taking its id
+func (s *PostStorage) getOne(statement, ID string) (*model.Post, error) {
+	var post model.Post
+
+	err := s.DB.Get(&post, statement, ID)
+	if err != nil {
+		return &post, err
+	}
+
+	posts := []model.Post{post}
+	err = s.loadRelations(posts)
+
+	return &posts[0], err
+}
+
+// loadRelations fills in the authors, tags and series of a list of posts
+func (s *PostStorage) loadRelations(posts []model.Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	postIDs := make([]int64, len(posts))
+	byID := map[string]*model.Post{}
+	for i := range posts {
+		posts[i].AuthorIDs = []string{}
+		posts[i].TagIDs = []string{}
+		posts[i].SeriesId = nil
+		postIDs[i] = posts[i].ID
+		byID[posts[i].GetID()] = &posts[i]
+	}
+
+	if err := s.loadAuthorIDs(postIDs, byID); err != nil {
+		return err
+	}
+
+	if err := s.loadTagIDs(postIDs, byID); err != nil {
+		return err
+	}
+
+	return s.loadSeriesIDs(postIDs, byID)
+}
+
+// loadAuthorIDs fills in the authors of posts by their ids in byline order,
+// leaving out co-authors in the trash
+func (s *PostStorage) loadAuthorIDs(postIDs []int64, byID map[string]*model.Post) error {
+	sql, args, err := sqlx.In(`SELECT CAST(post_authors.post_id AS CHAR) AS post_id, CAST(post_authors.user_id AS CHAR) AS user_id
+		FROM post_authors JOIN users ON users.id = post_authors.user_id
+		WHERE post_authors.post_id IN (?) AND users.deleted_at IS NULL
+		ORDER BY post_authors.post_id, post_authors.position, post_authors.user_id`, postIDs)
+	if err != nil {
+		return err
+	}
+
+	var postAuthors []struct {
+		PostId string `db:"post_id"`
+		UserId string `db:"user_id"`
+	}
+
+	if err = s.DB.Select(&postAuthors, s.DB.Rebind(sql), args...); err != nil {
+		return err
+	}
+
+	for _, postAuthor := range postAuthors {
+		if post, ok := byID[postAuthor.PostId]; ok {
+			post.AuthorIDs = append(post.AuthorIDs, postAuthor.UserId)
+		}
+	}
+
+	for _, post := range byID {
+		post.AuthorIDs = post.Byline()
+	}
+
+	return nil
+}
+
+// loadTagIDs fills in the tags of posts by their ids
+func (s *PostStorage) loadTagIDs(postIDs []int64, byID map[string]*model.Post) error {
+	sql, args, err := sqlx.In(`SELECT CAST(post_id AS CHAR) AS post_id, CAST(tag_id AS CHAR) AS tag_id
+		FROM posts_tags WHERE post_id IN (?) ORDER BY tag_id`, postIDs)
+	if err != nil {
+		return err
+	}
+
+	var postTags []struct {
+		PostId string `db:"post_id"`
+		TagId  string `db:"tag_id"`
+	}
+
+	if err = s.DB.Select(&postTags, s.DB.Rebind(sql), args...); err != nil {
+		return err
+	}
+
+	for _, postTag := range postTags {
+		if post, ok := byID[postTag.PostId]; ok {
+			post.TagIDs = append(post.TagIDs, postTag.TagId)
+		}
+	}
+
+	return nil
+}
+
+// loadSeriesIDs fills in the series of posts by their ids
+func (s *PostStorage) loadSeriesIDs(postIDs []int64, byID map[string]*model.Post) error {
+	sql, args, err := sqlx.In(`SELECT CAST(post_id AS CHAR) AS post_id, CAST(series_id AS CHAR) AS series_id
+		FROM series_posts WHERE post_id IN (?)`, postIDs)
+	if err != nil {
+		return err
+	}
+
+	var seriesPosts []struct {
+		PostId   string `db:"post_id"`
+		SeriesId string `db:"series_id"`
+	}
+
+	if err = s.DB.Select(&seriesPosts, s.DB.Rebind(sql), args...); err != nil {
+		return err
+	}
+
+	for i := range seriesPosts {
+		if post, ok := byID[seriesPosts[i].PostId]; ok {
+			post.SeriesId = &seriesPosts[i].SeriesId
+		}
+	}
+
+	return nil
+}
+
+// saveAuthorIDs replaces the authors of a post in byline order, always
+// including its owner. Co-authors in the trash keep their place, so they are
+// still on the byline if they are restored.
+func saveAuthorIDs(tx *sqlx.Tx, c *model.Post) error {
+	c.AuthorIDs = c.Byline()
+
+	_, err := tx.Exec(`DELETE FROM post_authors WHERE post_id=?
+		AND user_id NOT IN (SELECT users.id FROM users WHERE users.deleted_at IS NOT NULL)`, c.ID)
+	if err != nil {
+		return err
+	}
+
+	for i, authorID := range c.AuthorIDs {
+		_, err := tx.Exec(`INSERT INTO post_authors (post_id, user_id, position) VALUES (?, ?, ?)
+			ON DUPLICATE KEY UPDATE position=VALUES(position)`,
+			c.ID, authorID, i+1)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// saveTagIDs replaces the tags of a post
+func saveTagIDs(tx *sqlx.Tx, c *model.Post) error {
+	if _, err := tx.Exec("DELETE FROM posts_tags WHERE post_id=?", c.ID); err != nil {
+		return err
+	}
+
+	for _, tagID := range c.TagIDs {
+		if _, err := tx.Exec("INSERT INTO posts_tags (post_id, tag_id) VALUES (?, ?)", c.ID, tagID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// saveSeriesID moves a post to the end of its series when it has joined a new
+// one, or takes it out of the series it was in. A post staying in the same
+// series keeps its place.
+func saveSeriesID(tx *sqlx.Tx, c *model.Post) error {
+	current := []string{}
+	err := tx.Select(&current, "SELECT CAST(series_id AS CHAR) FROM series_posts WHERE post_id=?", c.ID)
+	if err != nil {
+		return err
+	}
+
+	if c.SeriesId != nil && len(current) > 0 && current[0] == *c.SeriesId {
+		return nil
+	}
+
+	if _, err = tx.Exec("DELETE FROM series_posts WHERE post_id=?", c.ID); err != nil || c.SeriesId == nil {
+		return err
+	}
+
+	// Lock the series so that posts joining it at once get their own places
+	var seriesID int64
+	if err = tx.Get(&seriesID, "SELECT id FROM series WHERE id=? FOR UPDATE", *c.SeriesId); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT INTO series_posts (post_id, series_id, position)
+		SELECT ?, ?, COALESCE(MAX(position), 0) + 1 FROM series_posts WHERE series_id=?`,
+		c.ID, seriesID, seriesID)
+
+	return err
+}
+
+// Missing returns the ids in a list that belong to no post, or to posts in
+// the trash
+func (s *PostStorage) Missing(IDs []string) ([]string, error) {
+	missing := []string{}
+	if len(IDs) == 0 {
+		return missing, nil
+	}
+
+	found := []string{}
+	sql, args, err := sqlx.In("SELECT CAST(id AS CHAR) FROM posts WHERE id IN (?) AND deleted_at IS NULL", IDs)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.DB.Select(&found, s.DB.Rebind(sql), args...); err != nil {
+		return nil, err
+	}
+
+	for _, ID := range IDs {
+		if !containsString(found, ID) {
+			missing = append(missing, ID)
+		}
+	}
+
+	return missing, nil
+}
+
+// GetByPermalink selects the post with a permalink
+func (s *PostStorage) GetByPermalink(permalink string) (*model.Post, error) {
 	var post model.Post
 
-	err := s.DB.Get(&post, "SELECT * FROM posts WHERE id=?", ID)
+	err := s.DB.Get(&post, "SELECT * FROM posts WHERE permalink=? AND deleted_at IS NULL", permalink)
 
 	return &post, err
 }
 
-// Insert inserts a single post
-func (s *PostStorage) Insert(c model.Post) (*model.Post, error) {
-	result, err := s.DB.NamedExec(`INSERT INTO posts (
+// GetByOldPermalink selects the post that used to have a permalink, so links
+// to it can be redirected
+func (s *PostStorage) GetByOldPermalink(permalink string) (*model.Post, error) {
+	var post model.Post
+
+	err := s.DB.Get(&post, `SELECT posts.* FROM posts
+		JOIN post_permalinks ON post_permalinks.post_id = posts.id
+		WHERE post_permalinks.permalink=? AND posts.deleted_at IS NULL`, permalink)
+
+	return &post, err
+}
+
+// UniquePermalink turns text into a permalink that no other post has or used
+// to have, numbering it when the plain slug is taken. Posts in the trash keep
+// their permalinks, so they can be restored. postID is the post the permalink
+// is for, or 0 for a new post.
+func (s *PostStorage) UniquePermalink(text string, postID int64) (string, error) {
+	base := slug.Make(text)
+	taken := map[string]bool{}
+	rows := []string{}
+
+	err := s.DB.Select(&rows, `SELECT permalink FROM posts
+		WHERE id <> ? AND (permalink = ? OR permalink LIKE ?)
+		UNION SELECT permalink FROM post_permalinks
+		WHERE post_id <> ? AND (permalink = ? OR permalink LIKE ?)`,
+		postID, base, base+"-%",
+		postID, base, base+"-%")
+	if err != nil {
+		return "", err
+	}
+
+	for _, permalink := range rows {
+		taken[permalink] = true
+	}
+
+	permalink := base
+	for n := 2; taken[permalink]; n++ {
+		permalink = fmt.Sprintf("%s-%d", base, n)
+	}
+
+	return permalink, nil
+}
+
+// GetPublishedByUser selects the public posts a user wrote or co-wrote, newest
+// first
+func (s *PostStorage) GetPublishedByUser(userID string) ([]model.Post, error) {
+	posts := []model.Post{}
+
+	err := s.DB.Select(&posts, `SELECT * FROM posts
+		WHERE (user_id=? OR id IN (SELECT post_authors.post_id FROM post_authors WHERE post_authors.user_id=?))
+		AND status=? AND published_at <= UTC_TIMESTAMP() AND deleted_at IS NULL
+		ORDER BY published_at DESC, id DESC`, userID, userID, model.PostStatusPublished)
+
+	return posts, err
+}
+
+// GetDueScheduled selects the scheduled posts whose publish time has passed,
+// leaving those in the trash to wait until they are restored
+func (s *PostStorage) GetDueScheduled(now time.Time) ([]model.Post, error) {
+	posts := []model.Post{}
+
+	err := s.DB.Select(&posts, `SELECT * FROM posts
+		WHERE status=? AND published_at <= ? AND deleted_at IS NULL
+		ORDER BY published_at ASC, id ASC`, model.PostStatusScheduled, now.UTC())
+
+	return posts, err
+}
+
+// PublishScheduled publishes a post if it is still scheduled and due. It
+// reports whether the post was published, so running it twice is harmless.
+func (s *PostStorage) PublishScheduled(c *model.Post, now time.Time, audit Audit) (bool, error) {
+	published := *c
+	published.Status = model.PostStatusPublished
+	published.Version++
+
+	changed := false
+	err := transact(s.DB, func(tx *sqlx.Tx) error {
+		result, err := tx.Exec(`UPDATE posts SET status=?, version=version + 1
+			WHERE id=? AND status=? AND published_at <= ? AND deleted_at IS NULL`,
+			model.PostStatusPublished,
+			c.ID,
+			model.PostStatusScheduled,
+			now.UTC())
+		if err != nil {
+			return err
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil || affected == 0 {
+			return err
+		}
+
+		changed = true
+		return audit.record(tx, &published)
+	})
+	if err != nil || !changed {
+		return false, err
+	}
+
+	*c = published
+	return true, nil
+}
+
+// Insert inserts a single post, its authors, its tags and its place in a
+// series, caching the HTML its content renders to
+func (s *PostStorage) Insert(c model.Post, audit Audit) (*model.Post, error) {
+	tx, err := s.DB.Beginx()
+	if err != nil {
+		return &model.Post{}, err
+	}
+
+	c.RenderContent()
+
+	result, err := tx.NamedExec(`INSERT INTO posts (
 		title,
 		excerpt,
+		excerpt_generated,
 		content,
+		content_format,
+		content_delta,
+		content_html,
+		word_count,
+		reading_time,
 		permalink,
-		user_id
+		status,
+		published_at,
+		user_id,
+		category_id
 	) VALUES (
 		:title,
 		:excerpt,
+		:excerpt_generated,
 		:content,
+		:content_format,
+		:content_delta,
+		:content_html,
+		:word_count,
+		:reading_time,
 		:permalink,
-		14
+		:status,
+		:published_at,
+		:user_id,
+		:category_id
 	)`, &c)
 
-	if err != nil {
+	if isDuplicateEntry(err) {
+		tx.Rollback()
+		return &model.Post{}, ErrPermalinkTaken
+	} else if err != nil {
+		tx.Rollback()
 		fmt.Println("insert error", err)
 		return &model.Post{}, err
 	}
 
 	insertID, err := result.LastInsertId()
 	if err != nil {
+		tx.Rollback()
 		fmt.Println("insert error last insert id", err)
 		return &model.Post{}, err
 	}
@@ -96,35 +482,262 @@ func (s *PostStorage) Insert(c model.Post) (*model.Post, error) {
 	// Set ID on return struct for rendering to json
 	c.SetID(fmt.Sprintf("%d", insertID))
 
+	if err = saveAuthorIDs(tx, &c); err == nil {
+		err = saveTagIDs(tx, &c)
+	}
+
+	if err == nil {
+		err = saveSeriesID(tx, &c)
+	}
+
+	if err == nil {
+		err = audit.record(tx, &c)
+	}
+
+	if err != nil {
+		tx.Rollback()
+		return &model.Post{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return &model.Post{}, err
+	}
+
 	return s.GetOne(c.GetID())
 }
 
-// Delete deletes a single post
-func (s *PostStorage) Delete(id string) error {
+// Delete moves a single post to the trash. It returns sql.ErrNoRows when the
+// post is already in the trash.
+func (s *PostStorage) Delete(id string, audit Audit) error {
 	_, err := strconv.ParseInt(id, 10, 64)
 	if err != nil {
 		return fmt.Errorf("Post id must be integer: %s", id)
 	}
 
-	_, err = s.DB.Exec("DELETE FROM posts WHERE id=? LIMIT 1", id)
+	return transact(s.DB, func(tx *sqlx.Tx) error {
+		result, err := tx.Exec(`UPDATE posts SET deleted_at=UTC_TIMESTAMP(), version=version + 1
+			WHERE id=? AND deleted_at IS NULL LIMIT 1`, id)
+
+		if err == nil {
+			err = checkAffected(result)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		return audit.record(tx, nil)
+	})
+}
+
+// Restore takes a single post out of the trash. It returns sql.ErrNoRows when
+// the post is no longer in the trash.
+func (s *PostStorage) Restore(c *model.Post, audit Audit) error {
+	restored := *c
+	restored.DeletedAt = nil
+	restored.Version++
+
+	err := transact(s.DB, func(tx *sqlx.Tx) error {
+		result, err := tx.Exec(`UPDATE posts SET deleted_at=NULL, version=version + 1
+			WHERE id=? AND deleted_at IS NOT NULL`, c.ID)
+
+		if err == nil {
+			err = checkAffected(result)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		return audit.record(tx, &restored)
+	})
 	if err != nil {
 		return err
 	}
+
+	*c = restored
 	return nil
 }
 
-// Update updates a single post
-func (s *PostStorage) Update(c *model.Post) error {
-	_, err := s.DB.NamedExec(`UPDATE posts SET 
+// GetExpiredTrash selects the posts that went in the trash before a time
+func (s *PostStorage) GetExpiredTrash(before time.Time) ([]model.Post, error) {
+	posts := []model.Post{}
+
+	err := s.DB.Select(&posts, `SELECT * FROM posts
+		WHERE deleted_at < ?
+		ORDER BY deleted_at ASC, id ASC`, before.UTC())
+
+	return posts, err
+}
+
+// Purge deletes a post for good if it is still in the trash and went there
+// before a time. It reports whether the post was deleted.
+func (s *PostStorage) Purge(c *model.Post, before time.Time, audit Audit) (bool, error) {
+	purged := false
+
+	err := transact(s.DB, func(tx *sqlx.Tx) error {
+		result, err := tx.Exec("DELETE FROM posts WHERE id=? AND deleted_at < ?", c.ID, before.UTC())
+		if err != nil {
+			return err
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil || affected == 0 {
+			return err
+		}
+
+		purged = true
+		return audit.record(tx, nil)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return purged, nil
+}
+
+// GetUnrendered selects the posts whose HTML has not been cached, which are
+// those saved before it was, trashed or not
+func (s *PostStorage) GetUnrendered() ([]model.Post, error) {
+	posts := []model.Post{}
+
+	err := s.DB.Select(&posts, `SELECT * FROM posts
+		WHERE content_html IS NULL
+		ORDER BY id ASC`)
+
+	return posts, err
+}
+
+// SaveRendered saves the cached HTML, word count, reading time and excerpt of
+// a post whose HTML had not been cached. It reports whether the post was
+// saved, leaving posts cached since alone. Nobody changed the post, so its
+// version is kept and no revision is saved.
+func (s *PostStorage) SaveRendered(c *model.Post) (bool, error) {
+	result, err := s.DB.NamedExec(`UPDATE posts SET
+		excerpt=:excerpt,
+		excerpt_generated=:excerpt_generated,
+		content_html=:content_html,
+		word_count=:word_count,
+		reading_time=:reading_time
+		WHERE id=:id AND content_html IS NULL`, c)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+// Update updates a single post, its tags and its series, first saving its
+// previous title, excerpt, content and permalink as a revision made by
+// editorID. An empty editorID records a change nobody in particular made. A
+// replaced permalink is kept so links to it can be redirected. It returns
+// ErrStaleVersion, changing nothing, when the post is no longer at the
+// version being updated. The HTML the post's content renders to is cached
+// again.
+func (s *PostStorage) Update(c *model.Post, editorID string, audit Audit) error {
+	var editor *string
+	if len(editorID) > 0 {
+		editor = &editorID
+	}
+
+	tx, err := s.DB.Beginx()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT INTO post_revisions (
+		post_id,
+		editor_id,
+		title,
+		excerpt,
+		content,
+		content_format,
+		content_delta,
+		permalink
+	) SELECT
+		id,
+		?,
+		COALESCE(title, ''),
+		COALESCE(excerpt, ''),
+		COALESCE(content, ''),
+		content_format,
+		content_delta,
+		COALESCE(permalink, '')
+	FROM posts WHERE id=?`, editor, c.ID)
+
+	if err == nil {
+		_, err = tx.Exec("DELETE FROM post_permalinks WHERE permalink=?", c.Permalink)
+	}
+
+	if err == nil {
+		_, err = tx.Exec(`INSERT INTO post_permalinks (permalink, post_id)
+			SELECT permalink, id FROM posts WHERE id=? AND permalink <> ?
+			ON DUPLICATE KEY UPDATE post_id=VALUES(post_id)`, c.ID, c.Permalink)
+	}
+
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	c.RenderContent()
+
+	result, err := tx.NamedExec(`UPDATE posts SET 
 		title=:title,
 		excerpt=:excerpt,
+		excerpt_generated=:excerpt_generated,
 		content=:content,
-		permalink=:permalink
-		WHERE id=:id`, &c)
+		content_format=:content_format,
+		content_delta=:content_delta,
+		content_html=:content_html,
+		word_count=:word_count,
+		reading_time=:reading_time,
+		permalink=:permalink,
+		status=:status,
+		published_at=:published_at,
+		user_id=:user_id,
+		category_id=:category_id,
+		version=version + 1
+		WHERE id=:id AND version=:version`, &c)
+
+	if isDuplicateEntry(err) {
+		err = ErrPermalinkTaken
+	} else if err == nil {
+		err = checkVersioned(result)
+	}
+
+	if err == nil {
+		err = saveAuthorIDs(tx, c)
+	}
+
+	if err == nil {
+		err = saveTagIDs(tx, c)
+	}
+
+	if err == nil {
+		err = saveSeriesID(tx, c)
+	}
+
+	if err == nil {
+		after := *c
+		after.Version++
+		err = audit.record(tx, &after)
+	}
 
 	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
 		return err
 	}
 
+	c.Version++
 	return nil
 }
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"database/sql"
+	"github.com/jmoiron/sqlx"
+)
+
+// NewRecoveryCodeStorage returns a new instance of RecoveryCodeStorage
+func NewRecoveryCodeStorage(DB *sqlx.DB) *RecoveryCodeStorage {
+	return &RecoveryCodeStorage{DB}
+}
+
+// RecoveryCodeStorage forms SQL queries for two-factor recovery codes
+type RecoveryCodeStorage struct {
+	DB *sqlx.DB
+}
+
+// Replace replaces all of a user's recovery codes with new ones
+func (s *RecoveryCodeStorage) Replace(userID string, codeHashes []string) error {
+	tx, err := s.DB.Beginx()
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec("DELETE FROM recovery_codes WHERE user_id=?", userID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for _, codeHash := range codeHashes {
+		_, err = tx.Exec("INSERT INTO recovery_codes (user_id, code_hash) VALUES (?, ?)",
+			userID,
+			codeHash)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Use marks one of a user's unused recovery codes as used. It returns
+// sql.ErrNoRows when no unused code matches.
+func (s *RecoveryCodeStorage) Use(userID string, codeHash string) error {
+	result, err := s.DB.Exec(`UPDATE recovery_codes SET used_at=UTC_TIMESTAMP()
+		WHERE user_id=? AND code_hash=? AND used_at IS NULL
+		LIMIT 1`, userID, codeHash)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	} else if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// DeleteForUser deletes all of a user's recovery codes
+func (s *RecoveryCodeStorage) DeleteForUser(userID string) error {
+	_, err := s.DB.Exec("DELETE FROM recovery_codes WHERE user_id=?", userID)
+	return err
+}
@@ -0,0 +1,284 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/timrourke/timrourke.com/model"
+	"github.com/timrourke/timrourke.com/query"
+	"strconv"
+)
+
+// NewSeriesStorage returns a new instance of SeriesStorage
+func NewSeriesStorage(DB *sqlx.DB) *SeriesStorage {
+	return &SeriesStorage{DB}
+}
+
+// SeriesStorage forms SQL queries for series and the order of their posts
+type SeriesStorage struct {
+	DB *sqlx.DB
+}
+
+// GetAll selects a list of series. Unless publicOnly is false, only posts
+// anyone may read are listed in each series.
+func (s *SeriesStorage) GetAll(q *query.Query, publicOnly bool) (uint, []model.Series, error) {
+	var (
+		series []model.Series
+		count  uint
+	)
+
+	q.Select("series.*").From("series series")
+
+	sql, boundValues := q.Compile()
+
+	rows, err := s.DB.NamedQuery(sql, boundValues)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var m model.Series
+		err = rows.StructScan(&m)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		series = append(series, m)
+	}
+
+	// Get count of all matching series for pagination
+	count, errCount := countAll(s.DB, q)
+
+	if err != nil {
+		return 0, nil, err
+	} else if errCount != nil {
+		return 0, nil, errCount
+	}
+
+	if err = s.loadPostIDs(series, publicOnly); err != nil {
+		return 0, nil, err
+	}
+
+	return count, series, nil
+}
+
+// GetOne selects a single series. Unless publicOnly is false, only posts
+// anyone may read are listed.
+func (s *SeriesStorage) GetOne(ID string, publicOnly bool) (*model.Series, error) {
+	var one model.Series
+
+	err := s.DB.Get(&one, "SELECT * FROM series WHERE id=?", ID)
+	if err != nil {
+		return &one, err
+	}
+
+	series := []model.Series{one}
+	err = s.loadPostIDs(series, publicOnly)
+
+	return &series[0], err
+}
+
+// loadPostIDs fills in the posts of a list of series in reading order, leaving
+// out posts in the trash
+func (s *SeriesStorage) loadPostIDs(series []model.Series, publicOnly bool) error {
+	if len(series) == 0 {
+		return nil
+	}
+
+	seriesIDs := make([]int64, len(series))
+	byID := map[string]*model.Series{}
+	for i := range series {
+		series[i].PostIDs = []string{}
+		seriesIDs[i] = series[i].ID
+		byID[series[i].GetID()] = &series[i]
+	}
+
+	sql, args, err := sqlx.In(`SELECT CAST(series_posts.series_id AS CHAR) AS series_id, CAST(series_posts.post_id AS CHAR) AS post_id
+		FROM series_posts JOIN posts ON posts.id = series_posts.post_id
+		WHERE series_posts.series_id IN (?) AND posts.deleted_at IS NULL AND (? = 0 OR (posts.status = ? AND posts.published_at <= UTC_TIMESTAMP()))
+		ORDER BY series_posts.series_id, series_posts.position`,
+		seriesIDs, publicOnly, model.PostStatusPublished)
+	if err != nil {
+		return err
+	}
+
+	var seriesPosts []struct {
+		SeriesId string `db:"series_id"`
+		PostId   string `db:"post_id"`
+	}
+
+	if err = s.DB.Select(&seriesPosts, s.DB.Rebind(sql), args...); err != nil {
+		return err
+	}
+
+	for _, seriesPost := range seriesPosts {
+		if one, ok := byID[seriesPost.SeriesId]; ok {
+			one.PostIDs = append(one.PostIDs, seriesPost.PostId)
+		}
+	}
+
+	return nil
+}
+
+// GetNeighbours selects the posts before and after a post in its series,
+// either of which is nil at the ends of the series or for a post in no
+// series. Unless publicOnly is false, posts nobody else may read are skipped.
+func (s *SeriesStorage) GetNeighbours(postID string, publicOnly bool) (*model.Post, *model.Post, error) {
+	previous, err := s.getNeighbour(postID, publicOnly, "<", "DESC")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	next, err := s.getNeighbour(postID, publicOnly, ">", "ASC")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return previous, next, nil
+}
+
+// getNeighbour selects the nearest post in the same series as a post whose
+// position compares to the post's by op
+func (s *SeriesStorage) getNeighbour(postID string, publicOnly bool, op, order string) (*model.Post, error) {
+	var post model.Post
+
+	err := s.DB.Get(&post, fmt.Sprintf(`SELECT posts.* FROM posts
+		JOIN series_posts neighbour ON neighbour.post_id = posts.id
+		JOIN series_posts own ON own.series_id = neighbour.series_id
+		WHERE own.post_id = ? AND neighbour.position %s own.position AND posts.deleted_at IS NULL
+		AND (? = 0 OR (posts.status = ? AND posts.published_at <= UTC_TIMESTAMP()))
+		ORDER BY neighbour.position %s LIMIT 1`, op, order),
+		postID, publicOnly, model.PostStatusPublished)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &post, nil
+}
+
+// Insert inserts a single series and its posts
+func (s *SeriesStorage) Insert(c model.Series, audit Audit) (*model.Series, error) {
+	tx, err := s.DB.Beginx()
+	if err != nil {
+		return &model.Series{}, err
+	}
+
+	result, err := tx.NamedExec(`INSERT INTO series (
+		title,
+		slug,
+		description
+	) VALUES (
+		:title,
+		:slug,
+		:description
+	)`, &c)
+
+	if isDuplicateEntry(err) {
+		tx.Rollback()
+		return &model.Series{}, ErrSeriesTaken
+	} else if err != nil {
+		tx.Rollback()
+		return &model.Series{}, err
+	}
+
+	insertID, err := result.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return &model.Series{}, err
+	}
+
+	// Set ID on return struct for rendering to json
+	c.SetID(fmt.Sprintf("%d", insertID))
+
+	if err = savePostIDs(tx, &c); err == nil {
+		err = audit.record(tx, &c)
+	}
+
+	if err != nil {
+		tx.Rollback()
+		return &model.Series{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return &model.Series{}, err
+	}
+
+	return s.GetOne(c.GetID(), false)
+}
+
+// Update updates a single series and replaces the order of its posts in one
+// go, so readers never see a half reordered series
+func (s *SeriesStorage) Update(c *model.Series, audit Audit) error {
+	tx, err := s.DB.Beginx()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.NamedExec(`UPDATE series SET
+		title=:title,
+		slug=:slug,
+		description=:description
+		WHERE id=:id`, c)
+
+	if isDuplicateEntry(err) {
+		err = ErrSeriesTaken
+	} else if err == nil {
+		err = savePostIDs(tx, c)
+	}
+
+	if err == nil {
+		err = audit.record(tx, c)
+	}
+
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// savePostIDs replaces the posts of a series, in order. Posts taken from
+// another series leave a gap there, which does not change its order.
+func savePostIDs(tx *sqlx.Tx, c *model.Series) error {
+	// Lock the series so that posts joining it at once get their own places
+	var seriesID int64
+	if err := tx.Get(&seriesID, "SELECT id FROM series WHERE id=? FOR UPDATE", c.ID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM series_posts WHERE series_id=?", c.ID); err != nil {
+		return err
+	}
+
+	for i, postID := range c.PostIDs {
+		_, err := tx.Exec(`INSERT INTO series_posts (post_id, series_id, position) VALUES (?, ?, ?)
+			ON DUPLICATE KEY UPDATE series_id=VALUES(series_id), position=VALUES(position)`,
+			postID, c.ID, i+1)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete deletes a single series. Its posts are kept, in no series.
+func (s *SeriesStorage) Delete(id string, audit Audit) error {
+	_, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return fmt.Errorf("Series id must be integer: %s", id)
+	}
+
+	return transact(s.DB, func(tx *sqlx.Tx) error {
+		if _, err := tx.Exec("DELETE FROM series WHERE id=? LIMIT 1", id); err != nil {
+			return err
+		}
+
+		return audit.record(tx, nil)
+	})
+}
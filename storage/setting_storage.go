@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"database/sql"
+	"github.com/jmoiron/sqlx"
+)
+
+// NewSettingStorage returns a new instance of SettingStorage
+func NewSettingStorage(DB *sqlx.DB) *SettingStorage {
+	return &SettingStorage{DB}
+}
+
+// SettingStorage forms SQL queries for site-wide settings
+type SettingStorage struct {
+	DB *sqlx.DB
+}
+
+// Get selects the value of a setting, or an empty string when it is not set
+func (s *SettingStorage) Get(name string) (string, error) {
+	var value string
+
+	err := s.DB.Get(&value, "SELECT value FROM settings WHERE name=?", name)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+
+	return value, err
+}
+
+// Set inserts or replaces the value of a setting
+func (s *SettingStorage) Set(name, value string) error {
+	_, err := s.DB.Exec(`INSERT INTO settings (name, value) VALUES (?, ?)
+		ON DUPLICATE KEY UPDATE value=VALUES(value)`, name, value)
+
+	return err
+}
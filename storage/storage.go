@@ -1,5 +1,43 @@
 package storage
 
+import (
+	"database/sql"
+	"errors"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/timrourke/timrourke.com/query"
+)
+
+// ErrStaleVersion is returned when updating a row that was changed since the
+// version being updated was read
+var ErrStaleVersion = errors.New("The record was changed by someone else")
+
+// ErrPermalinkTaken is returned when saving a post whose permalink another
+// post took first
+var ErrPermalinkTaken = errors.New("The permalink is already taken")
+
+// ErrTagTaken is returned when saving a tag whose slug another tag has
+var ErrTagTaken = errors.New("A tag with that name already exists")
+
+// ErrSeriesTaken is returned when saving a series whose slug another series
+// has
+var ErrSeriesTaken = errors.New("A series with that title already exists")
+
+// ErrCategoryTaken is returned when saving a category whose slug another
+// category has
+var ErrCategoryTaken = errors.New("A category with that name already exists")
+
+// ErrCategoryCycle is returned when moving a category under itself or one of
+// its own descendants
+var ErrCategoryCycle = errors.New("A category cannot be moved beneath itself")
+
+// ErrCategoryTooDeep is returned when moving a category would nest it or its
+// descendants deeper than the categories table can hold
+var ErrCategoryTooDeep = errors.New("Categories are nested too deeply")
+
+// mysqlDuplicateEntry is the MySQL error number for a unique key violation
+const mysqlDuplicateEntry = 1062
+
 // QueryParams defines the struct of query params to use for SQL constraints
 type QueryParams struct {
 	Limit   uint64
@@ -7,3 +45,67 @@ type QueryParams struct {
 	OrderBy string
 	Where   map[string]string
 }
+
+// countAll runs the count query for a list query, so pagination only counts the
+// rows the list's filters allow
+func countAll(DB *sqlx.DB, q *query.Query) (uint, error) {
+	var total uint
+
+	sql, boundValues := q.CompileCount()
+
+	named, args, err := sqlx.Named(sql, boundValues)
+	if err != nil {
+		return 0, err
+	}
+
+	err = DB.Get(&total, DB.Rebind(named), args...)
+
+	return total, err
+}
+
+// checkVersioned returns ErrStaleVersion when an update conditional on a
+// row's version affected nothing
+func checkVersioned(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	} else if affected == 0 {
+		return ErrStaleVersion
+	}
+
+	return nil
+}
+
+// checkAffected returns sql.ErrNoRows when a write changed no rows
+func checkAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	} else if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// transact runs a write in a transaction, which is committed unless the write
+// fails
+func transact(DB *sqlx.DB, write func(tx *sqlx.Tx) error) error {
+	tx, err := DB.Beginx()
+	if err != nil {
+		return err
+	}
+
+	if err = write(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// isDuplicateEntry reports whether an error is a unique key violation
+func isDuplicateEntry(err error) bool {
+	mysqlErr, ok := err.(*mysql.MySQLError)
+	return ok && mysqlErr.Number == mysqlDuplicateEntry
+}
@@ -0,0 +1,181 @@
+package storage
+
+import (
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/timrourke/timrourke.com/model"
+	"github.com/timrourke/timrourke.com/query"
+	"strconv"
+)
+
+// NewTagStorage returns a new instance of TagStorage
+func NewTagStorage(DB *sqlx.DB) *TagStorage {
+	return &TagStorage{DB}
+}
+
+// TagStorage forms SQL queries for tags
+type TagStorage struct {
+	DB *sqlx.DB
+}
+
+// GetAll selects a list of tags
+func (s *TagStorage) GetAll(q *query.Query) (uint, []model.Tag, error) {
+	var (
+		tags  []model.Tag
+		count uint
+	)
+
+	q.Select("tags.*").From("tags tags")
+
+	sql, boundValues := q.Compile()
+
+	rows, err := s.DB.NamedQuery(sql, boundValues)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var m model.Tag
+		err = rows.StructScan(&m)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		tags = append(tags, m)
+	}
+
+	// Get count of all matching tags for pagination
+	count, errCount := countAll(s.DB, q)
+
+	if err != nil {
+		return 0, nil, err
+	} else if errCount != nil {
+		return 0, nil, errCount
+	}
+
+	return count, tags, nil
+}
+
+// GetOne selects a single tag
+func (s *TagStorage) GetOne(ID string) (*model.Tag, error) {
+	var tag model.Tag
+
+	err := s.DB.Get(&tag, "SELECT * FROM tags WHERE id=?", ID)
+
+	return &tag, err
+}
+
+// GetBySlug selects the tag with a slug
+func (s *TagStorage) GetBySlug(slug string) (*model.Tag, error) {
+	var tag model.Tag
+
+	err := s.DB.Get(&tag, "SELECT * FROM tags WHERE slug=?", slug)
+
+	return &tag, err
+}
+
+// Missing returns the ids in a list that belong to no tag
+func (s *TagStorage) Missing(IDs []string) ([]string, error) {
+	missing := []string{}
+	if len(IDs) == 0 {
+		return missing, nil
+	}
+
+	found := []string{}
+	sql, args, err := sqlx.In("SELECT CAST(id AS CHAR) FROM tags WHERE id IN (?)", IDs)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.DB.Select(&found, s.DB.Rebind(sql), args...); err != nil {
+		return nil, err
+	}
+
+	for _, ID := range IDs {
+		if !containsString(found, ID) {
+			missing = append(missing, ID)
+		}
+	}
+
+	return missing, nil
+}
+
+// Insert inserts a single tag
+func (s *TagStorage) Insert(c model.Tag, audit Audit) (*model.Tag, error) {
+	err := transact(s.DB, func(tx *sqlx.Tx) error {
+		result, err := tx.NamedExec(`INSERT INTO tags (
+			name,
+			slug
+		) VALUES (
+			:name,
+			:slug
+		)`, &c)
+
+		if isDuplicateEntry(err) {
+			return ErrTagTaken
+		} else if err != nil {
+			return err
+		}
+
+		insertID, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		// Set ID on return struct for rendering to json
+		c.SetID(fmt.Sprintf("%d", insertID))
+
+		return audit.record(tx, &c)
+	})
+	if err != nil {
+		return &model.Tag{}, err
+	}
+
+	return s.GetOne(c.GetID())
+}
+
+// Update updates a single tag
+func (s *TagStorage) Update(c *model.Tag, audit Audit) error {
+	return transact(s.DB, func(tx *sqlx.Tx) error {
+		_, err := tx.NamedExec(`UPDATE tags SET
+			name=:name,
+			slug=:slug
+			WHERE id=:id`, &c)
+
+		if isDuplicateEntry(err) {
+			return ErrTagTaken
+		} else if err != nil {
+			return err
+		}
+
+		return audit.record(tx, c)
+	})
+}
+
+// Delete deletes a single tag, untagging every post it was on
+func (s *TagStorage) Delete(id string, audit Audit) error {
+	_, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return fmt.Errorf("Tag id must be integer: %s", id)
+	}
+
+	return transact(s.DB, func(tx *sqlx.Tx) error {
+		if _, err := tx.Exec("DELETE FROM tags WHERE id=? LIMIT 1", id); err != nil {
+			return err
+		}
+
+		return audit.record(tx, nil)
+	})
+}
+
+// containsString reports whether a list of strings contains one
+func containsString(list []string, value string) bool {
+	for _, candidate := range list {
+		if candidate == value {
+			return true
+		}
+	}
+
+	return false
+}
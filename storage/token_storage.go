@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/timrourke/timrourke.com/model"
+	"github.com/timrourke/timrourke.com/query"
+	"strconv"
+)
+
+// NewTokenStorage returns a new instance of TokenStorage
+func NewTokenStorage(DB *sqlx.DB) *TokenStorage {
+	return &TokenStorage{DB}
+}
+
+// TokenStorage forms SQL queries for personal access tokens
+type TokenStorage struct {
+	DB *sqlx.DB
+}
+
+// GetAll selects a list of tokens
+func (s *TokenStorage) GetAll(q *query.Query) (uint, []model.Token, error) {
+	var (
+		tokens []model.Token
+		count  uint
+	)
+
+	q.Select("tokens.*").From("tokens tokens")
+
+	sql, boundValues := q.Compile()
+
+	rows, err := s.DB.NamedQuery(sql, boundValues)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var m model.Token
+		err = rows.StructScan(&m)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		tokens = append(tokens, m)
+	}
+
+	// Get count of all matching tokens for pagination
+	count, errCount := countAll(s.DB, q)
+
+	if err != nil {
+		return 0, nil, err
+	} else if errCount != nil {
+		return 0, nil, errCount
+	}
+
+	return count, tokens, nil
+}
+
+// GetOne selects a single token
+func (s *TokenStorage) GetOne(ID string) (*model.Token, error) {
+	var token model.Token
+
+	err := s.DB.Get(&token, "SELECT * FROM tokens WHERE id=?", ID)
+
+	return &token, err
+}
+
+// GetByHash selects a single token by the hash of its secret
+func (s *TokenStorage) GetByHash(tokenHash string) (*model.Token, error) {
+	var token model.Token
+
+	err := s.DB.Get(&token, "SELECT * FROM tokens WHERE token_hash=?", tokenHash)
+
+	return &token, err
+}
+
+// Insert inserts a single token
+func (s *TokenStorage) Insert(c model.Token) (*model.Token, error) {
+	result, err := s.DB.NamedExec(`INSERT INTO tokens (
+		user_id,
+		name,
+		token_hash,
+		scopes,
+		expires_at
+	) VALUES (
+		:user_id,
+		:name,
+		:token_hash,
+		:scopes,
+		:expires_at
+	)`, &c)
+
+	if err != nil {
+		return &model.Token{}, err
+	}
+
+	insertID, err := result.LastInsertId()
+	if err != nil {
+		return &model.Token{}, err
+	}
+
+	// Set ID on return struct for rendering to json
+	c.SetID(fmt.Sprintf("%d", insertID))
+
+	return s.GetOne(c.GetID())
+}
+
+// Revoke revokes a single token, keeping it so it still shows in the list
+func (s *TokenStorage) Revoke(id string) error {
+	_, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return fmt.Errorf("Token id must be integer: %s", id)
+	}
+
+	_, err = s.DB.Exec(`UPDATE tokens SET revoked_at=UTC_TIMESTAMP()
+		WHERE id=? AND revoked_at IS NULL`, id)
+
+	return err
+}
+
+// Touch records that a token was just used
+func (s *TokenStorage) Touch(id string) error {
+	_, err := s.DB.Exec("UPDATE tokens SET last_used_at=UTC_TIMESTAMP() WHERE id=?", id)
+
+	return err
+}
@@ -1,11 +1,13 @@
 package storage
 
 import (
+	"database/sql"
 	"fmt"
 	"github.com/jmoiron/sqlx"
 	"github.com/timrourke/timrourke.com/model"
 	"github.com/timrourke/timrourke.com/query"
 	"strconv"
+	"time"
 )
 
 // NewUserStorage returns a new instance of UserStorage
@@ -18,8 +20,20 @@ type UserStorage struct {
 	DB *sqlx.DB
 }
 
-// GetAll selects a list of users
+// GetAll selects a list of users, leaving out those in the trash
 func (s *UserStorage) GetAll(q *query.Query) (uint, []model.User, error) {
+	q.Where("users.deleted_at IS NULL")
+	return s.getAll(q)
+}
+
+// GetTrash selects a list of the users in the trash
+func (s *UserStorage) GetTrash(q *query.Query) (uint, []model.User, error) {
+	q.Where("users.deleted_at IS NOT NULL")
+	return s.getAll(q)
+}
+
+// getAll selects a list of users matching a query
+func (s *UserStorage) getAll(q *query.Query) (uint, []model.User, error) {
 	var (
 		users []model.User
 		count uint
@@ -45,8 +59,8 @@ func (s *UserStorage) GetAll(q *query.Query) (uint, []model.User, error) {
 		users = append(users, m)
 	}
 
-	// Get count of all users for pagination
-	errCount := s.DB.Get(&count, "SELECT COUNT(*) FROM users")
+	// Get count of all matching users for pagination
+	count, errCount := countAll(s.DB, q)
 
 	if err != nil {
 		return 0, nil, err
@@ -57,67 +71,368 @@ func (s *UserStorage) GetAll(q *query.Query) (uint, []model.User, error) {
 	return count, users, nil
 }
 
-// GetOne selects a single user
+// GetOne selects a single user, unless they are in the trash
 func (s *UserStorage) GetOne(ID string) (*model.User, error) {
 	var user model.User
 
-	err := s.DB.Get(&user, "SELECT * FROM users WHERE id=?", ID)
+	err := s.DB.Get(&user, "SELECT * FROM users WHERE id=? AND deleted_at IS NULL", ID)
 
 	return &user, err
 }
 
-// Insert inserts a single user
-func (s *UserStorage) Insert(c model.User) (*model.User, error) {
-	result, err := s.DB.NamedExec(`INSERT INTO users (
-		username,
-		email,
-		password_hash
-	) VALUES (
-		:username,
-		:email,
-		:password_hash
-	)`, &c)
+// GetTrashed selects a single user in the trash
+func (s *UserStorage) GetTrashed(ID string) (*model.User, error) {
+	var user model.User
+
+	err := s.DB.Get(&user, "SELECT * FROM users WHERE id=? AND deleted_at IS NOT NULL", ID)
+
+	return &user, err
+}
+
+// Missing returns the ids in a list that belong to no user, or to users in the
+// trash
+func (s *UserStorage) Missing(IDs []string) ([]string, error) {
+	missing := []string{}
+	if len(IDs) == 0 {
+		return missing, nil
+	}
 
+	found := []string{}
+	sql, args, err := sqlx.In("SELECT CAST(id AS CHAR) FROM users WHERE id IN (?) AND deleted_at IS NULL", IDs)
 	if err != nil {
-		return &model.User{}, err
+		return nil, err
+	}
+
+	if err = s.DB.Select(&found, s.DB.Rebind(sql), args...); err != nil {
+		return nil, err
+	}
+
+	for _, ID := range IDs {
+		if !containsString(found, ID) {
+			missing = append(missing, ID)
+		}
 	}
 
-	insertID, err := result.LastInsertId()
+	return missing, nil
+}
+
+// GetByUsername selects a single user by username, unless they are in the
+// trash
+func (s *UserStorage) GetByUsername(username string) (*model.User, error) {
+	var user model.User
+
+	err := s.DB.Get(&user, "SELECT * FROM users WHERE username=? AND deleted_at IS NULL ORDER BY id ASC LIMIT 1", username)
+
+	return &user, err
+}
+
+// GetByEmail selects a single user by email, unless they are in the trash
+func (s *UserStorage) GetByEmail(email string) (*model.User, error) {
+	var user model.User
+
+	err := s.DB.Get(&user, "SELECT * FROM users WHERE email=? AND deleted_at IS NULL ORDER BY id ASC LIMIT 1", email)
+
+	return &user, err
+}
+
+// Count counts all users
+func (s *UserStorage) Count() (uint, error) {
+	var count uint
+
+	err := s.DB.Get(&count, "SELECT COUNT(*) FROM users")
+
+	return count, err
+}
+
+// Insert inserts a single user
+func (s *UserStorage) Insert(c model.User, audit Audit) (*model.User, error) {
+	err := transact(s.DB, func(tx *sqlx.Tx) error {
+		result, err := tx.NamedExec(`INSERT INTO users (
+			username,
+			email,
+			password_hash,
+			role,
+			display_name,
+			bio,
+			avatar_url,
+			website,
+			social_links
+		) VALUES (
+			:username,
+			:email,
+			:password_hash,
+			:role,
+			:display_name,
+			:bio,
+			:avatar_url,
+			:website,
+			:social_links
+		)`, &c)
+
+		if err != nil {
+			return err
+		}
+
+		insertID, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		// Set ID on return struct for rendering to json
+		c.SetID(fmt.Sprintf("%d", insertID))
+
+		return audit.record(tx, &c)
+	})
 	if err != nil {
 		return &model.User{}, err
 	}
 
-	// Set ID on return struct for rendering to json
-	c.SetID(fmt.Sprintf("%d", insertID))
-
 	return s.GetOne(c.GetID())
 }
 
-// Delete deletes a single user
-func (s *UserStorage) Delete(id string) error {
+// Delete moves a single user to the trash, which stops them logging in. It
+// returns sql.ErrNoRows when the user is already in the trash.
+func (s *UserStorage) Delete(id string, audit Audit) error {
 	_, err := strconv.ParseInt(id, 10, 64)
 	if err != nil {
 		return fmt.Errorf("User id must be integer: %s", id)
 	}
 
-	_, err = s.DB.Exec("DELETE FROM users WHERE id=? LIMIT 1", id)
+	return transact(s.DB, func(tx *sqlx.Tx) error {
+		result, err := tx.Exec(`UPDATE users SET deleted_at=UTC_TIMESTAMP(), version=version + 1
+			WHERE id=? AND deleted_at IS NULL LIMIT 1`, id)
+
+		if err == nil {
+			err = checkAffected(result)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		return audit.record(tx, nil)
+	})
+}
+
+// Restore takes a single user out of the trash. It returns sql.ErrNoRows when
+// the user is no longer in the trash.
+func (s *UserStorage) Restore(c *model.User, audit Audit) error {
+	restored := *c
+	restored.DeletedAt = nil
+	restored.Version++
+
+	err := transact(s.DB, func(tx *sqlx.Tx) error {
+		result, err := tx.Exec(`UPDATE users SET deleted_at=NULL, version=version + 1
+			WHERE id=? AND deleted_at IS NOT NULL`, c.ID)
+
+		if err == nil {
+			err = checkAffected(result)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		return audit.record(tx, &restored)
+	})
 	if err != nil {
 		return err
 	}
+
+	*c = restored
 	return nil
 }
 
-// Update updates a single user
-func (s *UserStorage) Update(c *model.User) error {
-	_, err := s.DB.NamedExec(`UPDATE users SET 
-		username=:username,
-		email=:email,
-		password_hash=:password_hash
+// GetExpiredTrash selects the users that went in the trash before a time and
+// have no posts left. Users whose posts are still around stay in the trash
+// until their posts are gone.
+func (s *UserStorage) GetExpiredTrash(before time.Time) ([]model.User, error) {
+	users := []model.User{}
+
+	err := s.DB.Select(&users, `SELECT * FROM users
+		WHERE deleted_at < ? AND NOT EXISTS (SELECT 1 FROM posts WHERE posts.user_id = users.id)
+		ORDER BY deleted_at ASC, id ASC`, before.UTC())
+
+	return users, err
+}
+
+// Purge deletes a user for good if they are still in the trash, went there
+// before a time and have no posts. It reports whether the user was deleted.
+func (s *UserStorage) Purge(c *model.User, before time.Time, audit Audit) (bool, error) {
+	purged := false
+
+	err := transact(s.DB, func(tx *sqlx.Tx) error {
+		result, err := tx.Exec(`DELETE FROM users
+			WHERE id=? AND deleted_at < ? AND NOT EXISTS (SELECT 1 FROM posts WHERE posts.user_id = users.id)`,
+			c.ID, before.UTC())
+		if err != nil {
+			return err
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil || affected == 0 {
+			return err
+		}
+
+		purged = true
+		return audit.record(tx, nil)
+	})
+
+	if err != nil {
+		return false, err
+	}
+
+	return purged, nil
+}
+
+// Update updates a single user. It returns ErrStaleVersion, changing nothing,
+// when the user is no longer at the version being updated. An admin unlocking
+// the user passes the unlock event to record, which is only recorded, and the
+// user only unlocked, along with the update.
+func (s *UserStorage) Update(c *model.User, unlock *model.LockoutEvent, audit Audit) error {
+	after := *c
+	after.Version++
+	if unlock != nil {
+		clearLockout(&after)
+	}
+
+	err := transact(s.DB, func(tx *sqlx.Tx) error {
+		result, err := tx.NamedExec(`UPDATE users SET 
+			username=:username,
+			email=:email,
+			password_hash=:password_hash,
+			role=:role,
+			display_name=:display_name,
+			bio=:bio,
+			avatar_url=:avatar_url,
+			website=:website,
+			social_links=:social_links,
+			version=version + 1
+			WHERE id=:id AND version=:version`, &c)
+
+		if err != nil {
+			return err
+		}
+
+		if err = checkVersioned(result); err != nil {
+			return err
+		}
+
+		if unlock != nil {
+			if err = unlockUser(tx, c.ID); err != nil {
+				return err
+			}
+
+			if err = insertLockoutEvent(tx, *unlock); err != nil {
+				return err
+			}
+		}
+
+		return audit.record(tx, &after)
+	})
+	if err != nil {
+		return err
+	}
+
+	*c = after
+	return nil
+}
+
+// UpdateTwoFactor updates a user's TOTP secret and enrollment, resetting the
+// last used time step
+func (s *UserStorage) UpdateTwoFactor(c *model.User) error {
+	_, err := s.DB.NamedExec(`UPDATE users SET
+		totp_secret=:totp_secret,
+		totp_enabled_at=:totp_enabled_at,
+		totp_last_step=0
 		WHERE id=:id`, &c)
 
+	return err
+}
+
+// UseTOTPStep records the time step of a TOTP code a user just gave. It returns
+// sql.ErrNoRows when the step is not newer than the last one used, so a code
+// cannot be replayed by concurrent requests.
+func (s *UserStorage) UseTOTPStep(c *model.User, step int64) error {
+	result, err := s.DB.Exec(`UPDATE users SET totp_last_step=?
+		WHERE id=? AND totp_last_step < ?`, step, c.ID, step)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
 	if err != nil {
 		return err
+	} else if affected == 0 {
+		return sql.ErrNoRows
 	}
 
+	c.TOTPLastStep = step
 	return nil
 }
+
+// RecordFailedLogin counts a failed login against a user, starting the count
+// again when the last failure was before windowStart. It returns the new
+// number of failures.
+func (s *UserStorage) RecordFailedLogin(c *model.User, now, windowStart time.Time) (int, error) {
+	_, err := s.DB.Exec(`UPDATE users SET
+		failed_logins=IF(last_failed_login_at IS NULL OR last_failed_login_at < ?, 1, failed_logins + 1),
+		last_failed_login_at=?
+		WHERE id=?`, windowStart, now, c.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	err = s.DB.Get(&c.FailedLogins, "SELECT failed_logins FROM users WHERE id=?", c.ID)
+
+	return c.FailedLogins, err
+}
+
+// Lock locks a user out until the given time
+func (s *UserStorage) Lock(c *model.User, until time.Time) error {
+	_, err := s.DB.Exec(`UPDATE users SET
+		failed_logins=0,
+		lockouts=lockouts + 1,
+		locked_until=?
+		WHERE id=?`, until, c.ID)
+	if err != nil {
+		return err
+	}
+
+	c.FailedLogins = 0
+	c.Lockouts++
+	c.LockedUntil = &until
+
+	return nil
+}
+
+// Unlock clears a user's failed logins and lockouts, after they log in or an
+// admin unlocks them
+func (s *UserStorage) Unlock(c *model.User) error {
+	if err := unlockUser(s.DB, c.ID); err != nil {
+		return err
+	}
+
+	clearLockout(c)
+	return nil
+}
+
+// unlockUser clears the failed logins and lockouts of the user with an id
+func unlockUser(e sqlx.Execer, id int64) error {
+	_, err := e.Exec(`UPDATE users SET
+		failed_logins=0,
+		last_failed_login_at=NULL,
+		lockouts=0,
+		locked_until=NULL
+		WHERE id=?`, id)
+
+	return err
+}
+
+// clearLockout clears a user's failed logins and lockouts to match an unlock
+func clearLockout(c *model.User) {
+	c.FailedLogins = 0
+	c.LastFailedLoginAt = nil
+	c.Lockouts = 0
+	c.LockedUntil = nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
@@ -11,14 +12,24 @@ import (
 	"github.com/joho/godotenv"
 	"github.com/manyminds/api2go"
 	"github.com/manyminds/api2go-adapter/gingonic"
+	"github.com/timrourke/timrourke.com/auth"
+	"github.com/timrourke/timrourke.com/clientip"
 	"github.com/timrourke/timrourke.com/db"
+	"github.com/timrourke/timrourke.com/handler"
+	"github.com/timrourke/timrourke.com/highlight"
+	"github.com/timrourke/timrourke.com/mail"
 	"github.com/timrourke/timrourke.com/model"
 	"github.com/timrourke/timrourke.com/resource"
+	"github.com/timrourke/timrourke.com/sanitize"
+	"github.com/timrourke/timrourke.com/scheduler"
 	"github.com/timrourke/timrourke.com/storage"
 	"log"
 	"net/http"
 	"os"
 	"os/exec"
+	"os/signal"
+	"strconv"
+	"syscall"
 	"time"
 )
 
@@ -74,10 +85,47 @@ func run() {
 	// Expose DB to models for relationship resolutions
 	model.DB = DB
 
+	// Build the authenticator once, so every request checks tokens against
+	// the same secret
+	authenticator := newAuthenticator(DB)
+
+	// Build the mailer up front, so a missing mail configuration stops the app
+	// before it serves anything
+	mailer := newMailer()
+
 	// Initialize routes
-	r := initRouter(DB)
+	r := initRouter(DB, authenticator, mailer)
+
+	// Start background jobs
+	jobs := newScheduler(DB)
+	jobs.Start()
+
+	srv := &http.Server{
+		Addr:    ":8000",
+		Handler: r,
+	}
+
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logError(err)
+			panic(err)
+		}
+	}()
+
+	// Wait for a signal to shut down, letting requests and jobs in progress
+	// finish first
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	<-quit
 
-	r.Run(":8000")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		logError(err)
+	}
+
+	jobs.Stop()
 }
 
 // Connect to database
@@ -100,7 +148,7 @@ func CORSMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "http://localhost:4200")
 		c.Writer.Header().Set("Access-Control-Max-Age", "86400")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PATCH, DELETE")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, PATCH, DELETE")
 		c.Writer.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
 		c.Writer.Header().Set("Access-Control-Expose-Headers", "Content-Length")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
@@ -120,9 +168,14 @@ func Api2goCorsMiddleware(c api2go.APIContexter, w http.ResponseWriter, r *http.
 }
 
 // Initialize gin-gonic routes
-func initRouter(DB *sqlx.DB) *gin.Engine {
+func initRouter(DB *sqlx.DB, authenticator *auth.Authenticator, mailer mail.Mailer) *gin.Engine {
 	r := gin.Default()
 
+	// Client addresses are resolved by clientip, which only believes the
+	// forwarded headers of TRUSTED_PROXIES, so gin must not read them itself
+	r.ForwardedByClientIP = false
+	r.Use(newClientIPResolver().Middleware)
+
 	api := api2go.NewAPIWithRouting(
 		"api",
 		api2go.NewStaticResolver("http://localhost:8000"),
@@ -132,17 +185,130 @@ func initRouter(DB *sqlx.DB) *gin.Engine {
 	api.UseMiddleware(Api2goCorsMiddleware)
 
 	userStorage := storage.NewUserStorage(DB)
+
+	api.UseMiddleware(authenticator.Middleware)
+
+	auditEventStorage := storage.NewAuditEventStorage(DB)
+
 	api.AddResource(model.User{}, resource.UserResource{
-		UserStorage: userStorage,
+		UserStorage:       userStorage,
+		AuditEventStorage: auditEventStorage,
 	})
 
 	postStorage := storage.NewPostStorage(DB)
+	tagStorage := storage.NewTagStorage(DB)
+	categoryStorage := storage.NewCategoryStorage(DB)
+	seriesStorage := storage.NewSeriesStorage(DB)
+	sanitizer := newSanitizer()
+	excerptLength := intFromEnv("EXCERPT_LENGTH")
 	api.AddResource(model.Post{}, resource.PostResource{
-		PostStorage: postStorage,
+		PostStorage:       postStorage,
+		UserStorage:       userStorage,
+		TagStorage:        tagStorage,
+		CategoryStorage:   categoryStorage,
+		SeriesStorage:     seriesStorage,
+		AuditEventStorage: auditEventStorage,
+		Sanitizer:         sanitizer,
+		ExcerptLength:     excerptLength,
+	})
+
+	api.AddResource(model.Tag{}, resource.TagResource{
+		TagStorage:        tagStorage,
+		AuditEventStorage: auditEventStorage,
+	})
+
+	api.AddResource(model.Category{}, resource.CategoryResource{
+		CategoryStorage:   categoryStorage,
+		AuditEventStorage: auditEventStorage,
+	})
+
+	api.AddResource(model.Series{}, resource.SeriesResource{
+		SeriesStorage:     seriesStorage,
+		PostStorage:       postStorage,
+		AuditEventStorage: auditEventStorage,
+	})
+
+	postRevisionStorage := storage.NewPostRevisionStorage(DB)
+	api.AddResource(model.PostRevision{}, resource.PostRevisionResource{
+		PostRevisionStorage: postRevisionStorage,
+	})
+
+	api.AddResource(model.Token{}, resource.TokenResource{
+		TokenStorage: storage.NewTokenStorage(DB),
+	})
+
+	api.AddResource(model.AuditEvent{}, resource.AuditEventResource{
+		AuditEventStorage: auditEventStorage,
 	})
 
 	r.GET("/ping", getPing)
 
+	authRoutes := r.Group("/api", CORSMiddleware())
+	authRoutes.OPTIONS("/login", getPreflight)
+	authRoutes.POST("/login", handler.Login(authenticator))
+
+	passwordReset := newPasswordReset(DB, userStorage, mailer)
+	authRoutes.OPTIONS("/password-resets", getPreflight)
+	authRoutes.POST("/password-resets", passwordReset.Request)
+	authRoutes.OPTIONS("/password-resets/confirm", getPreflight)
+	authRoutes.POST("/password-resets/confirm", passwordReset.Confirm)
+
+	twoFactor := handler.TwoFactor{
+		Authenticator: authenticator,
+		Issuer:        "timrourke.com",
+	}
+	authRoutes.OPTIONS("/two-factor/enroll", getPreflight)
+	authRoutes.POST("/two-factor/enroll", twoFactor.Enroll)
+	authRoutes.OPTIONS("/two-factor/confirm", getPreflight)
+	authRoutes.POST("/two-factor/confirm", twoFactor.Confirm)
+	authRoutes.OPTIONS("/two-factor/disable", getPreflight)
+	authRoutes.POST("/two-factor/disable", twoFactor.Disable)
+	authRoutes.OPTIONS("/two-factor/policy", getPreflight)
+	authRoutes.GET("/two-factor/policy", twoFactor.GetPolicy)
+	authRoutes.PUT("/two-factor/policy", twoFactor.UpdatePolicy)
+
+	revisions := handler.Revisions{
+		Authenticator:       authenticator,
+		PostStorage:         postStorage,
+		PostRevisionStorage: postRevisionStorage,
+		AuditEventStorage:   auditEventStorage,
+		Sanitizer:           sanitizer,
+		ExcerptLength:       excerptLength,
+	}
+	authRoutes.OPTIONS("/posts/:id/revisions/diff", getPreflight)
+	authRoutes.GET("/posts/:id/revisions/diff", revisions.Diff)
+	authRoutes.OPTIONS("/posts/:id/revisions/:revision/restore", getPreflight)
+	authRoutes.POST("/posts/:id/revisions/:revision/restore", revisions.Restore)
+
+	trash := handler.Trash{
+		Authenticator:     authenticator,
+		PostStorage:       postStorage,
+		UserStorage:       userStorage,
+		AuditEventStorage: auditEventStorage,
+	}
+	authRoutes.OPTIONS("/trash/posts", getPreflight)
+	authRoutes.GET("/trash/posts", trash.Posts)
+	authRoutes.OPTIONS("/trash/users", getPreflight)
+	authRoutes.GET("/trash/users", trash.Users)
+	authRoutes.OPTIONS("/posts/:id/restore", getPreflight)
+	authRoutes.POST("/posts/:id/restore", trash.RestorePost)
+	authRoutes.OPTIONS("/users/:id/restore", getPreflight)
+	authRoutes.POST("/users/:id/restore", trash.RestoreUser)
+
+	authors := handler.Authors{
+		UserStorage: userStorage,
+		PostStorage: postStorage,
+	}
+	r.GET("/authors/:username", authors.Show)
+
+	posts := handler.Posts{
+		PostStorage: postStorage,
+		Sanitizer:   sanitizer,
+	}
+	r.GET("/posts/:permalink", posts.Show)
+
+	r.GET("/css/highlight.css", newHighlight().CSS)
+
 	r.Use(static.Serve("/", static.LocalFile("./frontend/html", true)))
 	// r.Use(static.Serve("/", static.LocalFile("./hugo/public", true)))
 
@@ -158,6 +324,188 @@ func initRouter(DB *sqlx.DB) *gin.Engine {
 	return r
 }
 
+// Build the authenticator for access tokens, configured by AUTH_TOKEN_SECRET
+// and AUTH_TOKEN_TTL. There is no default secret, so the app refuses to start
+// without one.
+func newAuthenticator(DB *sqlx.DB) *auth.Authenticator {
+	ttl := durationFromEnv("AUTH_TOKEN_TTL")
+
+	authenticator, err := auth.NewAuthenticator([]byte(os.Getenv("AUTH_TOKEN_SECRET")), ttl, DB)
+	if err != nil {
+		logError(err)
+		panic(err)
+	}
+
+	return authenticator
+}
+
+// Build the scheduler for background jobs, configured by SCHEDULER_INTERVAL
+// and TRASH_RETENTION
+func newScheduler(DB *sqlx.DB) *scheduler.Scheduler {
+	jobs := scheduler.New(storage.NewJobRunStorage(DB))
+	postStorage := storage.NewPostStorage(DB)
+	auditEventStorage := storage.NewAuditEventStorage(DB)
+	interval := durationFromEnv("SCHEDULER_INTERVAL")
+
+	jobs.Add(scheduler.PublishScheduledPosts(
+		postStorage,
+		auditEventStorage,
+		interval))
+
+	jobs.Add(scheduler.PurgeTrash(
+		postStorage,
+		storage.NewUserStorage(DB),
+		auditEventStorage,
+		durationFromEnv("TRASH_RETENTION"),
+		interval))
+
+	jobs.Add(scheduler.RenderPosts(
+		postStorage,
+		newSanitizer(),
+		intFromEnv("EXCERPT_LENGTH"),
+		interval))
+
+	return jobs
+}
+
+// Build the resolver for client addresses, trusting the forwarded headers of
+// the comma separated proxies, IP addresses or CIDR ranges, in TRUSTED_PROXIES
+func newClientIPResolver() *clientip.Resolver {
+	resolver, err := clientip.NewResolver(model.ParseList(os.Getenv("TRUSTED_PROXIES")))
+	if err != nil {
+		logError(err)
+		panic(err)
+	}
+
+	return resolver
+}
+
+// Build the policy post HTML is sanitized with, allowing iframes from the
+// comma separated hosts in EMBED_HOSTS
+func newSanitizer() *sanitize.Policy {
+	hosts, ok := os.LookupEnv("EMBED_HOSTS")
+	if !ok {
+		return sanitize.NewPolicy(sanitize.DefaultEmbedHosts)
+	}
+
+	return sanitize.NewPolicy(model.ParseList(hosts))
+}
+
+// Build the stylesheet for highlighted code in the theme named by
+// HIGHLIGHT_THEME, which is light unless set
+func newHighlight() handler.Highlight {
+	name := os.Getenv("HIGHLIGHT_THEME")
+	if len(name) == 0 {
+		name = highlight.DefaultTheme
+	}
+
+	theme, ok := highlight.Themes[name]
+	if !ok {
+		err := fmt.Errorf("HIGHLIGHT_THEME %q is not a theme", name)
+		logError(err)
+		panic(err)
+	}
+
+	return handler.Highlight{Theme: theme}
+}
+
+// Build the password reset handlers, configured by PASSWORD_RESET_URL and
+// PASSWORD_RESET_TTL
+func newPasswordReset(DB *sqlx.DB, userStorage *storage.UserStorage, mailer mail.Mailer) handler.PasswordReset {
+	resetURL := os.Getenv("PASSWORD_RESET_URL")
+	if len(resetURL) == 0 {
+		resetURL = "http://localhost:8000/admin/reset-password"
+	}
+
+	return handler.PasswordReset{
+		UserStorage:          userStorage,
+		PasswordResetStorage: storage.NewPasswordResetStorage(DB),
+		Mailer:               mailer,
+		ResetURL:             resetURL,
+		TTL:                  durationFromEnv("PASSWORD_RESET_TTL"),
+	}
+}
+
+// Build the mailer selected by MAILER: "smtp" sends through SMTP_HOST and
+// "file" appends to MAILER_FILE. Mail carries password reset tokens, so there
+// is no default, and the app refuses to start without one.
+func newMailer() mail.Mailer {
+	from := os.Getenv("MAIL_FROM")
+	if len(from) == 0 {
+		from = "timrourke.com <no-reply@timrourke.com>"
+	}
+
+	switch os.Getenv("MAILER") {
+	case "smtp":
+		port := os.Getenv("SMTP_PORT")
+		if len(port) == 0 {
+			port = "25"
+		}
+
+		mailer, err := mail.NewSMTPMailer(
+			os.Getenv("SMTP_HOST"),
+			port,
+			os.Getenv("SMTP_USERNAME"),
+			os.Getenv("SMTP_PASSWORD"),
+			from)
+		if err != nil {
+			logError(err)
+			panic(err)
+		}
+
+		return mailer
+	case "file":
+		mailer, err := mail.NewFileMailer(os.Getenv("MAILER_FILE"), from)
+		if err != nil {
+			logError(err)
+			panic(err)
+		}
+
+		return mailer
+	default:
+		err := fmt.Errorf("MAILER %q is not a mailer, use smtp or file", os.Getenv("MAILER"))
+		logError(err)
+		panic(err)
+	}
+}
+
+// Parse a duration from an environment variable, returning 0 when unset
+func durationFromEnv(name string) time.Duration {
+	raw := os.Getenv(name)
+	if len(raw) == 0 {
+		return 0
+	}
+
+	duration, err := time.ParseDuration(raw)
+	if err != nil {
+		logError(err)
+		panic(err)
+	}
+
+	return duration
+}
+
+// Parse an integer from an environment variable, returning 0 when unset
+func intFromEnv(name string) int {
+	raw := os.Getenv(name)
+	if len(raw) == 0 {
+		return 0
+	}
+
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		logError(err)
+		panic(err)
+	}
+
+	return value
+}
+
+// Preflight route handler for CORS requests to non-api2go routes
+func getPreflight(c *gin.Context) {
+	c.AbortWithStatus(http.StatusNoContent)
+}
+
 // Ping route handler for testing uptime
 func getPing(c *gin.Context) {
 	c.String(200, "pong")
@@ -6,36 +6,90 @@ import (
 	"github.com/DATA-DOG/godog"
 	"github.com/DATA-DOG/godog/gherkin"
 	"github.com/jmoiron/sqlx"
+	"github.com/timrourke/timrourke.com/auth"
+	"github.com/timrourke/timrourke.com/mail"
+	"github.com/timrourke/timrourke.com/model"
+	"github.com/timrourke/timrourke.com/storage"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"strings"
 	"time"
 )
 
 var test_db *sqlx.DB
 
+// test_authenticator serves every request and signs every token the steps
+// issue, just as a single authenticator does for the running app
+var test_authenticator *auth.Authenticator
+
+// test_mailer writes the email requests send to stdout, rather than needing
+// a mail server
+var test_mailer = mail.NewWriterMailer(os.Stdout, "timrourke.com <no-reply@timrourke.com>")
+
 func init() {
 	test_db = initDB()
+
+	// .env.test may leave the secret out, as tokens only need to last a run
+	if len(os.Getenv("AUTH_TOKEN_SECRET")) == 0 {
+		os.Setenv("AUTH_TOKEN_SECRET", "feature-tests")
+	}
+	test_authenticator = newAuthenticator(test_db)
 }
 
 type apiFeature struct {
-	resp *httptest.ResponseRecorder
+	resp    *httptest.ResponseRecorder
+	headers http.Header
 }
 
 func (a *apiFeature) resetResponse(interface{}) {
 	a.resp = httptest.NewRecorder()
+	a.headers = http.Header{}
 
 	_ = test_db.MustExec("SET FOREIGN_KEY_CHECKS=0")
 	_ = test_db.MustExec("TRUNCATE TABLE `users`")
+	_ = test_db.MustExec("TRUNCATE TABLE `posts`")
+	_ = test_db.MustExec("TRUNCATE TABLE `password_resets`")
+	_ = test_db.MustExec("TRUNCATE TABLE `recovery_codes`")
+	_ = test_db.MustExec("TRUNCATE TABLE `settings`")
+	_ = test_db.MustExec("TRUNCATE TABLE `tokens`")
+	_ = test_db.MustExec("TRUNCATE TABLE `login_throttles`")
+	_ = test_db.MustExec("TRUNCATE TABLE `lockout_events`")
+	_ = test_db.MustExec("TRUNCATE TABLE `audit_events`")
+	_ = test_db.MustExec("TRUNCATE TABLE `job_runs`")
+	_ = test_db.MustExec("TRUNCATE TABLE `post_revisions`")
+	_ = test_db.MustExec("TRUNCATE TABLE `post_permalinks`")
+	_ = test_db.MustExec("TRUNCATE TABLE `tags`")
+	_ = test_db.MustExec("TRUNCATE TABLE `posts_tags`")
+	_ = test_db.MustExec("TRUNCATE TABLE `categories`")
+	_ = test_db.MustExec("TRUNCATE TABLE `series`")
+	_ = test_db.MustExec("TRUNCATE TABLE `series_posts`")
+	_ = test_db.MustExec("TRUNCATE TABLE `post_authors`")
 	_ = test_db.MustExec("SET FOREIGN_KEY_CHECKS=1")
 }
 
 func (a *apiFeature) iSendRequestTo(method, endpoint string) error {
-	req, err := http.NewRequest(method, endpoint, nil)
+	return a.sendRequest(method, endpoint, nil)
+}
+
+func (a *apiFeature) iSendRequestToWithBody(method, endpoint string, body *gherkin.DocString) error {
+	return a.sendRequest(method, endpoint, strings.NewReader(body.Content))
+}
+
+func (a *apiFeature) sendRequest(method, endpoint string, body io.Reader) error {
+	req, err := http.NewRequest(method, endpoint, body)
 	if err != nil {
 		return err
 	}
-	initRouter(test_db).ServeHTTP(a.resp, req)
+	// Requests come from the same address httptest gives them
+	req.RemoteAddr = "192.0.2.1:1234"
+	for name, values := range a.headers {
+		req.Header[name] = values
+	}
+
+	a.resp = httptest.NewRecorder()
+	initRouter(test_db, test_authenticator, test_mailer).ServeHTTP(a.resp, req)
 
 	// handle panic
 	defer func() {
@@ -50,6 +104,87 @@ func (a *apiFeature) iSendRequestTo(method, endpoint string) error {
 	return err
 }
 
+func (a *apiFeature) iAmAuthenticatedAsUser(id string) error {
+	user, err := test_authenticator.UserStorage.GetOne(id)
+	if err != nil {
+		return err
+	}
+
+	token, _, err := test_authenticator.IssueToken(user, user.HasTwoFactor())
+	if err != nil {
+		return err
+	}
+
+	a.headers.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+func (a *apiFeature) iAmNotAuthenticated() error {
+	a.headers.Del("Authorization")
+	return nil
+}
+
+func (a *apiFeature) iAmAuthenticatedAsUserWithATokenScopedTo(id, scopes string) error {
+	token, err := auth.GeneratePersonalToken()
+	if err != nil {
+		return err
+	}
+
+	_, err = storage.NewTokenStorage(test_db).Insert(model.Token{
+		Name:      "feature test",
+		Scopes:    model.Scopes(model.ParseList(scopes)),
+		TokenHash: auth.HashSecret(token),
+		UserId:    id,
+	})
+	if err != nil {
+		return err
+	}
+
+	a.headers.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+func (a *apiFeature) iSetTheHeaderTo(name, value string) error {
+	a.headers.Set(name, value)
+	return nil
+}
+
+func (a *apiFeature) iFailToLogInAsTimes(username string, times int) error {
+	body := fmt.Sprintf(`{"username": %q, "password": "not my password"}`, username)
+
+	for i := 0; i < times; i++ {
+		if err := a.sendRequest("POST", "/api/login", strings.NewReader(body)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (a *apiFeature) theScheduledJobsRun() error {
+	return newScheduler(test_db).RunAll()
+}
+
+func (a *apiFeature) theJobShouldHaveRunHandlingItems(name string, items int) error {
+	run, err := storage.NewJobRunStorage(test_db).GetOne(name)
+	if err != nil {
+		return err
+	}
+
+	if run.SucceededAt == nil || run.IsRunning() {
+		return fmt.Errorf("expected job %s to have succeeded, but it was %+v", name, run)
+	}
+
+	if run.Items != items {
+		return fmt.Errorf("expected job %s to handle %d items, but it handled %d",
+			name,
+			items,
+			run.Items)
+	}
+
+	return nil
+}
+
 func (a *apiFeature) theResponseCodeShouldBe(expectedStatus int) error {
 	actual := a.resp.Code
 
@@ -62,6 +197,19 @@ func (a *apiFeature) theResponseCodeShouldBe(expectedStatus int) error {
 	return nil
 }
 
+func (a *apiFeature) theResponseHeaderShouldBe(name, expected string) error {
+	actual := a.resp.Header().Get(name)
+
+	if actual != expected {
+		return fmt.Errorf("expected %s header to be %s, but it was %s",
+			name,
+			expected,
+			actual)
+	}
+
+	return nil
+}
+
 func (a *apiFeature) theResponseShouldMatchText(expectedResponseText string) error {
 	actual := strings.TrimSpace(a.resp.Body.String())
 
@@ -74,6 +222,26 @@ func (a *apiFeature) theResponseShouldMatchText(expectedResponseText string) err
 	return nil
 }
 
+func (a *apiFeature) theResponseShouldContainText(expectedText string) error {
+	if !strings.Contains(a.resp.Body.String(), expectedText) {
+		return fmt.Errorf("expected response to contain %s, but it was %s",
+			expectedText,
+			a.resp.Body.String())
+	}
+
+	return nil
+}
+
+func (a *apiFeature) theResponseShouldNotContainText(unexpectedText string) error {
+	if strings.Contains(a.resp.Body.String(), unexpectedText) {
+		return fmt.Errorf("expected response not to contain %s, but it was %s",
+			unexpectedText,
+			a.resp.Body.String())
+	}
+
+	return nil
+}
+
 func (a *apiFeature) theResponseShouldMatchJson(expectedJson *gherkin.DocString) error {
 	var (
 		expected, actual []byte
@@ -154,6 +322,82 @@ func (a *apiFeature) thereAreUsers(users *gherkin.DataTable) error {
 				vals = append(vals, updatedAt)
 			case "password_hash":
 				vals = append(vals, cell.Value)
+			case "role":
+				vals = append(vals, cell.Value)
+			case "totp_secret":
+				vals = append(vals, cell.Value)
+			case "totp_enabled_at":
+				enabledAt, err := time.Parse(time.RFC3339, cell.Value)
+				if err != nil {
+					return err
+				}
+
+				vals = append(vals, enabledAt)
+			case "locked_until":
+				lockedUntil, err := time.Parse(time.RFC3339, cell.Value)
+				if err != nil {
+					return err
+				}
+
+				vals = append(vals, lockedUntil)
+			case "deleted_at":
+				// Blank for users that are not in the trash
+				if len(cell.Value) == 0 {
+					vals = append(vals, nil)
+					continue
+				}
+
+				deletedAt, err := time.Parse(time.RFC3339, cell.Value)
+				if err != nil {
+					return err
+				}
+
+				vals = append(vals, deletedAt)
+			default:
+				return fmt.Errorf("unexpected column name: %s", head[n].Value)
+			}
+		}
+		if _, err = stmt.Exec(vals...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *apiFeature) thereArePosts(posts *gherkin.DataTable) error {
+	var fields []string
+	var marks []string
+	head := posts.Rows[0].Cells
+	for _, cell := range head {
+		fields = append(fields, cell.Value)
+		marks = append(marks, "?")
+	}
+
+	stmt, err := test_db.Preparex("INSERT INTO posts (" + strings.Join(fields, ", ") + ") VALUES(" + strings.Join(marks, ", ") + ")")
+
+	if err != nil {
+		return err
+	}
+
+	for i := 1; i < len(posts.Rows); i++ {
+		var vals []interface{}
+		for n, cell := range posts.Rows[i].Cells {
+			switch head[n].Value {
+			case "id", "user_id", "category_id", "title", "excerpt", "content", "permalink", "status":
+				vals = append(vals, cell.Value)
+			case "created_at", "updated_at", "published_at", "deleted_at":
+				// Blank for posts that are not published or not in the trash
+				if len(cell.Value) == 0 {
+					vals = append(vals, nil)
+					continue
+				}
+
+				parsed, err := time.Parse(time.RFC3339, cell.Value)
+				if err != nil {
+					return err
+				}
+
+				vals = append(vals, parsed)
 			default:
 				return fmt.Errorf("unexpected column name: %s", head[n].Value)
 			}
@@ -165,6 +409,76 @@ func (a *apiFeature) thereAreUsers(users *gherkin.DataTable) error {
 	return nil
 }
 
+func (a *apiFeature) thereAreTags(tags *gherkin.DataTable) error {
+	return insertRows("tags", tags, "id", "name", "slug")
+}
+
+func (a *apiFeature) thereAreCategories(categories *gherkin.DataTable) error {
+	return insertRows("categories", categories, "id", "name", "slug", "parent_id", "path")
+}
+
+func (a *apiFeature) thereAreSeries(series *gherkin.DataTable) error {
+	return insertRows("series", series, "id", "title", "slug", "description")
+}
+
+func (a *apiFeature) theSeriesContainPosts(seriesPosts *gherkin.DataTable) error {
+	return insertRows("series_posts", seriesPosts, "series_id", "post_id", "position")
+}
+
+func (a *apiFeature) thePostsHaveAuthors(postAuthors *gherkin.DataTable) error {
+	return insertRows("post_authors", postAuthors, "post_id", "user_id", "position")
+}
+
+func (a *apiFeature) thePostsAreTagged(postTags *gherkin.DataTable) error {
+	return insertRows("posts_tags", postTags, "post_id", "tag_id")
+}
+
+// insertRows inserts a table of plain values into the given columns, with
+// blank cells left NULL
+func insertRows(table string, rows *gherkin.DataTable, columns ...string) error {
+	var fields []string
+	var marks []string
+	head := rows.Rows[0].Cells
+	for _, cell := range head {
+		if !containsColumn(columns, cell.Value) {
+			return fmt.Errorf("unexpected column name: %s", cell.Value)
+		}
+
+		fields = append(fields, cell.Value)
+		marks = append(marks, "?")
+	}
+
+	stmt, err := test_db.Preparex("INSERT INTO " + table + " (" + strings.Join(fields, ", ") + ") VALUES(" + strings.Join(marks, ", ") + ")")
+	if err != nil {
+		return err
+	}
+
+	for i := 1; i < len(rows.Rows); i++ {
+		var vals []interface{}
+		for _, cell := range rows.Rows[i].Cells {
+			if len(cell.Value) == 0 {
+				vals = append(vals, nil)
+			} else {
+				vals = append(vals, cell.Value)
+			}
+		}
+		if _, err = stmt.Exec(vals...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func containsColumn(columns []string, column string) bool {
+	for _, candidate := range columns {
+		if candidate == column {
+			return true
+		}
+	}
+
+	return false
+}
+
 func FeatureContext(s *godog.Suite) {
 	api := &apiFeature{}
 
@@ -172,12 +486,48 @@ func FeatureContext(s *godog.Suite) {
 
 	s.Step(`^I send "([^"]*)" request to "([^"]*)"$`,
 		api.iSendRequestTo)
+	s.Step(`^I send "([^"]*)" request to "([^"]*)" with body:$`,
+		api.iSendRequestToWithBody)
+	s.Step(`^I am authenticated as user "([^"]*)"$`,
+		api.iAmAuthenticatedAsUser)
+	s.Step(`^I am not authenticated$`,
+		api.iAmNotAuthenticated)
+	s.Step(`^I am authenticated as user "([^"]*)" with a token scoped to "([^"]*)"$`,
+		api.iAmAuthenticatedAsUserWithATokenScopedTo)
+	s.Step(`^I set the "([^"]*)" header to "([^"]*)"$`,
+		api.iSetTheHeaderTo)
+	s.Step(`^I fail to log in as "([^"]*)" (\d+) times$`,
+		api.iFailToLogInAsTimes)
+	s.Step(`^the scheduled jobs run$`,
+		api.theScheduledJobsRun)
+	s.Step(`^the job "([^"]*)" should have run handling (\d+) items?$`,
+		api.theJobShouldHaveRunHandlingItems)
 	s.Step(`^the response code should be (\d+)$`,
 		api.theResponseCodeShouldBe)
+	s.Step(`^the response header "([^"]*)" should be "([^"]*)"$`,
+		api.theResponseHeaderShouldBe)
 	s.Step(`^the response should match text "([^"]*)"$`,
 		api.theResponseShouldMatchText)
+	s.Step(`^the response should contain text "([^"]*)"$`,
+		api.theResponseShouldContainText)
+	s.Step(`^the response should not contain text "([^"]*)"$`,
+		api.theResponseShouldNotContainText)
 	s.Step(`^the response should match json:$`,
 		api.theResponseShouldMatchJson)
 	s.Step(`^there are users:$`,
 		api.thereAreUsers)
+	s.Step(`^there are posts:$`,
+		api.thereArePosts)
+	s.Step(`^there are tags:$`,
+		api.thereAreTags)
+	s.Step(`^there are categories:$`,
+		api.thereAreCategories)
+	s.Step(`^there are series:$`,
+		api.thereAreSeries)
+	s.Step(`^the series contain posts:$`,
+		api.theSeriesContainPosts)
+	s.Step(`^the posts are tagged:$`,
+		api.thePostsAreTagged)
+	s.Step(`^the posts have authors:$`,
+		api.thePostsHaveAuthors)
 }